@@ -7,6 +7,7 @@ import (
 type AccountApi interface {
 	WalletBalance(ctx context.Context, req AccountWalletBalanceReq) (AccountWalletBalanceRes, error)
 	TransferableAmount(ctx context.Context, req AccountTransferableAmountReq) (AccountTransferableAmountRes, error)
+	TransactionLog(ctx context.Context, req AccountTransactionLogReq) (AccountTransactionLogRes, error)
 }
 
 type AccountWalletBalanceReq struct {
@@ -40,6 +41,51 @@ type AccountTransferableAmountRes struct {
 	} `json:"result"`
 }
 
+type TransactionType string
+
+const (
+	TransactionTypeSettlement = TransactionType("SETTLEMENT") // Funding fee.
+)
+
+type AccountTransactionLogReq struct {
+	AccountType AccountType     `url:"accountType,omitempty"`
+	Category    ProductType     `url:"category,omitempty"`
+	Currency    Coin            `url:"currency,omitempty"`
+	Type        TransactionType `url:"type,omitempty"`
+	StartTime   Timestamp       `url:"startTime"` // Range of the start and the end time cannot exceed 7 days.
+	EndTime     Timestamp       `url:"endTime"`
+	Limit       uint            `url:"limit,omitempty"`  // Limit for data size per page. [1, 50]. Default: 20
+	Cursor      string          `url:"cursor,omitempty"` // Use the nextPageCursor token from the response to retrieve the next page of the result set.
+}
+type AccountTransactionLogRes struct {
+	ResponseBase `json:",inline"`
+
+	Result struct {
+		List           []TransactionLog `json:"list"`
+		NextPageCursor string           `json:"nextPageCursor"`
+	} `json:"result"`
+}
+
+func (r AccountTransactionLogReq) WithCursor(cursor string) AccountTransactionLogReq {
+	r.Cursor = cursor
+	return r
+}
+
+func (r *AccountTransactionLogRes) Page() ([]TransactionLog, string) {
+	return r.Result.List, r.Result.NextPageCursor
+}
+
+type TransactionLog struct {
+	Symbol          Symbol          `json:"symbol"`
+	Category        ProductType     `json:"category"`
+	Currency        Coin            `json:"currency"`
+	Type            TransactionType `json:"type"`
+	Funding         Amount          `json:"funding"` // Positive value means deduction of funding fee.
+	CashFlow        Amount          `json:"cashFlow"`
+	Change          Amount          `json:"change"`
+	TransactionTime Timestamp       `json:"transactionTime"`
+}
+
 type accountApi struct {
 	client *client
 }
@@ -55,3 +101,9 @@ func (a *accountApi) TransferableAmount(ctx context.Context, req AccountTransfer
 	err = a.client.get(ctx, url, &req, &res)
 	return
 }
+
+func (a *accountApi) TransactionLog(ctx context.Context, req AccountTransactionLogReq) (res AccountTransactionLogRes, err error) {
+	url := a.client.conf.endpoint.Get("/v5/account/transaction-log")
+	err = a.client.get(ctx, url, &req, &res)
+	return
+}
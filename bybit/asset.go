@@ -33,15 +33,22 @@ type AssetQueryAccountCoinBalanceRes struct {
 	} `json:"result"`
 }
 
+// AssetInterTransferReq transfers between accounts of the same UID.
 type AssetInterTransferReq struct {
 	TransferId TransferId `json:"transferId"`
 
 	Coin            Coin        `json:"coin"`
-	Amount          Amount      `json:"amount"`
+	Amount          string      `json:"amount"`
 	FromAccountType AccountType `json:"fromAccountType"`
 	ToAccountType   AccountType `json:"toAccountType"`
 }
 type AssetInterTransferRes struct {
+	ResponseBase `json:",inline"`
+
+	Result struct {
+		TransferId TransferId     `json:"transferId"`
+		Status     TransferStatus `json:"status"`
+	} `json:"result"`
 }
 
 type AssetUniversalTransferReq struct {
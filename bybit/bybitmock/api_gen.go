@@ -0,0 +1,268 @@
+// Code generated by gen.go; DO NOT EDIT.
+
+package bybitmock
+
+import (
+	"context"
+
+	"github.com/lesomnus/tiny-short/bybit"
+)
+
+// UserApi stubs `bybit.UserApi`.
+// A call to a method whose func is nil fails with `ErrNotStubbed`.
+type UserApi struct {
+	CreateSubApiKeyFunc func(ctx context.Context, req bybit.UserCreateSubApiKeyReq) (bybit.UserCreateSubApiKeyRes, error)
+	DeleteSubApiKeyFunc func(ctx context.Context, req bybit.UserDeleteSubApiKeyReq) (bybit.UserDeleteSubApiKeyRes, error)
+	QueryApiFunc        func(ctx context.Context, req bybit.UserQueryApiReq) (bybit.UserQueryApiRes, error)
+	QuerySubMembersFunc func(ctx context.Context, req bybit.UserQuerySubMembersReq) (bybit.UserQuerySubMembersRes, error)
+	SubApiKeysFunc      func(ctx context.Context, req bybit.UserSubApiKeysReq) (bybit.UserSubApiKeysRes, error)
+	SubMembersFunc      func(ctx context.Context, req bybit.UserSubMembersReq) (bybit.UserSubMembersRes, error)
+	UpdateSubApiKeyFunc func(ctx context.Context, req bybit.UserUpdateSubApiKeyReq) (bybit.UserUpdateSubApiKeyRes, error)
+}
+
+// userApi is `bybit.UserApi` of the client that records calls and dispatches them to `UserApi`.
+type userApi struct {
+	c *Client
+}
+
+func (c *Client) User() bybit.UserApi {
+	return userApi{c}
+}
+
+func (a userApi) CreateSubApiKey(ctx context.Context, req bybit.UserCreateSubApiKeyReq) (bybit.UserCreateSubApiKeyRes, error) {
+	a.c.record("User.CreateSubApiKey", req)
+	if a.c.UserApi.CreateSubApiKeyFunc == nil {
+		return bybit.UserCreateSubApiKeyRes{}, notStubbed("User.CreateSubApiKey")
+	}
+	return a.c.UserApi.CreateSubApiKeyFunc(ctx, req)
+}
+
+func (a userApi) DeleteSubApiKey(ctx context.Context, req bybit.UserDeleteSubApiKeyReq) (bybit.UserDeleteSubApiKeyRes, error) {
+	a.c.record("User.DeleteSubApiKey", req)
+	if a.c.UserApi.DeleteSubApiKeyFunc == nil {
+		return bybit.UserDeleteSubApiKeyRes{}, notStubbed("User.DeleteSubApiKey")
+	}
+	return a.c.UserApi.DeleteSubApiKeyFunc(ctx, req)
+}
+
+func (a userApi) QueryApi(ctx context.Context, req bybit.UserQueryApiReq) (bybit.UserQueryApiRes, error) {
+	a.c.record("User.QueryApi", req)
+	if a.c.UserApi.QueryApiFunc == nil {
+		return bybit.UserQueryApiRes{}, notStubbed("User.QueryApi")
+	}
+	return a.c.UserApi.QueryApiFunc(ctx, req)
+}
+
+func (a userApi) QuerySubMembers(ctx context.Context, req bybit.UserQuerySubMembersReq) (bybit.UserQuerySubMembersRes, error) {
+	a.c.record("User.QuerySubMembers", req)
+	if a.c.UserApi.QuerySubMembersFunc == nil {
+		return bybit.UserQuerySubMembersRes{}, notStubbed("User.QuerySubMembers")
+	}
+	return a.c.UserApi.QuerySubMembersFunc(ctx, req)
+}
+
+func (a userApi) SubApiKeys(ctx context.Context, req bybit.UserSubApiKeysReq) (bybit.UserSubApiKeysRes, error) {
+	a.c.record("User.SubApiKeys", req)
+	if a.c.UserApi.SubApiKeysFunc == nil {
+		return bybit.UserSubApiKeysRes{}, notStubbed("User.SubApiKeys")
+	}
+	return a.c.UserApi.SubApiKeysFunc(ctx, req)
+}
+
+func (a userApi) SubMembers(ctx context.Context, req bybit.UserSubMembersReq) (bybit.UserSubMembersRes, error) {
+	a.c.record("User.SubMembers", req)
+	if a.c.UserApi.SubMembersFunc == nil {
+		return bybit.UserSubMembersRes{}, notStubbed("User.SubMembers")
+	}
+	return a.c.UserApi.SubMembersFunc(ctx, req)
+}
+
+func (a userApi) UpdateSubApiKey(ctx context.Context, req bybit.UserUpdateSubApiKeyReq) (bybit.UserUpdateSubApiKeyRes, error) {
+	a.c.record("User.UpdateSubApiKey", req)
+	if a.c.UserApi.UpdateSubApiKeyFunc == nil {
+		return bybit.UserUpdateSubApiKeyRes{}, notStubbed("User.UpdateSubApiKey")
+	}
+	return a.c.UserApi.UpdateSubApiKeyFunc(ctx, req)
+}
+
+// AccountApi stubs `bybit.AccountApi`.
+// A call to a method whose func is nil fails with `ErrNotStubbed`.
+type AccountApi struct {
+	TransactionLogFunc     func(ctx context.Context, req bybit.AccountTransactionLogReq) (bybit.AccountTransactionLogRes, error)
+	TransferableAmountFunc func(ctx context.Context, req bybit.AccountTransferableAmountReq) (bybit.AccountTransferableAmountRes, error)
+	WalletBalanceFunc      func(ctx context.Context, req bybit.AccountWalletBalanceReq) (bybit.AccountWalletBalanceRes, error)
+}
+
+// accountApi is `bybit.AccountApi` of the client that records calls and dispatches them to `AccountApi`.
+type accountApi struct {
+	c *Client
+}
+
+func (c *Client) Account() bybit.AccountApi {
+	return accountApi{c}
+}
+
+func (a accountApi) TransactionLog(ctx context.Context, req bybit.AccountTransactionLogReq) (bybit.AccountTransactionLogRes, error) {
+	a.c.record("Account.TransactionLog", req)
+	if a.c.AccountApi.TransactionLogFunc == nil {
+		return bybit.AccountTransactionLogRes{}, notStubbed("Account.TransactionLog")
+	}
+	return a.c.AccountApi.TransactionLogFunc(ctx, req)
+}
+
+func (a accountApi) TransferableAmount(ctx context.Context, req bybit.AccountTransferableAmountReq) (bybit.AccountTransferableAmountRes, error) {
+	a.c.record("Account.TransferableAmount", req)
+	if a.c.AccountApi.TransferableAmountFunc == nil {
+		return bybit.AccountTransferableAmountRes{}, notStubbed("Account.TransferableAmount")
+	}
+	return a.c.AccountApi.TransferableAmountFunc(ctx, req)
+}
+
+func (a accountApi) WalletBalance(ctx context.Context, req bybit.AccountWalletBalanceReq) (bybit.AccountWalletBalanceRes, error) {
+	a.c.record("Account.WalletBalance", req)
+	if a.c.AccountApi.WalletBalanceFunc == nil {
+		return bybit.AccountWalletBalanceRes{}, notStubbed("Account.WalletBalance")
+	}
+	return a.c.AccountApi.WalletBalanceFunc(ctx, req)
+}
+
+// AssetApi stubs `bybit.AssetApi`.
+// A call to a method whose func is nil fails with `ErrNotStubbed`.
+type AssetApi struct {
+	InterTransferFunc           func(ctx context.Context, req bybit.AssetInterTransferReq) (bybit.AssetInterTransferRes, error)
+	QueryAccountCoinBalanceFunc func(ctx context.Context, req bybit.AssetQueryAccountCoinBalanceReq) (bybit.AssetQueryAccountCoinBalanceRes, error)
+	UniversalTransferFunc       func(ctx context.Context, req bybit.AssetUniversalTransferReq) (bybit.AssetUniversalTransferRes, error)
+}
+
+// assetApi is `bybit.AssetApi` of the client that records calls and dispatches them to `AssetApi`.
+type assetApi struct {
+	c *Client
+}
+
+func (c *Client) Asset() bybit.AssetApi {
+	return assetApi{c}
+}
+
+func (a assetApi) InterTransfer(ctx context.Context, req bybit.AssetInterTransferReq) (bybit.AssetInterTransferRes, error) {
+	a.c.record("Asset.InterTransfer", req)
+	if a.c.AssetApi.InterTransferFunc == nil {
+		return bybit.AssetInterTransferRes{}, notStubbed("Asset.InterTransfer")
+	}
+	return a.c.AssetApi.InterTransferFunc(ctx, req)
+}
+
+func (a assetApi) QueryAccountCoinBalance(ctx context.Context, req bybit.AssetQueryAccountCoinBalanceReq) (bybit.AssetQueryAccountCoinBalanceRes, error) {
+	a.c.record("Asset.QueryAccountCoinBalance", req)
+	if a.c.AssetApi.QueryAccountCoinBalanceFunc == nil {
+		return bybit.AssetQueryAccountCoinBalanceRes{}, notStubbed("Asset.QueryAccountCoinBalance")
+	}
+	return a.c.AssetApi.QueryAccountCoinBalanceFunc(ctx, req)
+}
+
+func (a assetApi) UniversalTransfer(ctx context.Context, req bybit.AssetUniversalTransferReq) (bybit.AssetUniversalTransferRes, error) {
+	a.c.record("Asset.UniversalTransfer", req)
+	if a.c.AssetApi.UniversalTransferFunc == nil {
+		return bybit.AssetUniversalTransferRes{}, notStubbed("Asset.UniversalTransfer")
+	}
+	return a.c.AssetApi.UniversalTransferFunc(ctx, req)
+}
+
+// MarketApi stubs `bybit.MarketApi`.
+// A call to a method whose func is nil fails with `ErrNotStubbed`.
+type MarketApi struct {
+	FundingHistoryFunc  func(ctx context.Context, req bybit.MarketFundingHistoryReq) (bybit.MarketFundingHistoryRes, error)
+	InstrumentsInfoFunc func(ctx context.Context, req bybit.MarketInstrumentsInfoReq) (bybit.MarketInstrumentsInfoRes, error)
+	TickersFunc         func(ctx context.Context, req bybit.MarketTickersReq) (bybit.MarketTickersRes, error)
+}
+
+// marketApi is `bybit.MarketApi` of the client that records calls and dispatches them to `MarketApi`.
+type marketApi struct {
+	c *Client
+}
+
+func (c *Client) Market() bybit.MarketApi {
+	return marketApi{c}
+}
+
+func (a marketApi) FundingHistory(ctx context.Context, req bybit.MarketFundingHistoryReq) (bybit.MarketFundingHistoryRes, error) {
+	a.c.record("Market.FundingHistory", req)
+	if a.c.MarketApi.FundingHistoryFunc == nil {
+		return bybit.MarketFundingHistoryRes{}, notStubbed("Market.FundingHistory")
+	}
+	return a.c.MarketApi.FundingHistoryFunc(ctx, req)
+}
+
+func (a marketApi) InstrumentsInfo(ctx context.Context, req bybit.MarketInstrumentsInfoReq) (bybit.MarketInstrumentsInfoRes, error) {
+	a.c.record("Market.InstrumentsInfo", req)
+	if a.c.MarketApi.InstrumentsInfoFunc == nil {
+		return bybit.MarketInstrumentsInfoRes{}, notStubbed("Market.InstrumentsInfo")
+	}
+	return a.c.MarketApi.InstrumentsInfoFunc(ctx, req)
+}
+
+func (a marketApi) Tickers(ctx context.Context, req bybit.MarketTickersReq) (bybit.MarketTickersRes, error) {
+	a.c.record("Market.Tickers", req)
+	if a.c.MarketApi.TickersFunc == nil {
+		return bybit.MarketTickersRes{}, notStubbed("Market.Tickers")
+	}
+	return a.c.MarketApi.TickersFunc(ctx, req)
+}
+
+// TradeApi stubs `bybit.TradeApi`.
+// A call to a method whose func is nil fails with `ErrNotStubbed`.
+type TradeApi struct {
+	ExecutionListFunc func(ctx context.Context, req bybit.TradeExecutionListReq) (bybit.TradeExecutionListRes, error)
+	OrderAmendFunc    func(ctx context.Context, req bybit.TradeOrderAmendReq) (bybit.TradeOrderAmendRes, error)
+	OrderCancelFunc   func(ctx context.Context, req bybit.TradeOrderCancelReq) (bybit.TradeOrderCancelRes, error)
+	OrderCreateFunc   func(ctx context.Context, req bybit.TradeOrderCreateApiReq) (bybit.TradeOrderCreateApiRes, error)
+	OrderHistoryFunc  func(ctx context.Context, req bybit.TradeOrderHistoryReq) (bybit.TradeOrderHistoryRes, error)
+}
+
+// tradeApi is `bybit.TradeApi` of the client that records calls and dispatches them to `TradeApi`.
+type tradeApi struct {
+	c *Client
+}
+
+func (c *Client) Trade() bybit.TradeApi {
+	return tradeApi{c}
+}
+
+func (a tradeApi) ExecutionList(ctx context.Context, req bybit.TradeExecutionListReq) (bybit.TradeExecutionListRes, error) {
+	a.c.record("Trade.ExecutionList", req)
+	if a.c.TradeApi.ExecutionListFunc == nil {
+		return bybit.TradeExecutionListRes{}, notStubbed("Trade.ExecutionList")
+	}
+	return a.c.TradeApi.ExecutionListFunc(ctx, req)
+}
+
+func (a tradeApi) OrderAmend(ctx context.Context, req bybit.TradeOrderAmendReq) (bybit.TradeOrderAmendRes, error) {
+	a.c.record("Trade.OrderAmend", req)
+	if a.c.TradeApi.OrderAmendFunc == nil {
+		return bybit.TradeOrderAmendRes{}, notStubbed("Trade.OrderAmend")
+	}
+	return a.c.TradeApi.OrderAmendFunc(ctx, req)
+}
+
+func (a tradeApi) OrderCancel(ctx context.Context, req bybit.TradeOrderCancelReq) (bybit.TradeOrderCancelRes, error) {
+	a.c.record("Trade.OrderCancel", req)
+	if a.c.TradeApi.OrderCancelFunc == nil {
+		return bybit.TradeOrderCancelRes{}, notStubbed("Trade.OrderCancel")
+	}
+	return a.c.TradeApi.OrderCancelFunc(ctx, req)
+}
+
+func (a tradeApi) OrderCreate(ctx context.Context, req bybit.TradeOrderCreateApiReq) (bybit.TradeOrderCreateApiRes, error) {
+	a.c.record("Trade.OrderCreate", req)
+	if a.c.TradeApi.OrderCreateFunc == nil {
+		return bybit.TradeOrderCreateApiRes{}, notStubbed("Trade.OrderCreate")
+	}
+	return a.c.TradeApi.OrderCreateFunc(ctx, req)
+}
+
+func (a tradeApi) OrderHistory(ctx context.Context, req bybit.TradeOrderHistoryReq) (bybit.TradeOrderHistoryRes, error) {
+	a.c.record("Trade.OrderHistory", req)
+	if a.c.TradeApi.OrderHistoryFunc == nil {
+		return bybit.TradeOrderHistoryRes{}, notStubbed("Trade.OrderHistory")
+	}
+	return a.c.TradeApi.OrderHistoryFunc(ctx, req)
+}
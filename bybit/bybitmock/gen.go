@@ -0,0 +1,76 @@
+//go:build ignore
+
+// Generates fakes of Api groups of `bybit.Client` into "api_gen.go".
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/lesomnus/tiny-short/bybit"
+)
+
+type group struct {
+	name string // Method of `bybit.Client` returns the group, e.g. "User".
+	t    reflect.Type
+}
+
+func main() {
+	groups := []group{
+		{"User", reflect.TypeFor[bybit.UserApi]()},
+		{"Account", reflect.TypeFor[bybit.AccountApi]()},
+		{"Asset", reflect.TypeFor[bybit.AssetApi]()},
+		{"Market", reflect.TypeFor[bybit.MarketApi]()},
+		{"Trade", reflect.TypeFor[bybit.TradeApi]()},
+	}
+
+	var b bytes.Buffer
+	b.WriteString("// Code generated by gen.go; DO NOT EDIT.\n\n")
+	b.WriteString("package bybitmock\n\n")
+	b.WriteString("import (\n\t\"context\"\n\n\t\"github.com/lesomnus/tiny-short/bybit\"\n)\n\n")
+
+	for _, g := range groups {
+		fake := g.t.Name()
+		view := strings.ToLower(fake[:1]) + fake[1:]
+
+		fmt.Fprintf(&b, "// %s stubs `bybit.%s`.\n", fake, fake)
+		fmt.Fprintf(&b, "// A call to a method whose func is nil fails with `ErrNotStubbed`.\n")
+		fmt.Fprintf(&b, "type %s struct {\n", fake)
+		for i := range g.t.NumMethod() {
+			m := g.t.Method(i)
+			fmt.Fprintf(&b, "\t%sFunc func(ctx context.Context, req bybit.%s) (bybit.%s, error)\n", m.Name, m.Type.In(1).Name(), m.Type.Out(0).Name())
+		}
+		b.WriteString("}\n\n")
+
+		fmt.Fprintf(&b, "// %s is `bybit.%s` of the client that records calls and dispatches them to `%s`.\n", view, fake, fake)
+		fmt.Fprintf(&b, "type %s struct {\n\tc *Client\n}\n\n", view)
+		fmt.Fprintf(&b, "func (c *Client) %s() bybit.%s {\n\treturn %s{c}\n}\n\n", g.name, fake, view)
+
+		for i := range g.t.NumMethod() {
+			m := g.t.Method(i)
+			req := m.Type.In(1).Name()
+			res := m.Type.Out(0).Name()
+			key := g.name + "." + m.Name
+
+			fmt.Fprintf(&b, "func (a %s) %s(ctx context.Context, req bybit.%s) (bybit.%s, error) {\n", view, m.Name, req, res)
+			fmt.Fprintf(&b, "\ta.c.record(%q, req)\n", key)
+			fmt.Fprintf(&b, "\tif a.c.%s.%sFunc == nil {\n", fake, m.Name)
+			fmt.Fprintf(&b, "\t\treturn bybit.%s{}, notStubbed(%q)\n", res, key)
+			b.WriteString("\t}\n")
+			fmt.Fprintf(&b, "\treturn a.c.%s.%sFunc(ctx, req)\n", fake, m.Name)
+			b.WriteString("}\n\n")
+		}
+	}
+
+	data, err := format.Source(b.Bytes())
+	if err != nil {
+		panic(err)
+	}
+	if err := os.WriteFile("api_gen.go", data, 0644); err != nil {
+		panic(err)
+	}
+}
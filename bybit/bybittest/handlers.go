@@ -0,0 +1,608 @@
+package bybittest
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/lesomnus/tiny-short/bybit"
+)
+
+func (s *Server) route(mux *http.ServeMux) {
+	s.handle(mux, "GET /v5/user/query-api", false, s.queryApi)
+	s.handle(mux, "GET /v5/user/query-sub-members", false, s.querySubMembers)
+	s.handle(mux, "GET /v5/user/submembers", false, s.subMembers)
+	s.handle(mux, "POST /v5/user/create-sub-api", false, s.createSubApiKey)
+	s.handle(mux, "GET /v5/user/sub-apikeys", false, s.subApiKeys)
+	s.handle(mux, "POST /v5/user/delete-sub-api", false, s.deleteSubApiKey)
+
+	s.handle(mux, "GET /v5/account/withdrawal", false, s.transferableAmount)
+	s.handle(mux, "GET /v5/account/transaction-log", false, s.transactionLog)
+
+	s.handle(mux, "GET /v5/asset/transfer/query-account-coin-balance", false, s.queryAccountCoinBalance)
+	s.handle(mux, "POST /v5/asset/transfer/inter-transfer", false, s.interTransfer)
+	s.handle(mux, "POST /v5/asset/transfer/universal-transfer", false, s.universalTransfer)
+
+	s.handle(mux, "GET /v5/market/instruments-info", true, s.instrumentsInfo)
+	s.handle(mux, "GET /v5/market/tickers", true, s.tickersInfo)
+	s.handle(mux, "GET /v5/market/funding/history", true, s.fundingHistory)
+
+	s.handle(mux, "POST /v5/order/create", false, s.orderCreate)
+	s.handle(mux, "POST /v5/order/amend", false, s.orderAmend)
+	s.handle(mux, "POST /v5/order/cancel", false, s.orderCancel)
+	s.handle(mux, "GET /v5/order/history", false, s.orderHistory)
+	s.handle(mux, "GET /v5/execution/list", false, s.executionList)
+
+	mux.HandleFunc("GET /v5/private", s.servePrivate)
+}
+
+func (r *request) isMain() bool {
+	return r.key.uid == MainUserId
+}
+
+// deny returns the error response if the key does not have the permission in the group,
+// e.g. `r.deny("Wallet", r.key.perms.Wallet, "AccountTransfer")`.
+func (r *request) deny(group string, perms []string, perm string) *bybit.ResponseBase {
+	if slices.Contains(perms, perm) {
+		return nil
+	}
+
+	res := errorRes(RetCodePermissionDenied, fmt.Sprintf("permission denied, %s %s is required", group, perm))
+	return &res
+}
+
+func (s *Server) queryApi(r *request) any {
+	res := bybit.UserQueryApiRes{}
+	res.Result.UserId = r.key.uid
+	res.Result.IsMaster = r.isMain()
+	res.Result.Permissions = r.key.perms
+	res.Result.CreatedAt = r.key.created_at
+	res.Result.ExpiredAt = r.key.expired_at
+	if r.key.read_only {
+		res.Result.ReadOnly = 1
+	}
+	return res
+}
+
+// members returns sub members in order of UID.
+func (s *Server) members() []bybit.SubMember {
+	vs := []bybit.SubMember{}
+	for uid, a := range s.accounts {
+		if uid != MainUserId {
+			vs = append(vs, a.member)
+		}
+	}
+	slices.SortFunc(vs, func(a, b bybit.SubMember) int {
+		return int(a.UserId) - int(b.UserId)
+	})
+	return vs
+}
+
+func (s *Server) querySubMembers(r *request) any {
+	if !r.isMain() {
+		return errorRes(RetCodePermissionDenied, "permission denied for sub account")
+	}
+
+	res := bybit.UserQuerySubMembersRes{}
+	res.Result.SubMembers = s.members()
+	return res
+}
+
+func (s *Server) subMembers(r *request) any {
+	if !r.isMain() {
+		return errorRes(RetCodePermissionDenied, "permission denied for sub account")
+	}
+
+	size := 10
+	if v := r.query.Get("pageSize"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 100 {
+			return errorRes(RetCodeInvalidParams, "invalid pageSize")
+		}
+		size = n
+	}
+
+	vs, next := page(s.members(), r.query.Get("nextCursor"), size)
+	if next == "" {
+		next = "0"
+	}
+
+	res := bybit.UserSubMembersRes{}
+	res.Result.SubMembers = vs
+	res.Result.NextCursor = next
+	return res
+}
+
+// page returns items at the cursor, which is an offset, and the cursor of the next page.
+func page[T any](vs []T, cursor string, size int) ([]T, string) {
+	offset, _ := strconv.Atoi(cursor)
+	if offset >= len(vs) {
+		return []T{}, ""
+	}
+
+	end := min(offset+size, len(vs))
+	if end == len(vs) {
+		return vs[offset:end], ""
+	}
+	return vs[offset:end], strconv.Itoa(end)
+}
+
+func (s *Server) createSubApiKey(r *request) any {
+	if !r.isMain() {
+		return errorRes(RetCodePermissionDenied, "permission denied for sub account")
+	}
+
+	var req bybit.UserCreateSubApiKeyReq
+	if err := r.decode(&req); err != nil {
+		return errorRes(RetCodeInvalidParams, err.Error())
+	}
+	if _, ok := s.accounts[req.SubUserId]; !ok || req.SubUserId == MainUserId {
+		return errorRes(RetCodeInvalidParams, "sub member not found")
+	}
+
+	now := s.now()
+	k := &apiKey{
+		uid: req.SubUserId,
+		secret: bybit.SecretRecord{
+			Type:   bybit.SecretTypeHmac,
+			ApiKey: randomHex(9),
+			Secret: randomHex(18),
+		},
+		perms:      req.Permissions,
+		read_only:  req.ReadOnly != 0,
+		note:       req.Note,
+		created_at: now,
+		expired_at: now.Add(90 * 24 * time.Hour),
+	}
+	s.keys[k.secret.ApiKey] = k
+
+	res := bybit.UserCreateSubApiKeyRes{}
+	res.Result.ApiKey = k.secret.ApiKey
+	res.Result.Secret = k.secret.Secret
+	return res
+}
+
+func (s *Server) subApiKeys(r *request) any {
+	if !r.isMain() {
+		return errorRes(RetCodePermissionDenied, "permission denied for sub account")
+	}
+
+	uid, err := strconv.ParseUint(r.query.Get("subMemberId"), 10, 64)
+	if err != nil {
+		return errorRes(RetCodeInvalidParams, "invalid subMemberId")
+	}
+
+	keys := []bybit.SubApiKeyInfo{}
+	for api_key, k := range s.keys {
+		if k.uid != bybit.UserId(uid) {
+			continue
+		}
+
+		flag := "hmac"
+		if k.public_key != nil {
+			flag = "rsa"
+		}
+		keys = append(keys, bybit.SubApiKeyInfo{
+			ApiKey:      api_key,
+			Note:        k.note,
+			ReadOnly:    k.read_only,
+			Permissions: k.perms,
+			CreatedAt:   k.created_at,
+			ExpiredAt:   k.expired_at,
+			Flag:        flag,
+		})
+	}
+	slices.SortFunc(keys, func(a, b bybit.SubApiKeyInfo) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+
+	size := 20
+	if v, err := strconv.Atoi(r.query.Get("limit")); err == nil && v > 0 {
+		size = v
+	}
+
+	res := bybit.UserSubApiKeysRes{}
+	res.Result.List, res.Result.NextPageCursor = page(keys, r.query.Get("cursor"), size)
+	return res
+}
+
+func (s *Server) deleteSubApiKey(r *request) any {
+	var req bybit.UserDeleteSubApiKeyReq
+	if err := r.decode(&req); err != nil {
+		return errorRes(RetCodeInvalidParams, err.Error())
+	}
+
+	api_key := req.ApiKey
+	if api_key == "" {
+		if r.isMain() {
+			return errorRes(RetCodeInvalidParams, "apikey is required for the main account")
+		}
+		api_key = r.key.secret.ApiKey
+	}
+
+	k, ok := s.keys[api_key]
+	if !ok || k.uid == MainUserId || (!r.isMain() && k.uid != r.key.uid) {
+		return errorRes(RetCodeInvalidParams, "API key not found")
+	}
+	delete(s.keys, api_key)
+
+	return bybit.UserDeleteSubApiKeyRes{}
+}
+
+func (s *Server) transferableAmount(r *request) any {
+	coin := bybit.Coin(r.query.Get("coinName"))
+
+	res := bybit.AccountTransferableAmountRes{}
+	res.Result.AvailableWithdrawal = s.balance(r.key.uid, bybit.AccountTypeUnified, coin)
+	return res
+}
+
+func (s *Server) transactionLog(r *request) any {
+	start, _ := strconv.ParseInt(r.query.Get("startTime"), 10, 64)
+	end, _ := strconv.ParseInt(r.query.Get("endTime"), 10, 64)
+
+	logs := []bybit.TransactionLog{}
+	for _, v := range s.accounts[r.key.uid].logs {
+		t := v.TransactionTime.Time().UnixMilli()
+		switch {
+		case r.query.Has("currency") && v.Currency != bybit.Coin(r.query.Get("currency")):
+		case r.query.Has("type") && v.Type != bybit.TransactionType(r.query.Get("type")):
+		case start > 0 && t < start:
+		case end > 0 && t > end:
+		default:
+			logs = append(logs, v)
+		}
+	}
+
+	size := 20
+	if v, err := strconv.Atoi(r.query.Get("limit")); err == nil && v > 0 {
+		size = v
+	}
+
+	res := bybit.AccountTransactionLogRes{}
+	res.Result.List, res.Result.NextPageCursor = page(logs, r.query.Get("cursor"), size)
+	return res
+}
+
+func (s *Server) queryAccountCoinBalance(r *request) any {
+	uid := r.key.uid
+	if v := r.query.Get("memberId"); v != "" {
+		n, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return errorRes(RetCodeInvalidParams, "invalid memberId")
+		}
+		uid = bybit.UserId(n)
+	}
+	if uid != r.key.uid && !r.isMain() {
+		return errorRes(RetCodePermissionDenied, "permission denied for sub account")
+	}
+	if _, ok := s.accounts[uid]; !ok {
+		return errorRes(RetCodeInvalidParams, "member not found")
+	}
+
+	account_type := bybit.AccountType(r.query.Get("accountType"))
+	coin := bybit.Coin(r.query.Get("coin"))
+	balance := s.balance(uid, account_type, coin)
+
+	res := bybit.AssetQueryAccountCoinBalanceRes{}
+	res.Result.AccountType = account_type
+	res.Result.MemberId = uid.String()
+	res.Result.Balance.Coin = coin
+	res.Result.Balance.WalletBalance = balance
+	res.Result.Balance.TransferBalance = balance
+	return res
+}
+
+// transfer moves the balance unless the status is scripted.
+func (s *Server) transfer(r *request, t Transfer) (bybit.TransferStatus, *bybit.ResponseBase) {
+	if _, ok := s.accounts[t.FromMember]; !ok {
+		res := errorRes(RetCodeInvalidParams, "from member not found")
+		return "", &res
+	}
+	if _, ok := s.accounts[t.ToMember]; !ok {
+		res := errorRes(RetCodeInvalidParams, "to member not found")
+		return "", &res
+	}
+	if t.Amount <= 0 {
+		res := errorRes(RetCodeInvalidParams, "invalid amount")
+		return "", &res
+	}
+
+	t.Status = bybit.TransferStatusSuccess
+	if r.failure != nil && r.failure.Status != "" {
+		t.Status = r.failure.Status
+	}
+	if t.Status == bybit.TransferStatusSuccess {
+		from := s.balance(t.FromMember, t.FromAccountType, t.Coin)
+		if from < t.Amount {
+			res := errorRes(RetCodeInsufficientBalance, "insufficient balance")
+			return "", &res
+		}
+
+		to := s.balance(t.ToMember, t.ToAccountType, t.Coin)
+		s.setBalance(t.FromMember, t.FromAccountType, t.Coin, from-t.Amount)
+		s.setBalance(t.ToMember, t.ToAccountType, t.Coin, to+t.Amount)
+	}
+
+	s.transfers = append(s.transfers, t)
+	return t.Status, nil
+}
+
+func (s *Server) interTransfer(r *request) any {
+	if e := r.deny("Wallet", r.key.perms.Wallet, "AccountTransfer"); e != nil {
+		return *e
+	}
+
+	var req bybit.AssetInterTransferReq
+	if err := r.decode(&req); err != nil {
+		return errorRes(RetCodeInvalidParams, err.Error())
+	}
+
+	amount, err := strconv.ParseFloat(req.Amount, 64)
+	if err != nil {
+		return errorRes(RetCodeInvalidParams, "invalid amount")
+	}
+	status, e := s.transfer(r, Transfer{
+		Coin:            req.Coin,
+		Amount:          bybit.Amount(amount),
+		FromMember:      r.key.uid,
+		ToMember:        r.key.uid,
+		FromAccountType: req.FromAccountType,
+		ToAccountType:   req.ToAccountType,
+	})
+	if e != nil {
+		return *e
+	}
+
+	res := bybit.AssetInterTransferRes{}
+	res.Result.TransferId = req.TransferId
+	res.Result.Status = status
+	return res
+}
+
+func (s *Server) universalTransfer(r *request) any {
+	if !r.isMain() {
+		return errorRes(RetCodePermissionDenied, "permission denied for sub account")
+	}
+	if e := r.deny("Wallet", r.key.perms.Wallet, "SubMemberTransfer"); e != nil {
+		return *e
+	}
+
+	var req bybit.AssetUniversalTransferReq
+	if err := r.decode(&req); err != nil {
+		return errorRes(RetCodeInvalidParams, err.Error())
+	}
+
+	amount, err := strconv.ParseFloat(req.Amount, 64)
+	if err != nil {
+		return errorRes(RetCodeInvalidParams, "invalid amount")
+	}
+	status, e := s.transfer(r, Transfer{
+		Coin:            req.Coin,
+		Amount:          bybit.Amount(amount),
+		FromMember:      req.FromMember,
+		ToMember:        req.ToMember,
+		FromAccountType: req.FromAccountType,
+		ToAccountType:   req.ToAccountType,
+	})
+	if e != nil {
+		return *e
+	}
+
+	res := bybit.AssetUniversalTransferRes{}
+	res.Result.TransferId = req.TransferId
+	res.Result.Status = status
+	return res
+}
+
+func (s *Server) instrumentsInfo(r *request) any {
+	symbol := bybit.Symbol(r.query.Get("symbol"))
+
+	res := bybit.MarketInstrumentsInfoRes{}
+	res.Result.Category = bybit.ProductType(r.query.Get("category"))
+	res.Result.List = append(res.Result.List, struct {
+		ContractType  bybit.ContractType `json:"contractType"`
+		PriceScale    string             `json:"priceScale"`
+		LotSizeFilter struct {
+			QtyStep     bybit.Amount `json:"qtyStep"`
+			MinOrderQty bybit.Amount `json:"minOrderQty"`
+		} `json:"lotSizeFilter"`
+	}{})
+
+	v := &res.Result.List[0]
+	v.PriceScale = "2"
+	if res.Result.Category == bybit.ProductTypeLinear {
+		v.ContractType = bybit.ContractTypeLinearPerpetual
+		v.LotSizeFilter.QtyStep = s.qty_steps[symbol]
+		v.LotSizeFilter.MinOrderQty = max(s.min_qtys[symbol], v.LotSizeFilter.QtyStep)
+	} else {
+		v.ContractType = bybit.ContractTypeInversePerpetual
+		v.LotSizeFilter.QtyStep = 1
+		v.LotSizeFilter.MinOrderQty = 1
+	}
+	return res
+}
+
+func (s *Server) tickersInfo(r *request) any {
+	symbol := bybit.Symbol(r.query.Get("symbol"))
+
+	res := bybit.MarketTickersRes{}
+	res.Result.Category = bybit.ProductType(r.query.Get("category"))
+
+	t, ok := s.tickers[symbol]
+	if !ok {
+		return res
+	}
+	res.Result.List = append(res.Result.List, struct {
+		Symbol      bybit.Symbol `json:"symbol"`
+		MarkPrice   bybit.Amount `json:"markPrice"`
+		FundingRate bybit.Amount `json:"fundingRate"`
+		Bid1Price   bybit.Amount `json:"bid1Price"`
+	}{
+		Symbol:      symbol,
+		MarkPrice:   t.MarkPrice,
+		FundingRate: t.FundingRate,
+		Bid1Price:   t.Bid1Price,
+	})
+	return res
+}
+
+func (s *Server) fundingHistory(r *request) any {
+	symbol := bybit.Symbol(r.query.Get("symbol"))
+	end, _ := strconv.ParseInt(r.query.Get("endTime"), 10, 64)
+
+	// Newer first.
+	vs := []bybit.FundingRate{}
+	for _, v := range slices.Backward(s.funding[symbol]) {
+		if end > 0 && v.FundingRateTimestamp.Time().UnixMilli() > end {
+			continue
+		}
+		vs = append(vs, v)
+	}
+	if v, err := strconv.Atoi(r.query.Get("limit")); err == nil && v > 0 && v < len(vs) {
+		vs = vs[:v]
+	}
+
+	res := bybit.MarketFundingHistoryRes{}
+	res.Result.Category = bybit.ProductType(r.query.Get("category"))
+	res.Result.List = vs
+	return res
+}
+
+// orderCreate fills the market order at once at the bid price.
+func (s *Server) orderCreate(r *request) any {
+	if e := r.deny("ContractTrade", r.key.perms.ContractTrade, "Order"); e != nil {
+		return *e
+	}
+
+	var req bybit.TradeOrderCreateApiReq
+	if err := r.decode(&req); err != nil {
+		return errorRes(RetCodeInvalidParams, err.Error())
+	}
+
+	t, ok := s.tickers[req.Symbol]
+	if !ok {
+		return errorRes(RetCodeInvalidParams, "symbol not found")
+	}
+	qty, err := strconv.ParseFloat(req.Quantity, 64)
+	if err != nil || qty <= 0 {
+		return errorRes(RetCodeInvalidParams, "invalid qty")
+	}
+	if req.OrderType != bybit.OrderTypeMarket {
+		return errorRes(RetCodeInvalidParams, "only market order is supported")
+	}
+	if req.Category == bybit.ProductTypeLinear && bybit.Amount(qty) < max(s.min_qtys[req.Symbol], s.qty_steps[req.Symbol]) {
+		return errorRes(RetCodeInvalidParams, "the number of contracts is below the minimum allowed")
+	}
+
+	// Inverse contract is margined by the coin.
+	if req.Category == bybit.ProductTypeInverse {
+		coin := bybit.Coin(req.Symbol[:len(req.Symbol)-len("USD")])
+		if s.balance(r.key.uid, bybit.AccountTypeUnified, coin)*t.Bid1Price < bybit.Amount(qty) {
+			return errorRes(RetCodeNotEnoughForOrder, "ab not enough for new order")
+		}
+	}
+
+	now := bybit.Timestamp(s.now())
+	order := bybit.Order{
+		OrderId:     s.nextOrderId(),
+		Category:    req.Category,
+		Symbol:      req.Symbol,
+		Side:        req.Side,
+		OrderStatus: bybit.OrderStatusFilled,
+		Price:       t.Bid1Price,
+		Qty:         bybit.Amount(qty),
+		CumExecQty:  bybit.Amount(qty),
+		AvgPrice:    t.Bid1Price,
+		CreatedTime: now,
+		UpdatedTime: now,
+	}
+	s.orders[r.key.uid] = append(s.orders[r.key.uid], order)
+	s.executions[r.key.uid] = append(s.executions[r.key.uid], bybit.Execution{
+		OrderId:   order.OrderId,
+		Category:  order.Category,
+		Symbol:    order.Symbol,
+		Side:      order.Side,
+		ExecPrice: order.AvgPrice,
+		ExecQty:   order.Qty,
+		ExecTime:  now,
+	})
+	s.publish(r.key.uid, []bybit.Order{order})
+
+	res := bybit.TradeOrderCreateApiRes{}
+	res.Result.OrderId = order.OrderId
+	return res
+}
+
+// orderAmend always fails since every order is filled on creation.
+func (s *Server) orderAmend(r *request) any {
+	if e := r.deny("ContractTrade", r.key.perms.ContractTrade, "Order"); e != nil {
+		return *e
+	}
+
+	var req bybit.TradeOrderAmendReq
+	if err := r.decode(&req); err != nil {
+		return errorRes(RetCodeInvalidParams, err.Error())
+	}
+	return errorRes(RetCodeOrderNotExists, "order not exists or too late to replace")
+}
+
+// orderCancel always fails since every order is filled on creation.
+func (s *Server) orderCancel(r *request) any {
+	if e := r.deny("ContractTrade", r.key.perms.ContractTrade, "Order"); e != nil {
+		return *e
+	}
+
+	var req bybit.TradeOrderCancelReq
+	if err := r.decode(&req); err != nil {
+		return errorRes(RetCodeInvalidParams, err.Error())
+	}
+	return errorRes(RetCodeOrderNotExists, "order not exists or too late to cancel")
+}
+
+// nextOrderId returns order IDs in sequence so outputs of tests are deterministic.
+func (s *Server) nextOrderId() string {
+	s.next_order++
+	return fmt.Sprintf("00000000-0000-0000-0000-%012d", s.next_order)
+}
+
+func (s *Server) orderHistory(r *request) any {
+	orders := []bybit.Order{}
+	for _, v := range slices.Backward(s.orders[r.key.uid]) {
+		if id := r.query.Get("orderId"); id != "" && v.OrderId != id {
+			continue
+		}
+		orders = append(orders, v)
+	}
+
+	size := 20
+	if v, err := strconv.Atoi(r.query.Get("limit")); err == nil && v > 0 {
+		size = v
+	}
+
+	res := bybit.TradeOrderHistoryRes{}
+	res.Result.List, res.Result.NextPageCursor = page(orders, r.query.Get("cursor"), size)
+	return res
+}
+
+func (s *Server) executionList(r *request) any {
+	executions := []bybit.Execution{}
+	for _, v := range slices.Backward(s.executions[r.key.uid]) {
+		if id := r.query.Get("orderId"); id != "" && v.OrderId != id {
+			continue
+		}
+		executions = append(executions, v)
+	}
+
+	size := 50
+	if v, err := strconv.Atoi(r.query.Get("limit")); err == nil && v > 0 {
+		size = v
+	}
+
+	res := bybit.TradeExecutionListRes{}
+	res.Result.Category = bybit.ProductType(r.query.Get("category"))
+	res.Result.List, res.Result.NextPageCursor = page(executions, r.query.Get("cursor"), size)
+	return res
+}
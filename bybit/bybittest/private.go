@@ -0,0 +1,190 @@
+package bybittest
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/websocket"
+	"github.com/lesomnus/tiny-short/bybit"
+	"github.com/lesomnus/tiny-short/bybit/ws"
+)
+
+// PrivateAddr returns the address of the private stream to be given to `ws.NewPrivateStream`.
+func (s *Server) PrivateAddr() string {
+	return "ws" + strings.TrimPrefix(s.URL, "http") + "/v5/private"
+}
+
+type privateConn struct {
+	conn *websocket.Conn
+	uid  bybit.UserId
+
+	mu     sync.Mutex
+	topics map[string]bool
+}
+
+type wsOp struct {
+	ReqId string `json:"req_id"`
+	Op    string `json:"op"`
+	Args  []any  `json:"args"`
+}
+
+func (c *privateConn) write(v any) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.conn.WriteJSON(v)
+}
+
+func (c *privateConn) ack(v wsOp, ok bool, msg string) error {
+	return c.write(map[string]any{
+		"success": ok,
+		"ret_msg": msg,
+		"conn_id": "bybittest",
+		"req_id":  v.ReqId,
+		"op":      v.Op,
+	})
+}
+
+func (c *privateConn) push(topic string, data any, ts int64) error {
+	c.mu.Lock()
+	ok := c.topics[topic]
+	c.mu.Unlock()
+	if !ok {
+		return nil
+	}
+
+	return c.write(map[string]any{
+		"id":           randomHex(8),
+		"topic":        topic,
+		"creationTime": ts,
+		"data":         data,
+	})
+}
+
+func (s *Server) servePrivate(w http.ResponseWriter, r *http.Request) {
+	upgrader := websocket.Upgrader{}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	c := &privateConn{conn: conn, topics: map[string]bool{}}
+	defer func() {
+		s.mu.Lock()
+		delete(s.streams, c)
+		s.mu.Unlock()
+	}()
+
+	for {
+		var v wsOp
+		if err := conn.ReadJSON(&v); err != nil {
+			return
+		}
+
+		switch v.Op {
+		case "ping":
+			c.write(map[string]any{"success": true, "ret_msg": "pong", "req_id": v.ReqId, "op": "pong"})
+
+		case "auth":
+			uid, err := s.authenticateStream(v.Args)
+			if err != nil {
+				c.ack(v, false, err.Error())
+				return
+			}
+
+			s.mu.Lock()
+			c.uid = uid
+			s.streams[c] = true
+			s.mu.Unlock()
+			c.ack(v, true, "")
+
+		case "subscribe":
+			if c.uid == 0 {
+				c.ack(v, false, "request not authorized")
+				continue
+			}
+
+			c.mu.Lock()
+			for _, arg := range v.Args {
+				if topic, ok := arg.(string); ok {
+					c.topics[topic] = true
+				}
+			}
+			c.mu.Unlock()
+			c.ack(v, true, "")
+
+		default:
+			c.ack(v, false, fmt.Sprintf("unknown op: %s", v.Op))
+		}
+	}
+}
+
+// authenticateStream verifies the arguments of "auth", which are the API key, expires, and signature.
+func (s *Server) authenticateStream(args []any) (bybit.UserId, error) {
+	if len(args) != 3 {
+		return 0, fmt.Errorf("invalid args")
+	}
+	api_key, _ := args[0].(string)
+	expires, _ := args[1].(float64)
+	signature, _ := args[2].(string)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k, ok := s.keys[api_key]
+	if !ok {
+		return 0, fmt.Errorf("API key is invalid")
+	}
+
+	payload := "GET/realtime" + strconv.FormatInt(int64(expires), 10)
+	if !k.verify([]byte(payload), signature) {
+		return 0, fmt.Errorf("error sign! origin_string[%s]", payload)
+	}
+	if s.now().UnixMilli() > int64(expires) {
+		return 0, fmt.Errorf("params error: expires is expired")
+	}
+
+	return k.uid, nil
+}
+
+// publish pushes the orders, their fills, and the unified wallet to the private streams of the account.
+// Must be called with the lock held.
+func (s *Server) publish(uid bybit.UserId, orders []bybit.Order) {
+	ts := s.now().UnixMilli()
+
+	executions := []bybit.Execution{}
+	for _, v := range s.executions[uid] {
+		if slices.ContainsFunc(orders, func(o bybit.Order) bool { return o.OrderId == v.OrderId }) {
+			executions = append(executions, v)
+		}
+	}
+
+	wallet := ws.Wallet{AccountType: bybit.AccountTypeUnified}
+	for coin, amount := range s.accounts[uid].balances[bybit.AccountTypeUnified] {
+		wallet.Coins = append(wallet.Coins, ws.WalletCoin{
+			Coin:                coin,
+			Equity:              amount,
+			WalletBalance:       amount,
+			AvailableToWithdraw: amount,
+		})
+	}
+	slices.SortFunc(wallet.Coins, func(a, b ws.WalletCoin) int {
+		return strings.Compare(string(a.Coin), string(b.Coin))
+	})
+
+	for c := range s.streams {
+		if c.uid != uid {
+			continue
+		}
+
+		// Wallet is pushed first so it is known when the order is closed.
+		// Write errors are noticed by the reader of the connection.
+		c.push("wallet", []ws.Wallet{wallet}, ts)
+		c.push("execution", executions, ts)
+		c.push("order", orders, ts)
+	}
+}
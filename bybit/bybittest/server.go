@@ -0,0 +1,463 @@
+// Package bybittest provides an in-process fake of Bybit v5 API for tests.
+// It keeps the main account and its sub accounts with their balances, transfers, orders, and fills,
+// and verifies signatures of requests made by HMAC or RSA keys.
+package bybittest
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/lesomnus/tiny-short/bybit"
+)
+
+// MainUserId is UID of the main account.
+const MainUserId = bybit.UserId(100000)
+
+// FullPermissions are permissions tiny-short requires for the main account.
+var FullPermissions = bybit.ApiPermissions{
+	ContractTrade: []string{"Order", "Position"},
+	Derivatives:   []string{"DerivativesTrade"},
+	Wallet:        []string{"AccountTransfer", "SubMemberTransfer"},
+}
+
+// Ret codes the fake responds with.
+const (
+	RetCodeInvalidApiKey       = 10003
+	RetCodeInvalidSign         = 10004
+	RetCodePermissionDenied    = 10005
+	RetCodeInvalidParams       = 10001
+	RetCodeInsufficientBalance = 131212
+	RetCodeNotEnoughForOrder   = 110007
+	RetCodeOrderNotExists      = 110001
+)
+
+// Failure is a scripted failure of an endpoint.
+type Failure struct {
+	RetCode int
+	RetMsg  string
+
+	// Status of the transfer responded with ret code 0.
+	// The transfer is not made unless it is `bybit.TransferStatusSuccess`.
+	Status bybit.TransferStatus
+
+	Times int // Number of requests to fail; 1 if 0.
+}
+
+// Transfer is a transfer requested.
+type Transfer struct {
+	Coin            bybit.Coin
+	Amount          bybit.Amount
+	FromMember      bybit.UserId
+	ToMember        bybit.UserId
+	FromAccountType bybit.AccountType
+	ToAccountType   bybit.AccountType
+	Status          bybit.TransferStatus
+}
+
+// Ticker is a state of the market of a symbol.
+type Ticker struct {
+	MarkPrice   bybit.Amount
+	Bid1Price   bybit.Amount
+	FundingRate bybit.Amount
+}
+
+type account struct {
+	member   bybit.SubMember
+	balances map[bybit.AccountType]map[bybit.Coin]bybit.Amount
+	logs     []bybit.TransactionLog
+}
+
+type apiKey struct {
+	uid        bybit.UserId
+	secret     bybit.SecretRecord
+	public_key *rsa.PublicKey // Set if the key is RSA.
+	perms      bybit.ApiPermissions
+	read_only  bool
+	note       string
+	created_at time.Time
+	expired_at time.Time
+}
+
+type Server struct {
+	*httptest.Server
+
+	mu         sync.Mutex
+	now        func() time.Time
+	next_uid   bybit.UserId
+	next_order int
+	accounts   map[bybit.UserId]*account
+	keys       map[string]*apiKey
+
+	tickers   map[bybit.Symbol]Ticker
+	qty_steps map[bybit.Symbol]bybit.Amount
+	min_qtys  map[bybit.Symbol]bybit.Amount
+	funding   map[bybit.Symbol][]bybit.FundingRate
+
+	failures   map[string][]*Failure
+	transfers  []Transfer
+	orders     map[bybit.UserId][]bybit.Order
+	executions map[bybit.UserId][]bybit.Execution
+
+	streams map[*privateConn]bool
+}
+
+// NewServer starts a fake server which has only the main account.
+// It must be closed by `Close`.
+func NewServer() *Server {
+	s := &Server{
+		now:      time.Now,
+		next_uid: MainUserId + 1,
+		accounts: map[bybit.UserId]*account{},
+		keys:     map[string]*apiKey{},
+
+		tickers:   map[bybit.Symbol]Ticker{},
+		qty_steps: map[bybit.Symbol]bybit.Amount{},
+		min_qtys:  map[bybit.Symbol]bybit.Amount{},
+		funding:   map[bybit.Symbol][]bybit.FundingRate{},
+
+		failures:   map[string][]*Failure{},
+		orders:     map[bybit.UserId][]bybit.Order{},
+		executions: map[bybit.UserId][]bybit.Execution{},
+
+		streams: map[*privateConn]bool{},
+	}
+	s.accounts[MainUserId] = &account{
+		member:   bybit.SubMember{UserId: MainUserId, Username: "main", Status: bybit.SubMemberStatusNormal},
+		balances: map[bybit.AccountType]map[bybit.Coin]bybit.Amount{},
+	}
+
+	mux := http.NewServeMux()
+	s.route(mux)
+	s.Server = httptest.NewServer(mux)
+
+	return s
+}
+
+// Endpoint returns the URL to be given to `bybit.WithNetwork`.
+func (s *Server) Endpoint() url.URL {
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		panic(err)
+	}
+	return *u
+}
+
+// Client returns a client that acts as the owner of the secret.
+func (s *Server) Client(secret bybit.SecretRecord) bybit.Client {
+	return bybit.NewClient(secret, bybit.WithNetwork(s.Endpoint()))
+}
+
+// SetNow replaces the clock of the server.
+func (s *Server) SetNow(now func() time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.now = now
+}
+
+// AddSubMember adds a sub account and returns its UID.
+func (s *Server) AddSubMember(username string, remark string) bybit.UserId {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	uid := s.next_uid
+	s.next_uid++
+	s.accounts[uid] = &account{
+		member: bybit.SubMember{
+			UserId:     uid,
+			Username:   username,
+			MemberType: 1,
+			Status:     bybit.SubMemberStatusNormal,
+			Remark:     remark,
+		},
+		balances: map[bybit.AccountType]map[bybit.Coin]bybit.Amount{},
+	}
+
+	return uid
+}
+
+// SetSubMemberStatus sets the status of the sub account, e.g. frozen.
+func (s *Server) SetSubMemberStatus(uid bybit.UserId, status bybit.SubMemberStatus) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.mustAccount(uid).member.Status = status
+}
+
+// AddKey registers the API key of the account.
+// The key expires in 90 days.
+func (s *Server) AddKey(uid bybit.UserId, secret bybit.SecretRecord, perms bybit.ApiPermissions) {
+	k := &apiKey{
+		uid:    uid,
+		secret: secret,
+		perms:  perms,
+	}
+	if secret.Type == bybit.SecretTypeRsa {
+		key, err := secret.Rsa()
+		if err != nil {
+			panic(fmt.Sprintf("invalid RSA key: %s", err))
+		}
+		k.public_key = &key.PublicKey
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.mustAccount(uid)
+	k.created_at = s.now()
+	k.expired_at = k.created_at.Add(90 * 24 * time.Hour)
+	s.keys[secret.ApiKey] = k
+}
+
+// Keys returns API keys of the account.
+func (s *Server) Keys(uid bybit.UserId) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	vs := []string{}
+	for api_key, k := range s.keys {
+		if k.uid == uid {
+			vs = append(vs, api_key)
+		}
+	}
+	slices.Sort(vs)
+	return vs
+}
+
+func (s *Server) SetBalance(uid bybit.UserId, account_type bybit.AccountType, coin bybit.Coin, amount bybit.Amount) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.setBalance(uid, account_type, coin, amount)
+}
+
+func (s *Server) Balance(uid bybit.UserId, account_type bybit.AccountType, coin bybit.Coin) bybit.Amount {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.balance(uid, account_type, coin)
+}
+
+func (s *Server) SetTicker(symbol bybit.Symbol, v Ticker) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tickers[symbol] = v
+}
+
+// SetQtyStep sets the qty step of the linear contract.
+func (s *Server) SetQtyStep(symbol bybit.Symbol, step bybit.Amount) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.qty_steps[symbol] = step
+}
+
+// SetMinOrderQty sets the min order qty of the linear contract.
+// It is the qty step if not set.
+func (s *Server) SetMinOrderQty(symbol bybit.Symbol, qty bybit.Amount) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.min_qtys[symbol] = qty
+}
+
+func (s *Server) AddFundingRate(symbol bybit.Symbol, rate bybit.Amount, at time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.funding[symbol] = append(s.funding[symbol], bybit.FundingRate{
+		Symbol:               symbol,
+		FundingRate:          rate,
+		FundingRateTimestamp: bybit.Timestamp(at),
+	})
+}
+
+// AddTransactionLog adds a log to the unified account, e.g. a settlement of funding.
+func (s *Server) AddTransactionLog(uid bybit.UserId, v bybit.TransactionLog) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a := s.mustAccount(uid)
+	a.logs = append(a.logs, v)
+}
+
+// Fail makes requests to the path fail as scripted, e.g.
+//
+//	s.Fail("/v5/asset/transfer/universal-transfer", Failure{Status: bybit.TransferStatusPending})
+//
+// Failures of the same path are applied in order.
+func (s *Server) Fail(path string, f Failure) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if f.Times == 0 {
+		f.Times = 1
+	}
+	s.failures[path] = append(s.failures[path], &f)
+}
+
+// Transfers returns transfers requested so far including the ones not made.
+func (s *Server) Transfers() []Transfer {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.transfers)
+}
+
+// Orders returns orders placed by the account.
+func (s *Server) Orders(uid bybit.UserId) []bybit.Order {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.orders[uid])
+}
+
+func (s *Server) mustAccount(uid bybit.UserId) *account {
+	a, ok := s.accounts[uid]
+	if !ok {
+		panic(fmt.Sprintf("account not found: %s", uid))
+	}
+	return a
+}
+
+func (s *Server) setBalance(uid bybit.UserId, account_type bybit.AccountType, coin bybit.Coin, amount bybit.Amount) {
+	a := s.mustAccount(uid)
+	b, ok := a.balances[account_type]
+	if !ok {
+		b = map[bybit.Coin]bybit.Amount{}
+		a.balances[account_type] = b
+	}
+	b[coin] = amount
+}
+
+func (s *Server) balance(uid bybit.UserId, account_type bybit.AccountType, coin bybit.Coin) bybit.Amount {
+	a, ok := s.accounts[uid]
+	if !ok {
+		return 0
+	}
+	return a.balances[account_type][coin]
+}
+
+// fail pops the scripted failure of the path.
+func (s *Server) fail(path string) *Failure {
+	fs := s.failures[path]
+	if len(fs) == 0 {
+		return nil
+	}
+
+	f := fs[0]
+	f.Times--
+	if f.Times <= 0 {
+		s.failures[path] = fs[1:]
+	}
+	return f
+}
+
+type request struct {
+	key     *apiKey // nil for public endpoints.
+	query   url.Values
+	body    []byte
+	failure *Failure // Scripted failure that is not a ret code.
+}
+
+func (r *request) decode(v any) error {
+	return json.Unmarshal(r.body, v)
+}
+
+// handler returns a response, which is one of `bybit.Xxx` responses.
+type handler func(r *request) any
+
+func errorRes(code int, msg string) bybit.ResponseBase {
+	return bybit.ResponseBase{RetCode: code, RetMsg: msg}
+}
+
+func (s *Server) handle(mux *http.ServeMux, pattern string, public bool, h handler) {
+	mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		var res any
+		k, base := s.authenticate(r, body)
+		switch {
+		case !public && k == nil:
+			res = base
+		case k != nil && k.read_only && r.Method == http.MethodPost:
+			res = errorRes(RetCodePermissionDenied, "permission denied for read-only API key")
+		default:
+			f := s.fail(r.URL.Path)
+			if f != nil && f.RetCode != 0 {
+				res = errorRes(f.RetCode, f.RetMsg)
+				break
+			}
+
+			res = h(&request{key: k, query: r.URL.Query(), body: body, failure: f})
+		}
+
+		data, err := json.Marshal(res)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	})
+}
+
+// authenticate returns the API key if the request is signed by it.
+func (s *Server) authenticate(r *http.Request, body []byte) (*apiKey, bybit.ResponseBase) {
+	api_key := r.Header.Get("X-BAPI-API-KEY")
+	k, ok := s.keys[api_key]
+	if !ok {
+		return nil, errorRes(RetCodeInvalidApiKey, "API key is invalid.")
+	}
+
+	payload := r.URL.RawQuery
+	if r.Method == http.MethodPost {
+		payload = string(body)
+	}
+	payload = r.Header.Get("X-BAPI-TIMESTAMP") + api_key + r.Header.Get("X-BAPI-RECV-WINDOW") + payload
+	if !k.verify([]byte(payload), r.Header.Get("X-BAPI-SIGN")) {
+		return nil, errorRes(RetCodeInvalidSign, "error sign! origin_string["+payload+"]")
+	}
+	if s.now().After(k.expired_at) {
+		return nil, errorRes(RetCodeInvalidApiKey, "API key is expired.")
+	}
+
+	return k, bybit.ResponseBase{}
+}
+
+func (k *apiKey) verify(payload []byte, signature string) bool {
+	if k.public_key == nil {
+		h := hmac.New(sha256.New, []byte(k.secret.Secret))
+		h.Write(payload)
+		return hmac.Equal([]byte(hex.EncodeToString(h.Sum(nil))), []byte(signature))
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+
+	hash := sha256.Sum256(payload)
+	return rsa.VerifyPKCS1v15(k.public_key, crypto.SHA256, hash[:], sig) == nil
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := io.ReadFull(rand.Reader, b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,221 @@
+package bybittest_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lesomnus/tiny-short/bybit"
+	"github.com/lesomnus/tiny-short/bybit/bybittest"
+	"github.com/lesomnus/tiny-short/bybit/ws"
+	"github.com/stretchr/testify/require"
+)
+
+func hmacSecret(api_key string) bybit.SecretRecord {
+	return bybit.SecretRecord{Type: bybit.SecretTypeHmac, ApiKey: api_key, Secret: "secret-of-" + api_key}
+}
+
+func rsaSecret(t *testing.T, api_key string) bybit.SecretRecord {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	data := pem.EncodeToMemory(&pem.Block{Type: bybit.PemTypeRsaPrivateKey, Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	return bybit.SecretRecord{Type: bybit.SecretTypeRsa, ApiKey: api_key, Secret: string(data)}
+}
+
+func universalTransfer(from bybit.UserId, to bybit.UserId, amount string) bybit.AssetUniversalTransferReq {
+	return bybit.AssetUniversalTransferReq{
+		TransferId:      bybit.TransferId(uuid.New()),
+		Coin:            "BTC",
+		Amount:          amount,
+		FromMember:      from,
+		ToMember:        to,
+		FromAccountType: bybit.AccountTypeUnified,
+		ToAccountType:   bybit.AccountTypeUnified,
+	}
+}
+
+func TestServer(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("signatures", func(t *testing.T) {
+		s := bybittest.NewServer()
+		defer s.Close()
+
+		for name, secret := range map[string]bybit.SecretRecord{
+			"hmac": hmacSecret("main-hmac"),
+			"rsa":  rsaSecret(t, "main-rsa"),
+		} {
+			t.Run(name, func(t *testing.T) {
+				require := require.New(t)
+
+				s.AddKey(bybittest.MainUserId, secret, bybittest.FullPermissions)
+				res, err := s.Client(secret).User().QueryApi(ctx, bybit.UserQueryApiReq{})
+				require.NoError(err)
+				require.True(res.Ok(), res.RetMsg)
+				require.Equal(bybittest.MainUserId, res.Result.UserId)
+				require.True(res.Result.IsMaster)
+
+				secret.Secret = "wrong"
+				if secret.Type == bybit.SecretTypeRsa {
+					secret = rsaSecret(t, secret.ApiKey)
+				}
+				res, err = s.Client(secret).User().QueryApi(ctx, bybit.UserQueryApiReq{})
+				require.NoError(err)
+				require.Equal(bybittest.RetCodeInvalidSign, res.RetCode)
+			})
+		}
+	})
+
+	t.Run("transfers", func(t *testing.T) {
+		require := require.New(t)
+
+		s := bybittest.NewServer()
+		defer s.Close()
+
+		secret := hmacSecret("main")
+		s.AddKey(bybittest.MainUserId, secret, bybittest.FullPermissions)
+		sub := s.AddSubMember("foo", "")
+		s.SetBalance(sub, bybit.AccountTypeUnified, "BTC", 1)
+
+		c := s.Client(secret)
+		res, err := c.Asset().UniversalTransfer(ctx, universalTransfer(sub, bybittest.MainUserId, "0.4"))
+		require.NoError(err)
+		require.True(res.Ok(), res.RetMsg)
+		require.Equal(bybit.TransferStatusSuccess, res.Result.Status)
+		require.InDelta(0.6, float64(s.Balance(sub, bybit.AccountTypeUnified, "BTC")), 1e-9)
+		require.InDelta(0.4, float64(s.Balance(bybittest.MainUserId, bybit.AccountTypeUnified, "BTC")), 1e-9)
+
+		res, err = c.Asset().UniversalTransfer(ctx, universalTransfer(sub, bybittest.MainUserId, "1"))
+		require.NoError(err)
+		require.Equal(bybittest.RetCodeInsufficientBalance, res.RetCode)
+		require.Len(s.Transfers(), 1)
+	})
+
+	t.Run("permissions", func(t *testing.T) {
+		require := require.New(t)
+
+		s := bybittest.NewServer()
+		defer s.Close()
+
+		secret := hmacSecret("main")
+		s.AddKey(bybittest.MainUserId, secret, bybit.ApiPermissions{
+			ContractTrade: []string{"Order", "Position"},
+		})
+		sub := s.AddSubMember("foo", "")
+		s.SetBalance(sub, bybit.AccountTypeUnified, "BTC", 1)
+		s.SetBalance(bybittest.MainUserId, bybit.AccountTypeFund, "BTC", 1)
+
+		c := s.Client(secret)
+		res, err := c.Asset().UniversalTransfer(ctx, universalTransfer(sub, bybittest.MainUserId, "0.5"))
+		require.NoError(err)
+		require.Equal(bybittest.RetCodePermissionDenied, res.RetCode)
+		require.Contains(res.RetMsg, "SubMemberTransfer")
+
+		inter, err := c.Asset().InterTransfer(ctx, bybit.AssetInterTransferReq{
+			TransferId:      bybit.TransferId(uuid.New()),
+			Coin:            "BTC",
+			Amount:          "0.5",
+			FromAccountType: bybit.AccountTypeFund,
+			ToAccountType:   bybit.AccountTypeUnified,
+		})
+		require.NoError(err)
+		require.Equal(bybittest.RetCodePermissionDenied, inter.RetCode)
+		require.Contains(inter.RetMsg, "AccountTransfer")
+
+		require.Empty(s.Transfers())
+		require.Equal(bybit.Amount(1), s.Balance(sub, bybit.AccountTypeUnified, "BTC"))
+		require.Equal(bybit.Amount(1), s.Balance(bybittest.MainUserId, bybit.AccountTypeFund, "BTC"))
+	})
+
+	t.Run("scripted failures", func(t *testing.T) {
+		require := require.New(t)
+
+		s := bybittest.NewServer()
+		defer s.Close()
+
+		secret := hmacSecret("main")
+		s.AddKey(bybittest.MainUserId, secret, bybittest.FullPermissions)
+		sub := s.AddSubMember("foo", "")
+		s.SetBalance(sub, bybit.AccountTypeUnified, "BTC", 1)
+
+		const path = "/v5/asset/transfer/universal-transfer"
+		s.Fail(path, bybittest.Failure{Status: bybit.TransferStatusPending})
+		s.Fail(path, bybittest.Failure{RetCode: bybit.RetCodeUnacceptableAmountAccuracy, RetMsg: "Amount accuracy error"})
+		s.Fail(path, bybittest.Failure{RetCode: bybit.RetCodeTooManyVisits, RetMsg: "Too many visits!", Times: 2})
+
+		c := s.Client(secret)
+		res, err := c.Asset().UniversalTransfer(ctx, universalTransfer(sub, bybittest.MainUserId, "0.5"))
+		require.NoError(err)
+		require.True(res.Ok(), res.RetMsg)
+		require.Equal(bybit.TransferStatusPending, res.Result.Status)
+		require.Equal(bybit.Amount(1), s.Balance(sub, bybit.AccountTypeUnified, "BTC"))
+
+		for _, code := range []int{bybit.RetCodeUnacceptableAmountAccuracy, bybit.RetCodeTooManyVisits, bybit.RetCodeTooManyVisits} {
+			res, err = c.Asset().UniversalTransfer(ctx, universalTransfer(sub, bybittest.MainUserId, "0.5"))
+			require.NoError(err)
+			require.Equal(code, res.RetCode)
+		}
+
+		res, err = c.Asset().UniversalTransfer(ctx, universalTransfer(sub, bybittest.MainUserId, "0.5"))
+		require.NoError(err)
+		require.True(res.Ok(), res.RetMsg)
+		require.Equal(bybit.Amount(0.5), s.Balance(sub, bybit.AccountTypeUnified, "BTC"))
+	})
+
+	t.Run("orders are pushed to private stream", func(t *testing.T) {
+		require := require.New(t)
+
+		s := bybittest.NewServer()
+		defer s.Close()
+
+		secret := rsaSecret(t, "sub")
+		sub := s.AddSubMember("foo", "")
+		s.AddKey(sub, secret, bybittest.FullPermissions)
+		s.SetBalance(sub, bybit.AccountTypeUnified, "BTC", 1)
+		s.SetTicker("BTCUSD", bybittest.Ticker{MarkPrice: 42000, Bid1Price: 42000})
+
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+
+		stream := ws.NewPrivateStream(s.PrivateAddr(), secret)
+		orders := make(chan []bybit.Order, 1)
+		require.NoError(stream.SubscribeOrders(ctx, func(vs []bybit.Order) { orders <- vs }))
+		go stream.Run(ctx)
+		require.NoError(stream.Ready(ctx))
+
+		res, err := s.Client(secret).Trade().OrderCreate(ctx, bybit.TradeOrderCreateApiReq{
+			Category:  bybit.ProductTypeInverse,
+			Symbol:    "BTCUSD",
+			Side:      bybit.OrderSideSell,
+			OrderType: bybit.OrderTypeMarket,
+			Quantity:  "42000",
+		})
+		require.NoError(err)
+		require.True(res.Ok(), res.RetMsg)
+
+		select {
+		case <-ctx.Done():
+			require.FailNow("order not pushed")
+		case vs := <-orders:
+			require.Len(vs, 1)
+			require.Equal(res.Result.OrderId, vs[0].OrderId)
+			require.Equal(bybit.OrderStatusFilled, vs[0].OrderStatus)
+		}
+
+		res, err = s.Client(secret).Trade().OrderCreate(ctx, bybit.TradeOrderCreateApiReq{
+			Category:  bybit.ProductTypeInverse,
+			Symbol:    "BTCUSD",
+			Side:      bybit.OrderSideSell,
+			OrderType: bybit.OrderTypeMarket,
+			Quantity:  "50000",
+		})
+		require.NoError(err)
+		require.Equal(bybittest.RetCodeNotEnoughForOrder, res.RetCode)
+	})
+}
@@ -0,0 +1,279 @@
+// Package cassette records HTTP interactions with Bybit into a file and replays them.
+//
+// Headers are not recorded, so the API key and signatures of requests never be in a cassette.
+// Values of "apiKey" and "secret" in bodies are replaced with placeholders;
+// the same value is replaced with the same placeholder so they can still be compared.
+package cassette
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Interaction is a pair of a request and its response.
+type Interaction struct {
+	Method  string          `json:"method"`
+	Path    string          `json:"path"`
+	Query   string          `json:"query,omitempty"`
+	Request json.RawMessage `json:"request,omitempty"` // Body of POST.
+
+	Status   int             `json:"status"`
+	Response json.RawMessage `json:"response"` // Body; a JSON string if it is not JSON.
+}
+
+type Cassette struct {
+	// Notes are states of the application at the recording that are needed to replay it,
+	// e.g. what was in local files.
+	Notes map[string]json.RawMessage `json:"notes,omitempty"`
+
+	Interactions []Interaction `json:"interactions"`
+}
+
+func Load(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Cassette{}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("unmarshal: %w", err)
+	}
+	return c, nil
+}
+
+func (c *Cassette) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "\t")
+	if err != nil {
+		return fmt.Errorf("marshal: %w", err)
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// Recorder is a transport that records interactions made through it.
+type Recorder struct {
+	Transport http.RoundTripper // `http.DefaultTransport` if nil.
+
+	mu       sync.Mutex
+	cassette Cassette
+	redactor redactor
+}
+
+func NewRecorder() *Recorder {
+	return &Recorder{redactor: redactor{}}
+}
+
+// Cassette returns notes and interactions recorded so far.
+func (r *Recorder) Cassette() *Cassette {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c := &Cassette{
+		Notes:        maps.Clone(r.cassette.Notes),
+		Interactions: slices.Clone(r.cassette.Interactions),
+	}
+	return c
+}
+
+// Note sets the note of the key by JSON of the value.
+func (r *Recorder) Note(key string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("marshal note %s: %w", key, err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.cassette.Notes == nil {
+		r.cassette.Notes = map[string]json.RawMessage{}
+	}
+	r.cassette.Notes[key] = data
+	return nil
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var req_body []byte
+	if req.Body != nil {
+		data, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("read request body: %w", err)
+		}
+		req_body = data
+		req.Body = io.NopCloser(bytes.NewReader(data))
+	}
+
+	t := r.Transport
+	if t == nil {
+		t = http.DefaultTransport
+	}
+	res, err := t.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	res_body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("read response body: %w", err)
+	}
+	res.Body = io.NopCloser(bytes.NewReader(res_body))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	v := Interaction{
+		Method:   req.Method,
+		Path:     req.URL.Path,
+		Query:    req.URL.RawQuery,
+		Status:   res.StatusCode,
+		Response: r.redactor.redact(res_body),
+	}
+	if len(req_body) > 0 {
+		v.Request = r.redactor.redact(req_body)
+	}
+	r.cassette.Interactions = append(r.cassette.Interactions, v)
+
+	return res, nil
+}
+
+// Player is a transport that serves responses of the cassette in order.
+// A request must have the same method and path as the recorded one;
+// queries and bodies are not compared since they have timestamps.
+type Player struct {
+	mu       sync.Mutex
+	cassette *Cassette
+	next     int
+}
+
+func NewPlayer(c *Cassette) *Player {
+	return &Player{cassette: c}
+}
+
+// Note decodes the note of the key into the value.
+// It returns false if there is no such note.
+func (p *Player) Note(key string, v any) (bool, error) {
+	data, ok := p.cassette.Notes[key]
+	if !ok {
+		return false, nil
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return false, fmt.Errorf("unmarshal note %s: %w", key, err)
+	}
+	return true, nil
+}
+
+// Remaining returns the number of interactions not replayed yet.
+func (p *Player) Remaining() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.cassette.Interactions) - p.next
+}
+
+func (p *Player) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.next >= len(p.cassette.Interactions) {
+		return nil, fmt.Errorf("cassette exhausted: %s %s", req.Method, req.URL.Path)
+	}
+
+	v := p.cassette.Interactions[p.next]
+	if v.Method != req.Method || v.Path != req.URL.Path {
+		return nil, fmt.Errorf("cassette mismatch at #%d: expected %s %s but %s %s", p.next, v.Method, v.Path, req.Method, req.URL.Path)
+	}
+	p.next++
+
+	body := []byte(v.Response)
+	var text string
+	if json.Unmarshal(body, &text) == nil {
+		body = []byte(text)
+	}
+
+	return &http.Response{
+		Status:        strconv.Itoa(v.Status) + " " + http.StatusText(v.Status),
+		StatusCode:    v.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"application/json"}},
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// redactor replaces secrets with placeholders.
+// It maps a secret to the same placeholder.
+type redactor map[string]string
+
+// redact returns the body with secrets redacted.
+// Body that is not JSON is returned as a JSON string as it is.
+func (r redactor) redact(body []byte) json.RawMessage {
+	d := json.NewDecoder(bytes.NewReader(body))
+	d.UseNumber()
+
+	var v any
+	if err := d.Decode(&v); err != nil {
+		data, _ := json.Marshal(string(body))
+		return data
+	}
+
+	data, err := json.Marshal(r.walk(v))
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+func (r redactor) walk(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		// In order so that placeholders are deterministic.
+		for _, k := range slices.Sorted(maps.Keys(v)) {
+			u := v[k]
+			s, ok := u.(string)
+			if ok && s != "" && isSecretKey(k) {
+				v[k] = r.placeholder(s)
+			} else {
+				v[k] = r.walk(u)
+			}
+		}
+	case []any:
+		for i, u := range v {
+			v[i] = r.walk(u)
+		}
+	}
+	return v
+}
+
+func (r redactor) placeholder(s string) string {
+	p, ok := r[s]
+	if !ok {
+		p = fmt.Sprintf("REDACTED-%d", len(r)+1)
+		r[s] = p
+	}
+	return p
+}
+
+func isSecretKey(k string) bool {
+	switch strings.ToLower(k) {
+	case "apikey", "secret":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,83 @@
+package cassette_test
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lesomnus/tiny-short/bybit"
+	"github.com/lesomnus/tiny-short/bybit/bybittest"
+	"github.com/lesomnus/tiny-short/bybit/cassette"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCassette(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	s := bybittest.NewServer()
+	defer s.Close()
+
+	secret := bybit.SecretRecord{Type: bybit.SecretTypeHmac, ApiKey: "main-key", Secret: "main-secret"}
+	s.AddKey(bybittest.MainUserId, secret, bybittest.FullPermissions)
+	sub := s.AddSubMember("foo", "")
+
+	r := cassette.NewRecorder()
+	c := bybit.NewClient(secret, bybit.WithNetwork(s.Endpoint()), bybit.WithHttpClient(&http.Client{Transport: r}))
+
+	created, err := c.User().CreateSubApiKey(ctx, bybit.UserCreateSubApiKeyReq{SubUserId: sub})
+	require.NoError(err)
+	require.True(created.Ok(), created.RetMsg)
+
+	keys, err := c.User().SubApiKeys(ctx, bybit.UserSubApiKeysReq{SubUserId: sub})
+	require.NoError(err)
+	require.True(keys.Ok(), keys.RetMsg)
+
+	p := filepath.Join(t.TempDir(), "cassette.json")
+	require.NoError(r.Note("foo", []int{42}))
+	require.NoError(r.Cassette().Save(p))
+
+	data, err := os.ReadFile(p)
+	require.NoError(err)
+	require.NotContains(string(data), "main-key")
+	require.NotContains(string(data), created.Result.ApiKey)
+	require.NotContains(string(data), created.Result.Secret)
+
+	// Server is not needed to replay.
+	s.Close()
+
+	loaded, err := cassette.Load(p)
+	require.NoError(err)
+	require.Len(loaded.Interactions, 2)
+
+	player := cassette.NewPlayer(loaded)
+	c = bybit.NewClient(secret, bybit.WithNetwork(s.Endpoint()), bybit.WithHttpClient(&http.Client{Transport: player}))
+
+	v := []int{}
+	ok, err := player.Note("foo", &v)
+	require.NoError(err)
+	require.True(ok)
+	require.Equal([]int{42}, v)
+
+	replayed, err := c.User().CreateSubApiKey(ctx, bybit.UserCreateSubApiKeyReq{SubUserId: sub})
+	require.NoError(err)
+	require.True(replayed.Ok())
+	require.Equal("REDACTED-1", replayed.Result.ApiKey)
+	require.Equal("REDACTED-2", replayed.Result.Secret)
+
+	_, err = c.Trade().OrderHistory(ctx, bybit.TradeOrderHistoryReq{})
+	require.ErrorContains(err, "cassette mismatch at #1")
+
+	keys, err = c.User().SubApiKeys(ctx, bybit.UserSubApiKeysReq{SubUserId: sub})
+	require.NoError(err)
+	require.Len(keys.Result.List, 1)
+
+	// Same secret is redacted into the same placeholder.
+	require.Equal(replayed.Result.ApiKey, keys.Result.List[0].ApiKey)
+	require.Equal(0, player.Remaining())
+
+	_, err = c.User().SubApiKeys(ctx, bybit.UserSubApiKeysReq{SubUserId: sub})
+	require.ErrorContains(err, "cassette exhausted")
+}
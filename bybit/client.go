@@ -29,10 +29,12 @@ type Client interface {
 type client struct {
 	conf   clientConfig
 	secret SecretRecord
+	signer Signer
 }
 
 type clientConfig struct {
 	endpoint Endpoint
+	http     *http.Client
 }
 
 type ClientOption = func(c *clientConfig)
@@ -44,6 +46,13 @@ func WithNetwork(u url.URL) ClientOption {
 	}
 }
 
+// WithHttpClient sets the HTTP client requests are made by, e.g. to record or replay them.
+func WithHttpClient(h *http.Client) ClientOption {
+	return func(c *clientConfig) {
+		c.http = h
+	}
+}
+
 func NewClient(secret SecretRecord, opts ...ClientOption) Client {
 	testnet, err := url.Parse(TestNetAddr1)
 	if err != nil {
@@ -52,6 +61,7 @@ func NewClient(secret SecretRecord, opts ...ClientOption) Client {
 
 	c := clientConfig{
 		endpoint: Endpoint(*testnet),
+		http:     http.DefaultClient,
 	}
 	for _, opt := range opts {
 		opt(&c)
@@ -60,12 +70,14 @@ func NewClient(secret SecretRecord, opts ...ClientOption) Client {
 	return &client{
 		conf:   c,
 		secret: secret,
+		signer: &lazySigner{secret: secret},
 	}
 }
 
 func (c *client) Clone(secret SecretRecord) Client {
 	c_ := *c
 	c_.secret = secret
+	c_.signer = &lazySigner{secret: secret}
 	return &c_
 }
 
@@ -101,7 +113,7 @@ func (c *client) makeReq(ctx context.Context, method string, url string, data []
 	w.Write(data)
 
 	payload := w.Bytes()
-	signature, err := c.secret.Sign(payload)
+	signature, err := c.signer.Sign(ctx, payload)
 	if err != nil {
 		return nil, fmt.Errorf("sign: %w", err)
 	}
@@ -156,7 +168,7 @@ func (c *client) exec(ctx context.Context, method string, url string, data []byt
 		return fmt.Errorf("make req: %w", err)
 	}
 
-	res_, err := http.DefaultClient.Do(req)
+	res_, err := c.conf.http.Do(req)
 	if err != nil {
 		return fmt.Errorf("roundtrip: %w", err)
 	}
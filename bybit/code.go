@@ -2,5 +2,6 @@ package bybit
 
 const (
 	RetCodeOk                         = 0
+	RetCodeTooManyVisits              = 10006
 	RetCodeUnacceptableAmountAccuracy = 131210
 )
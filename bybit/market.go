@@ -1,6 +1,10 @@
 package bybit
 
-import "context"
+import (
+	"context"
+	"strconv"
+	"time"
+)
 
 type MarketApi interface {
 	InstrumentsInfo(ctx context.Context, req MarketInstrumentsInfoReq) (MarketInstrumentsInfoRes, error)
@@ -21,7 +25,8 @@ type MarketInstrumentsInfoRes struct {
 			ContractType  ContractType `json:"contractType"`
 			PriceScale    string       `json:"priceScale"`
 			LotSizeFilter struct {
-				QtyStep Amount `json:"qtyStep"`
+				QtyStep     Amount `json:"qtyStep"`
+				MinOrderQty Amount `json:"minOrderQty"`
 			} `json:"lotSizeFilter"`
 		} `json:"list"`
 	} `json:"result"`
@@ -48,7 +53,7 @@ type MarketTickersRes struct {
 type MarketFundingHistoryReq struct {
 	Category  ProductType `url:"category"`
 	Symbol    Symbol      `url:"symbol"`
-	StartTime Timestamp   `utl:"startTime"`
+	StartTime Timestamp   `url:"startTime"`
 	EndTime   Timestamp   `url:"endTime"`
 	Limit     uint        `url:"limit"`
 }
@@ -56,15 +61,36 @@ type MarketFundingHistoryRes struct {
 	ResponseBase `json:",inline"`
 
 	Result struct {
-		Category ProductType `json:"category"`
-		List     []struct {
-			Symbol               Symbol    `json:"symbol"`
-			FundingRate          Amount    `json:"fundingRate"`
-			FundingRateTimestamp Timestamp `json:"fundingRateTimestamp"`
-		} `json:"list"`
+		Category ProductType   `json:"category"`
+		List     []FundingRate `json:"list"` // Newer first.
 	} `json:"result"`
 }
 
+// WithCursor queries the page ends at the cursor, which is a timestamp in milliseconds,
+// since the endpoint is paginated by `EndTime` instead of a cursor.
+func (r MarketFundingHistoryReq) WithCursor(cursor string) MarketFundingHistoryReq {
+	if v, err := strconv.ParseInt(cursor, 10, 64); err == nil {
+		r.EndTime = Timestamp(time.UnixMilli(v))
+	}
+	return r
+}
+
+// Page returns the timestamp just before the oldest one in the page as the cursor of the next page.
+func (r *MarketFundingHistoryRes) Page() ([]FundingRate, string) {
+	if len(r.Result.List) == 0 {
+		return r.Result.List, ""
+	}
+
+	oldest := time.Time(r.Result.List[len(r.Result.List)-1].FundingRateTimestamp)
+	return r.Result.List, strconv.FormatInt(oldest.UnixMilli()-1, 10)
+}
+
+type FundingRate struct {
+	Symbol               Symbol    `json:"symbol"`
+	FundingRate          Amount    `json:"fundingRate"`
+	FundingRateTimestamp Timestamp `json:"fundingRateTimestamp"`
+}
+
 type marketApi struct {
 	client *client
 }
@@ -0,0 +1,150 @@
+package bybit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"time"
+)
+
+// PagedReq is a request of a cursor-based list endpoint.
+type PagedReq[Req any] interface {
+	// WithCursor returns a copy of the request that queries the page at the cursor.
+	WithCursor(cursor string) Req
+}
+
+// PagedRes is a response of a cursor-based list endpoint.
+type PagedRes[T any] interface {
+	Ok() bool
+	Err() error
+
+	// Page returns items in the page and the cursor of the next page.
+	// The cursor is empty if it is the last page.
+	Page() ([]T, string)
+}
+
+type pagesConfig struct {
+	interval time.Duration
+	backoff  time.Duration
+	retries  int
+}
+
+type PagesOption = func(c *pagesConfig)
+
+// WithPageInterval sets the minimum interval between requests of pages.
+func WithPageInterval(d time.Duration) PagesOption {
+	return func(c *pagesConfig) {
+		c.interval = d
+	}
+}
+
+// WithRateLimitRetry retries the request of a page up to `retries` times
+// if it is rejected by the rate limit. The wait starts from `backoff` and doubles on every retry.
+func WithRateLimitRetry(retries int, backoff time.Duration) PagesOption {
+	return func(c *pagesConfig) {
+		c.retries = retries
+		c.backoff = backoff
+	}
+}
+
+// Pages iterates items of every page of a cursor-based list endpoint starting from `req`.
+// The iteration stops at the first error, which is yielded with the zero value of the item.
+// The type of items must be given explicitly, e.g.
+//
+//	for v, err := range Pages[Order](ctx, client.Trade().OrderHistory, TradeOrderHistoryReq{Category: ProductTypeInverse}) {
+//		...
+//	}
+func Pages[T any, Req PagedReq[Req], Res any, PRes interface {
+	*Res
+	PagedRes[T]
+}](ctx context.Context, fetch func(ctx context.Context, req Req) (Res, error), req Req, opts ...PagesOption) iter.Seq2[T, error] {
+	c := pagesConfig{
+		retries: 3,
+		backoff: time.Second,
+	}
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	return func(yield func(T, error) bool) {
+		var zero T
+		last := time.Time{}
+		for {
+			var page PRes
+			for i := 0; ; i++ {
+				if err := sleep(ctx, c.interval-time.Since(last)); err != nil {
+					yield(zero, err)
+					return
+				}
+				last = time.Now()
+
+				res, err := fetch(ctx, req)
+				if err != nil {
+					yield(zero, fmt.Errorf("request: %w", err))
+					return
+				}
+
+				page = PRes(&res)
+				if page.Ok() {
+					break
+				}
+
+				err = page.Err()
+				if e := (*ResponseError)(nil); !errors.As(err, &e) || e.Code != RetCodeTooManyVisits || i >= c.retries {
+					yield(zero, err)
+					return
+				}
+				if err := sleep(ctx, c.backoff<<i); err != nil {
+					yield(zero, err)
+					return
+				}
+			}
+
+			vs, cursor := page.Page()
+			for _, v := range vs {
+				if !yield(v, nil) {
+					return
+				}
+			}
+			if cursor == "" || len(vs) == 0 {
+				return
+			}
+
+			req = req.WithCursor(cursor)
+		}
+	}
+}
+
+// CollectPages fetches every page of a cursor-based list endpoint starting from `req`.
+// See `Pages`.
+func CollectPages[T any, Req PagedReq[Req], Res any, PRes interface {
+	*Res
+	PagedRes[T]
+}](ctx context.Context, fetch func(ctx context.Context, req Req) (Res, error), req Req, opts ...PagesOption) ([]T, error) {
+	items := []T{}
+	for v, err := range Pages[T, Req, Res, PRes](ctx, fetch, req, opts...) {
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, v)
+	}
+
+	return items, nil
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
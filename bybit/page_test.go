@@ -0,0 +1,158 @@
+package bybit_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/lesomnus/tiny-short/bybit"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollectPages(t *testing.T) {
+	pages := map[string]bybit.UserSubMembersRes{}
+	for cursor, v := range map[string]struct {
+		usernames []string
+		next      string
+	}{
+		"":  {[]string{"foo", "bar"}, "a"},
+		"a": {[]string{"baz"}, "b"},
+		"b": {[]string{"qux"}, "0"},
+	} {
+		res := bybit.UserSubMembersRes{}
+		for _, u := range v.usernames {
+			res.Result.SubMembers = append(res.Result.SubMembers, bybit.SubMember{Username: u})
+		}
+		res.Result.NextCursor = v.next
+		pages[cursor] = res
+	}
+
+	t.Run("every page", func(t *testing.T) {
+		require := require.New(t)
+
+		reqs := []bybit.UserSubMembersReq{}
+		members, err := bybit.CollectPages[bybit.SubMember](context.Background(), func(ctx context.Context, req bybit.UserSubMembersReq) (bybit.UserSubMembersRes, error) {
+			reqs = append(reqs, req)
+			return pages[req.NextCursor], nil
+		}, bybit.UserSubMembersReq{PageSize: 2})
+		require.NoError(err)
+
+		usernames := []string{}
+		for _, m := range members {
+			usernames = append(usernames, m.Username)
+		}
+		require.Equal([]string{"foo", "bar", "baz", "qux"}, usernames)
+		require.Len(reqs, 3)
+		for _, req := range reqs {
+			require.Equal(uint(2), req.PageSize)
+		}
+	})
+
+	t.Run("response error", func(t *testing.T) {
+		require := require.New(t)
+
+		_, err := bybit.CollectPages[bybit.SubMember](context.Background(), func(ctx context.Context, req bybit.UserSubMembersReq) (bybit.UserSubMembersRes, error) {
+			if req.NextCursor == "b" {
+				res := bybit.UserSubMembersRes{}
+				res.RetCode = 10001
+				res.RetMsg = "params error"
+				return res, nil
+			}
+			return pages[req.NextCursor], nil
+		}, bybit.UserSubMembersReq{})
+		require.ErrorContains(err, "params error (10001)")
+	})
+
+	t.Run("stop early", func(t *testing.T) {
+		require := require.New(t)
+
+		n := 0
+		usernames := []string{}
+		for v, err := range bybit.Pages[bybit.SubMember](context.Background(), func(ctx context.Context, req bybit.UserSubMembersReq) (bybit.UserSubMembersRes, error) {
+			n++
+			return pages[req.NextCursor], nil
+		}, bybit.UserSubMembersReq{}) {
+			require.NoError(err)
+			usernames = append(usernames, v.Username)
+			if len(usernames) == 3 {
+				break
+			}
+		}
+		require.Equal([]string{"foo", "bar", "baz"}, usernames)
+		require.Equal(2, n)
+	})
+
+	t.Run("retry on rate limit", func(t *testing.T) {
+		require := require.New(t)
+
+		n := 0
+		members, err := bybit.CollectPages[bybit.SubMember](context.Background(), func(ctx context.Context, req bybit.UserSubMembersReq) (bybit.UserSubMembersRes, error) {
+			n++
+			if n == 2 {
+				res := bybit.UserSubMembersRes{}
+				res.RetCode = bybit.RetCodeTooManyVisits
+				return res, nil
+			}
+			return pages[req.NextCursor], nil
+		}, bybit.UserSubMembersReq{}, bybit.WithRateLimitRetry(1, time.Millisecond))
+		require.NoError(err)
+		require.Len(members, 4)
+		require.Equal(4, n)
+	})
+
+	t.Run("context canceled", func(t *testing.T) {
+		require := require.New(t)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		_, err := bybit.CollectPages[bybit.SubMember](ctx, func(ctx context.Context, req bybit.UserSubMembersReq) (bybit.UserSubMembersRes, error) {
+			cancel()
+			return pages[req.NextCursor], nil
+		}, bybit.UserSubMembersReq{}, bybit.WithPageInterval(time.Hour))
+		require.ErrorIs(err, context.Canceled)
+	})
+}
+
+func TestCollectPagesOfFundingHistory(t *testing.T) {
+	require := require.New(t)
+
+	// Settled every 8 hours, newer first.
+	now := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	history := []bybit.FundingRate{}
+	for i := range 5 {
+		history = append(history, bybit.FundingRate{
+			Symbol:               "BTCUSD",
+			FundingRate:          bybit.Amount(i) / 10000,
+			FundingRateTimestamp: bybit.Timestamp(now.Add(-time.Duration(i) * 8 * time.Hour)),
+		})
+	}
+
+	reqs := []bybit.MarketFundingHistoryReq{}
+	rates, err := bybit.CollectPages[bybit.FundingRate](context.Background(), func(ctx context.Context, req bybit.MarketFundingHistoryReq) (bybit.MarketFundingHistoryRes, error) {
+		reqs = append(reqs, req)
+
+		res := bybit.MarketFundingHistoryRes{}
+		for _, v := range history {
+			if len(res.Result.List) == int(req.Limit) {
+				break
+			}
+			if time.Time(v.FundingRateTimestamp).After(time.Time(req.EndTime)) {
+				continue
+			}
+			res.Result.List = append(res.Result.List, v)
+		}
+		return res, nil
+	}, bybit.MarketFundingHistoryReq{Symbol: "BTCUSD", EndTime: bybit.Timestamp(now), Limit: 2})
+	require.NoError(err)
+	require.Equal(history, rates)
+
+	// Pages of 2, 2, 1, and the empty one.
+	require.Len(reqs, 4)
+	require.Equal(now, time.Time(reqs[0].EndTime))
+	for i, req := range reqs[1:] {
+		// Just before the oldest one in the previous page.
+		oldest := time.Time(history[min(2*i+1, len(history)-1)].FundingRateTimestamp)
+		require.Equal(oldest.Add(-time.Millisecond), time.Time(req.EndTime).UTC())
+		require.Equal(uint(2), req.Limit)
+		require.Equal(bybit.Symbol("BTCUSD"), req.Symbol)
+	}
+}
@@ -0,0 +1,130 @@
+package bybit
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+
+	"github.com/youmark/pkcs8"
+)
+
+const (
+	PemTypeRsaPrivateKey       = "RSA PRIVATE KEY"       // PKCS #1
+	PemTypePrivateKey          = "PRIVATE KEY"           // PKCS #8
+	PemTypeEncryptedPrivateKey = "ENCRYPTED PRIVATE KEY" // PKCS #8
+	PemTypeRsaPublicKey        = "RSA PUBLIC KEY"        // PKCS #1
+	PemTypePublicKey           = "PUBLIC KEY"            // PKIX
+)
+
+var ErrPassphraseRequired = errors.New("passphrase required for encrypted private key")
+
+// ParseRsaPrivateKey parses a PEM encoded RSA private key in PKCS #1 or PKCS #8.
+// Encrypted PKCS #8 and legacy encrypted PEM (RFC 1423) are decrypted by the passphrase.
+func ParseRsaPrivateKey(data []byte, passphrase []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("PEM block not found")
+	}
+
+	der := block.Bytes
+	//lint:ignore SA1019 legacy encrypted PEM is still produced by `openssl genrsa -aes256` of OpenSSL 1.x.
+	if x509.IsEncryptedPEMBlock(block) {
+		if len(passphrase) == 0 {
+			return nil, ErrPassphraseRequired
+		}
+
+		//lint:ignore SA1019 see above.
+		v, err := x509.DecryptPEMBlock(block, passphrase)
+		if err != nil {
+			return nil, fmt.Errorf("decrypt PEM: %w", err)
+		}
+		der = v
+	}
+
+	var (
+		key any
+		err error
+	)
+	switch block.Type {
+	case PemTypeRsaPrivateKey:
+		key, err = x509.ParsePKCS1PrivateKey(der)
+	case PemTypePrivateKey:
+		key, err = x509.ParsePKCS8PrivateKey(der)
+	case PemTypeEncryptedPrivateKey:
+		if len(passphrase) == 0 {
+			return nil, ErrPassphraseRequired
+		}
+		key, err = pkcs8.ParsePKCS8PrivateKey(der, passphrase)
+	default:
+		return nil, fmt.Errorf("unknown PEM type: %s", block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse private key: %w", err)
+	}
+
+	rsa_key, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("expected private key to be a RSA key")
+	}
+
+	return rsa_key, nil
+}
+
+// ParseRsaPublicKey parses a PEM encoded RSA public key in PKCS #1 or PKIX.
+func ParseRsaPublicKey(data []byte) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("PEM block not found")
+	}
+
+	var (
+		key any
+		err error
+	)
+	switch block.Type {
+	case PemTypeRsaPublicKey:
+		key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	case PemTypePublicKey:
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unknown PEM type: %s", block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse public key: %w", err)
+	}
+
+	rsa_key, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("expected public key to be a RSA key")
+	}
+
+	return rsa_key, nil
+}
+
+// VerifyRsa verifies the signature made by `SecretRecord.Sign` with RSA secret.
+func VerifyRsa(key *rsa.PublicKey, data []byte, signature string) error {
+	s, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return fmt.Errorf("decode signature: %w", err)
+	}
+
+	hash := sha256.Sum256(data)
+	return rsa.VerifyPKCS1v15(key, crypto.SHA256, hash[:], s)
+}
+
+// RsaFingerprint returns SHA-256 digest of the public key in PKIX DER
+// in the form of OpenSSH, e.g. "SHA256:47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU".
+func RsaFingerprint(key *rsa.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		return "", fmt.Errorf("marshal public key: %w", err)
+	}
+
+	digest := sha256.Sum256(der)
+	return fmt.Sprintf("SHA256:%s", base64.RawStdEncoding.EncodeToString(digest[:])), nil
+}
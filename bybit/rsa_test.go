@@ -0,0 +1,69 @@
+package bybit_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+
+	"github.com/lesomnus/tiny-short/bybit"
+	"github.com/stretchr/testify/require"
+	"github.com/youmark/pkcs8"
+)
+
+func TestParseRsaPrivateKey(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	pkcs8_der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	encrypted_der, err := pkcs8.MarshalPrivateKey(key, []byte("foo"), nil)
+	require.NoError(t, err)
+
+	for name, block := range map[string]*pem.Block{
+		"pkcs1": {Type: bybit.PemTypeRsaPrivateKey, Bytes: x509.MarshalPKCS1PrivateKey(key)},
+		"pkcs8": {Type: bybit.PemTypePrivateKey, Bytes: pkcs8_der},
+	} {
+		t.Run(name, func(t *testing.T) {
+			require := require.New(t)
+
+			actual, err := bybit.ParseRsaPrivateKey(pem.EncodeToMemory(block), nil)
+			require.NoError(err)
+			require.True(key.Equal(actual))
+		})
+	}
+
+	t.Run("encrypted pkcs8", func(t *testing.T) {
+		require := require.New(t)
+
+		data := pem.EncodeToMemory(&pem.Block{Type: bybit.PemTypeEncryptedPrivateKey, Bytes: encrypted_der})
+
+		_, err := bybit.ParseRsaPrivateKey(data, nil)
+		require.ErrorIs(err, bybit.ErrPassphraseRequired)
+
+		_, err = bybit.ParseRsaPrivateKey(data, []byte("bar"))
+		require.Error(err)
+
+		actual, err := bybit.ParseRsaPrivateKey(data, []byte("foo"))
+		require.NoError(err)
+		require.True(key.Equal(actual))
+	})
+
+	t.Run("sign and verify", func(t *testing.T) {
+		require := require.New(t)
+
+		s := bybit.SecretRecord{
+			Type:   bybit.SecretTypeRsa,
+			Secret: string(pem.EncodeToMemory(&pem.Block{Type: bybit.PemTypeRsaPrivateKey, Bytes: x509.MarshalPKCS1PrivateKey(key)})),
+		}
+		signature, err := s.Sign([]byte("foo"))
+		require.NoError(err)
+
+		pub, err := bybit.ParseRsaPublicKey(pem.EncodeToMemory(&pem.Block{Type: bybit.PemTypeRsaPublicKey, Bytes: x509.MarshalPKCS1PublicKey(&key.PublicKey)}))
+		require.NoError(err)
+		require.NoError(bybit.VerifyRsa(pub, []byte("foo"), signature))
+		require.Error(bybit.VerifyRsa(pub, []byte("bar"), signature))
+	})
+}
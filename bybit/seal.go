@@ -0,0 +1,191 @@
+package bybit
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+type Kdf string
+
+const (
+	KdfScrypt = Kdf("scrypt") // Key derived from a passphrase.
+	KdfRaw    = Kdf("raw")    // Key is used as is.
+	KdfRsa    = Kdf("rsa")    // Key derived from a signature made by RSA private key.
+)
+
+const CipherAesGcm = "AES-256-GCM"
+
+var ErrSecretsSealed = errors.New("secrets are sealed")
+
+// StoreKey derives the key that seals the secret store.
+type StoreKey struct {
+	Kdf    Kdf
+	derive func(s *sealedSecrets) ([]byte, error)
+}
+
+func NewPassphraseKey(passphrase []byte) *StoreKey {
+	return &StoreKey{
+		Kdf: KdfScrypt,
+		derive: func(s *sealedSecrets) ([]byte, error) {
+			if s.Params == nil {
+				return nil, errors.New("scrypt parameters not found")
+			}
+			return scrypt.Key(passphrase, s.Salt, s.Params.N, s.Params.R, s.Params.P, 32)
+		},
+	}
+}
+
+func NewRawKey(key []byte) (*StoreKey, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("key must be 32 bytes: %d", len(key))
+	}
+
+	return &StoreKey{
+		Kdf: KdfRaw,
+		derive: func(s *sealedSecrets) ([]byte, error) {
+			return key, nil
+		},
+	}, nil
+}
+
+// NewRsaKey uses given RSA secret to derive the key.
+// PKCS #1 v1.5 signature is deterministic so the same key is derived
+// for the same salt.
+func NewRsaKey(secret SecretRecord) (*StoreKey, error) {
+	if secret.Type != SecretTypeRsa {
+		return nil, fmt.Errorf("secret must be %s: %s", SecretTypeRsa, secret.Type)
+	}
+
+	return &StoreKey{
+		Kdf: KdfRsa,
+		derive: func(s *sealedSecrets) ([]byte, error) {
+			var w bytes.Buffer
+			w.WriteString("tiny-short secret store\n")
+			w.Write(s.Salt)
+
+			signature, err := secret.Sign(w.Bytes())
+			if err != nil {
+				return nil, fmt.Errorf("sign: %w", err)
+			}
+
+			key := sha256.Sum256([]byte(signature))
+			return key[:], nil
+		},
+	}, nil
+}
+
+type scryptParams struct {
+	N int `json:"n"`
+	R int `json:"r"`
+	P int `json:"p"`
+}
+
+type sealedSecrets struct {
+	Cipher string        `json:"cipher"`
+	Kdf    Kdf           `json:"kdf"`
+	Params *scryptParams `json:"params,omitempty"`
+	Salt   []byte        `json:"salt"`
+	Nonce  []byte        `json:"nonce"`
+	Data   []byte        `json:"data"`
+}
+
+func (s *sealedSecrets) aead(key *StoreKey) (cipher.AEAD, error) {
+	k, err := key.derive(s)
+	if err != nil {
+		return nil, fmt.Errorf("derive key: %w", err)
+	}
+
+	block, err := aes.NewCipher(k)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+func (s *sealedSecrets) ad() []byte {
+	return []byte(fmt.Sprintf("%s;%s", s.Cipher, s.Kdf))
+}
+
+// SealSecrets writes records encrypted by the key.
+func SealSecrets(w io.Writer, records SecretStore, key *StoreKey) error {
+	plain, err := json.Marshal(encodeRecords(records))
+	if err != nil {
+		return fmt.Errorf("marshal: %w", err)
+	}
+
+	s, err := seal(plain, key)
+	if err != nil {
+		return err
+	}
+
+	d := secretStoreDoc{
+		Version: SecretStoreVersion,
+		Sealed:  s,
+	}
+	return d.write(w)
+}
+
+func seal(plain []byte, key *StoreKey) (*sealedSecrets, error) {
+	s := &sealedSecrets{
+		Cipher: CipherAesGcm,
+		Kdf:    key.Kdf,
+		Salt:   make([]byte, 16),
+	}
+	if key.Kdf == KdfScrypt {
+		s.Params = &scryptParams{N: 1 << 15, R: 8, P: 1}
+	}
+	if _, err := rand.Read(s.Salt); err != nil {
+		return nil, fmt.Errorf("generate salt: %w", err)
+	}
+
+	aead, err := s.aead(key)
+	if err != nil {
+		return nil, err
+	}
+
+	s.Nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(s.Nonce); err != nil {
+		return nil, fmt.Errorf("generate nonce: %w", err)
+	}
+	s.Data = aead.Seal(nil, s.Nonce, plain, s.ad())
+
+	return s, nil
+}
+
+// It returns `ErrSecretsSealed` if the key is nil.
+func (s *sealedSecrets) open(key *StoreKey) ([]byte, error) {
+	if key == nil {
+		return nil, ErrSecretsSealed
+	}
+	if s.Cipher != CipherAesGcm {
+		return nil, fmt.Errorf("unknown cipher: %s", s.Cipher)
+	}
+	if s.Kdf != key.Kdf {
+		return nil, fmt.Errorf("secrets are sealed by %s key but %s key is given", s.Kdf, key.Kdf)
+	}
+
+	aead, err := s.aead(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(s.Nonce) != aead.NonceSize() {
+		return nil, errors.New("invalid nonce size")
+	}
+
+	plain, err := aead.Open(nil, s.Nonce, s.Data, s.ad())
+	if err != nil {
+		return nil, fmt.Errorf("decrypt: %w", err)
+	}
+
+	return plain, nil
+}
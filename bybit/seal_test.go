@@ -0,0 +1,79 @@
+package bybit_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/lesomnus/tiny-short/bybit"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSealSecrets(t *testing.T) {
+	records := bybit.SecretStore{}
+	records.Set(42, bybit.SecretRecord{
+		Type:        bybit.SecretTypeHmac,
+		ApiKey:      "foo",
+		Secret:      "bar",
+		DateCreated: time.Unix(0, 0).UTC(),
+		DateExpired: time.Unix(1, 0).UTC(),
+	})
+
+	t.Run("seal and open", func(t *testing.T) {
+		require := require.New(t)
+
+		key := bybit.NewPassphraseKey([]byte("Royale with Cheese"))
+
+		var b bytes.Buffer
+		err := bybit.SealSecrets(&b, records, key)
+		require.NoError(err)
+		require.NotContains(b.String(), "foo")
+
+		actual := bybit.SecretStore{}
+		err = bybit.OpenSecrets(&b, actual, key)
+		require.NoError(err)
+		require.Equal(records, actual)
+	})
+
+	t.Run("wrong key", func(t *testing.T) {
+		require := require.New(t)
+
+		var b bytes.Buffer
+		err := bybit.SealSecrets(&b, records, bybit.NewPassphraseKey([]byte("foo")))
+		require.NoError(err)
+
+		err = bybit.OpenSecrets(&b, bybit.SecretStore{}, bybit.NewPassphraseKey([]byte("bar")))
+		require.Error(err)
+	})
+
+	t.Run("sealed but no key", func(t *testing.T) {
+		require := require.New(t)
+
+		key, err := bybit.NewRawKey(make([]byte, 32))
+		require.NoError(err)
+
+		var b bytes.Buffer
+		err = bybit.SealSecrets(&b, records, key)
+		require.NoError(err)
+
+		err = bybit.OpenSecrets(&b, bybit.SecretStore{}, nil)
+		require.ErrorIs(err, bybit.ErrSecretsSealed)
+	})
+
+	t.Run("open plain", func(t *testing.T) {
+		require := require.New(t)
+
+		var b bytes.Buffer
+		err := bybit.SaveSecrets(&b, records)
+		require.NoError(err)
+
+		key, err := bybit.NewRawKey(make([]byte, 32))
+		require.NoError(err)
+
+		actual := bybit.SecretStore{}
+		err = bybit.OpenSecrets(&b, actual, key)
+		require.NoError(err)
+		require.Equal(records, actual)
+	})
+
+}
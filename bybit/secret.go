@@ -1,17 +1,10 @@
 package bybit
 
 import (
-	"crypto"
-	"crypto/hmac"
-	"crypto/rand"
+	"context"
 	"crypto/rsa"
-	"crypto/sha256"
-	"crypto/x509"
 	"encoding/base64"
-	"encoding/hex"
 	"encoding/json"
-	"encoding/pem"
-	"errors"
 	"fmt"
 	"io"
 	"maps"
@@ -31,6 +24,12 @@ type SecretRecord struct {
 	Secret      string     `json:"secret"`
 	DateCreated time.Time  `json:"dateCreated"`
 	DateExpired time.Time  `json:"dateExpired"`
+
+	// Decrypts the RSA private key if it is encrypted.
+	Passphrase string `json:"-"`
+
+	// Signs requests instead of `Secret` if given.
+	Signer Signer `json:"-"`
 }
 
 func (r *SecretRecord) Hmac() ([]byte, error) {
@@ -38,60 +37,42 @@ func (r *SecretRecord) Hmac() ([]byte, error) {
 }
 
 func (r *SecretRecord) Rsa() (*rsa.PrivateKey, error) {
-	block, _ := pem.Decode([]byte(r.Secret))
-	if block == nil {
-		return nil, errors.New("PEM block not found")
-	}
+	return ParseRsaPrivateKey([]byte(r.Secret), []byte(r.Passphrase))
+}
 
-	some_key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
-	if err != nil {
-		return nil, fmt.Errorf("parse private key: %w", err)
+// NewSigner returns `Signer` if given or makes a new one from `Secret`.
+func (r *SecretRecord) NewSigner() (Signer, error) {
+	if r.Signer != nil {
+		return r.Signer, nil
 	}
 
-	rsa_key, ok := some_key.(*rsa.PrivateKey)
-	if !ok {
-		return nil, fmt.Errorf("expected private key to be a RSA key")
-	}
+	switch r.Type {
+	case SecretTypeHmac:
+		key, err := r.Hmac()
+		if err != nil {
+			return nil, err
+		}
+		return NewHmacSigner(key), nil
 
-	return rsa_key, nil
-}
+	case SecretTypeRsa:
+		key, err := r.Rsa()
+		if err != nil {
+			return nil, err
+		}
+		return NewRsaSigner(key), nil
 
-func (r *SecretRecord) Sign(data []byte) (string, error) {
-	switch r.Type {
-	case "HMAC":
-		return r.signByHmac(data)
-	case "RSA":
-		return r.signByRsa(data)
 	default:
-		return "", fmt.Errorf("unknown type of secret")
-	}
-}
-
-func (r *SecretRecord) signByHmac(data []byte) (string, error) {
-	key, err := r.Hmac()
-	if err != nil {
-		return "", err
+		return nil, fmt.Errorf("unknown type of secret")
 	}
-
-	hash := hmac.New(sha256.New, key)
-	hash.Write(data)
-	signature := hex.EncodeToString(hash.Sum(nil))
-	return signature, nil
 }
 
-func (r *SecretRecord) signByRsa(data []byte) (string, error) {
-	key, err := r.Rsa()
-	if err != nil {
-		return "", err
-	}
-
-	hash := sha256.Sum256(data)
-	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+func (r *SecretRecord) Sign(data []byte) (string, error) {
+	s, err := r.NewSigner()
 	if err != nil {
 		return "", err
 	}
 
-	return base64.StdEncoding.EncodeToString(signature), nil
+	return s.Sign(context.Background(), data)
 }
 
 type SecretStore map[uint64]SecretRecord
@@ -105,14 +86,24 @@ func (s SecretStore) Set(uid UserId, record SecretRecord) {
 	s[uint64(uid)] = record
 }
 
-func SaveSecrets(w io.Writer, records SecretStore) error {
-	s := SecretStore{}
-	for k, v := range records {
-		v.Secret = base64.RawStdEncoding.EncodeToString([]byte(v.Secret))
-		s[k] = v
-	}
+func (s SecretStore) Delete(uid UserId) {
+	delete(s, uint64(uid))
+}
+
+// Version of the on-disk format of the secret store.
+//
+//	0: Records, or sealed records, at the top level.
+//	1: Records, or sealed records, in a versioned document.
+const SecretStoreVersion = 1
+
+type secretStoreDoc struct {
+	Version int            `json:"version"`
+	Records SecretStore    `json:"records,omitempty"`
+	Sealed  *sealedSecrets `json:"sealed,omitempty"`
+}
 
-	data, err := json.MarshalIndent(s, "", "\t")
+func (d *secretStoreDoc) write(w io.Writer) error {
+	data, err := json.MarshalIndent(d, "", "\t")
 	if err != nil {
 		return fmt.Errorf("marshal: %w", err)
 	}
@@ -123,7 +114,49 @@ func SaveSecrets(w io.Writer, records SecretStore) error {
 	return nil
 }
 
+func encodeRecords(records SecretStore) SecretStore {
+	s := SecretStore{}
+	for k, v := range records {
+		v.Secret = base64.RawStdEncoding.EncodeToString([]byte(v.Secret))
+		s[k] = v
+	}
+
+	return s
+}
+
+func decodeRecords(records SecretStore) error {
+	for k, v := range records {
+		r, err := base64.RawStdEncoding.DecodeString(v.Secret)
+		if err != nil {
+			return fmt.Errorf("%d has invalid secret: %w", k, err)
+		}
+
+		v.Secret = string(r)
+		records[k] = v
+	}
+
+	return nil
+}
+
+func SaveSecrets(w io.Writer, records SecretStore) error {
+	d := secretStoreDoc{
+		Version: SecretStoreVersion,
+		Records: encodeRecords(records),
+	}
+	return d.write(w)
+}
+
+// LoadSecrets reads records written by `SaveSecrets`.
+// It returns `ErrSecretsSealed` if records are sealed.
 func LoadSecrets(r io.Reader, records SecretStore) error {
+	return OpenSecrets(r, records, nil)
+}
+
+// OpenSecrets reads records written by `SaveSecrets` or `SealSecrets`
+// in any version of the format.
+// Sealed records are decrypted by the key so the key can be nil
+// if records are not sealed.
+func OpenSecrets(r io.Reader, records SecretStore, key *StoreKey) error {
 	data, err := io.ReadAll(r)
 	if err != nil {
 		return fmt.Errorf("read: %w", err)
@@ -132,18 +165,46 @@ func LoadSecrets(r io.Reader, records SecretStore) error {
 		return nil
 	}
 
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return fmt.Errorf("unmarshal: %w", err)
+	}
+
+	var sealed *sealedSecrets
 	s := SecretStore{}
-	if err := json.Unmarshal(data, &s); err != nil {
+	if _, ok := fields["version"]; ok {
+		d := secretStoreDoc{}
+		if err := json.Unmarshal(data, &d); err != nil {
+			return fmt.Errorf("unmarshal: %w", err)
+		}
+		if d.Version > SecretStoreVersion {
+			return fmt.Errorf("unsupported version of the secret store: %d", d.Version)
+		}
+
+		sealed = d.Sealed
+		if d.Records != nil {
+			s = d.Records
+		}
+	} else if _, ok := fields["cipher"]; ok {
+		sealed = &sealedSecrets{}
+		if err := json.Unmarshal(data, sealed); err != nil {
+			return fmt.Errorf("unmarshal: %w", err)
+		}
+	} else if err := json.Unmarshal(data, &s); err != nil {
 		return fmt.Errorf("unmarshal: %w", err)
 	}
-	for k, v := range s {
-		r, err := base64.RawStdEncoding.DecodeString(v.Secret)
+
+	if sealed != nil {
+		plain, err := sealed.open(key)
 		if err != nil {
-			return fmt.Errorf("%d has invalid secret: %w", k, err)
+			return err
 		}
-
-		v.Secret = string(r)
-		s[k] = v
+		if err := json.Unmarshal(plain, &s); err != nil {
+			return fmt.Errorf("unmarshal sealed: %w", err)
+		}
+	}
+	if err := decodeRecords(s); err != nil {
+		return err
 	}
 
 	maps.Copy(records, s)
@@ -0,0 +1,52 @@
+package bybit_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/lesomnus/tiny-short/bybit"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadSecrets(t *testing.T) {
+	records := bybit.SecretStore{}
+	records.Set(42, bybit.SecretRecord{
+		Type:        bybit.SecretTypeHmac,
+		ApiKey:      "foo",
+		Secret:      "bar",
+		DateCreated: time.Unix(0, 0).UTC(),
+		DateExpired: time.Unix(1, 0).UTC(),
+	})
+
+	t.Run("save and load", func(t *testing.T) {
+		require := require.New(t)
+
+		var b bytes.Buffer
+		err := bybit.SaveSecrets(&b, records)
+		require.NoError(err)
+
+		actual := bybit.SecretStore{}
+		err = bybit.LoadSecrets(&b, actual)
+		require.NoError(err)
+		require.Equal(records, actual)
+	})
+
+	t.Run("version 0", func(t *testing.T) {
+		require := require.New(t)
+
+		data := `{"42":{"type":"HMAC","apikey":"foo","secret":"YmFy","dateCreated":"1970-01-01T00:00:00Z","dateExpired":"1970-01-01T00:00:01Z"}}`
+
+		actual := bybit.SecretStore{}
+		err := bybit.LoadSecrets(bytes.NewReader([]byte(data)), actual)
+		require.NoError(err)
+		require.Equal(records, actual)
+	})
+
+	t.Run("unsupported version", func(t *testing.T) {
+		require := require.New(t)
+
+		err := bybit.LoadSecrets(bytes.NewReader([]byte(`{"version":999}`)), bybit.SecretStore{})
+		require.ErrorContains(err, "unsupported version")
+	})
+}
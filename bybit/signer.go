@@ -0,0 +1,131 @@
+package bybit
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// Signer signs the payload of a request.
+type Signer interface {
+	Sign(ctx context.Context, payload []byte) (string, error)
+}
+
+type HmacSigner struct {
+	key []byte
+}
+
+func NewHmacSigner(key []byte) *HmacSigner {
+	return &HmacSigner{key: key}
+}
+
+func (s *HmacSigner) Sign(ctx context.Context, payload []byte) (string, error) {
+	hash := hmac.New(sha256.New, s.key)
+	hash.Write(payload)
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// RsaSigner holds the parsed private key so it is not parsed on every request.
+type RsaSigner struct {
+	key *rsa.PrivateKey
+}
+
+func NewRsaSigner(key *rsa.PrivateKey) *RsaSigner {
+	return &RsaSigner{key: key}
+}
+
+func (s *RsaSigner) Sign(ctx context.Context, payload []byte) (string, error) {
+	hash := sha256.Sum256(payload)
+	signature, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, hash[:])
+	if err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(signature), nil
+}
+
+type ExecSignerEncoding string
+
+const (
+	ExecSignerEncodingBase64 = ExecSignerEncoding("base64") // Command prints base64 encoded signature.
+	ExecSignerEncodingRaw    = ExecSignerEncoding("raw")    // Command prints signature in binary.
+)
+
+// ExecSigner pipes the payload to the command and reads back the signature
+// so the private key can be kept out of the process, e.g. in a signing agent.
+// The command must make RSASSA-PKCS1-v1_5 signature with SHA-256,
+// e.g. `openssl dgst -sha256 -sign key.pem` with `ExecSignerEncodingRaw`.
+type ExecSigner struct {
+	Command  []string
+	Encoding ExecSignerEncoding // `ExecSignerEncodingBase64` if empty.
+}
+
+func (s *ExecSigner) Sign(ctx context.Context, payload []byte) (string, error) {
+	if len(s.Command) == 0 {
+		return "", errors.New("command not given")
+	}
+
+	var (
+		stdout bytes.Buffer
+		stderr bytes.Buffer
+	)
+	cmd := exec.CommandContext(ctx, s.Command[0], s.Command[1:]...)
+	cmd.Stdin = bytes.NewReader(payload)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("run %s: %w: %s", s.Command[0], err, msg)
+		}
+		return "", fmt.Errorf("run %s: %w", s.Command[0], err)
+	}
+
+	switch s.Encoding {
+	case "", ExecSignerEncodingBase64:
+		signature := strings.TrimSpace(stdout.String())
+		if _, err := base64.StdEncoding.DecodeString(signature); err != nil {
+			return "", fmt.Errorf("decode signature: %w", err)
+		}
+		return signature, nil
+
+	case ExecSignerEncodingRaw:
+		if stdout.Len() == 0 {
+			return "", errors.New("empty signature")
+		}
+		return base64.StdEncoding.EncodeToString(stdout.Bytes()), nil
+
+	default:
+		return "", fmt.Errorf("unknown encoding: %s", s.Encoding)
+	}
+}
+
+// lazySigner makes a signer from the secret on its first use.
+type lazySigner struct {
+	secret SecretRecord
+
+	once   sync.Once
+	signer Signer
+	err    error
+}
+
+func (s *lazySigner) Sign(ctx context.Context, payload []byte) (string, error) {
+	s.once.Do(func() {
+		s.signer, s.err = s.secret.NewSigner()
+	})
+	if s.err != nil {
+		return "", s.err
+	}
+
+	return s.signer.Sign(ctx, payload)
+}
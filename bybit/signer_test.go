@@ -0,0 +1,65 @@
+package bybit_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/lesomnus/tiny-short/bybit"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHmacSigner(t *testing.T) {
+	require := require.New(t)
+
+	// Well-known HMAC-SHA256 test vector.
+	s := bybit.NewHmacSigner([]byte("key"))
+	signature, err := s.Sign(context.Background(), []byte("The quick brown fox jumps over the lazy dog"))
+	require.NoError(err)
+	require.Equal("f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8", signature)
+}
+
+func TestExecSigner(t *testing.T) {
+	openssl, err := exec.LookPath("openssl")
+	if err != nil {
+		t.Skip("openssl not found")
+	}
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	p := filepath.Join(t.TempDir(), "key.pem")
+	err = os.WriteFile(p, pem.EncodeToMemory(&pem.Block{
+		Type:  bybit.PemTypeRsaPrivateKey,
+		Bytes: x509.MarshalPKCS1PrivateKey(key),
+	}), 0600)
+	require.NoError(t, err)
+
+	t.Run("raw", func(t *testing.T) {
+		require := require.New(t)
+
+		s := &bybit.ExecSigner{
+			Command:  []string{openssl, "dgst", "-sha256", "-sign", p},
+			Encoding: bybit.ExecSignerEncodingRaw,
+		}
+		signature, err := s.Sign(context.Background(), []byte("foo"))
+		require.NoError(err)
+		require.NoError(bybit.VerifyRsa(&key.PublicKey, []byte("foo"), signature))
+	})
+
+	t.Run("command fails", func(t *testing.T) {
+		require := require.New(t)
+
+		s := &bybit.ExecSigner{
+			Command: []string{openssl, "dgst", "-sha256", "-sign", p + ".not-exist"},
+		}
+		_, err := s.Sign(context.Background(), []byte("foo"))
+		require.Error(err)
+	})
+}
@@ -4,6 +4,8 @@ import "context"
 
 type TradeApi interface {
 	OrderCreate(ctx context.Context, req TradeOrderCreateApiReq) (TradeOrderCreateApiRes, error)
+	OrderAmend(ctx context.Context, req TradeOrderAmendReq) (TradeOrderAmendRes, error)
+	OrderCancel(ctx context.Context, req TradeOrderCancelReq) (TradeOrderCancelRes, error)
 	OrderHistory(ctx context.Context, req TradeOrderHistoryReq) (TradeOrderHistoryRes, error)
 	ExecutionList(ctx context.Context, req TradeExecutionListReq) (TradeExecutionListRes, error)
 }
@@ -24,46 +26,109 @@ type TradeOrderCreateApiRes struct {
 	} `json:"result"`
 }
 
+type TradeOrderAmendReq struct {
+	Category ProductType `json:"category"`
+	Symbol   Symbol      `json:"symbol"`
+	OrderId  string      `json:"orderId"`
+	Quantity string      `json:"qty,omitempty"`
+	Price    string      `json:"price,omitempty"`
+}
+type TradeOrderAmendRes struct {
+	ResponseBase `json:",inline"`
+
+	Result struct {
+		OrderId string `json:"orderId"`
+	} `json:"result"`
+}
+
+type TradeOrderCancelReq struct {
+	Category ProductType `json:"category"`
+	Symbol   Symbol      `json:"symbol"`
+	OrderId  string      `json:"orderId"`
+}
+type TradeOrderCancelRes struct {
+	ResponseBase `json:",inline"`
+
+	Result struct {
+		OrderId string `json:"orderId"`
+	} `json:"result"`
+}
+
 type TradeOrderHistoryReq struct {
 	Category ProductType `url:"category"`
 	OrderId  string      `url:"orderId"`
 	Limit    uint        `url:"limit"`
+	Cursor   string      `url:"cursor,omitempty"` // Use the nextPageCursor token from the response to retrieve the next page of the result set.
 }
 type TradeOrderHistoryRes struct {
 	ResponseBase `json:",inline"`
 
 	Result struct {
-		List []struct {
-			OrderId  string `json:"orderId"`
-			Price    Amount `json:"price"` // Order price.
-			Qty      Amount `json:"qty"`
-			AvgPrice Amount `json:"avgPrice"` // Average filled price.
-
-			CreatedTime Timestamp `json:"createdTime"`
-			UpdatedTime Timestamp `json:"updatedTime"`
-		} `json:"list"`
+		List           []Order `json:"list"`
+		NextPageCursor string  `json:"nextPageCursor"`
 	} `json:"result"`
 }
 
+func (r TradeOrderHistoryReq) WithCursor(cursor string) TradeOrderHistoryReq {
+	r.Cursor = cursor
+	return r
+}
+
+func (r *TradeOrderHistoryRes) Page() ([]Order, string) {
+	return r.Result.List, r.Result.NextPageCursor
+}
+
+type Order struct {
+	OrderId     string      `json:"orderId"`
+	Category    ProductType `json:"category"`
+	Symbol      Symbol      `json:"symbol"`
+	Side        OrderSide   `json:"side"`
+	OrderStatus OrderStatus `json:"orderStatus"`
+	Price       Amount      `json:"price"` // Order price.
+	Qty         Amount      `json:"qty"`
+	CumExecQty  Amount      `json:"cumExecQty"`
+	AvgPrice    Amount      `json:"avgPrice"` // Average filled price.
+
+	CreatedTime Timestamp `json:"createdTime"`
+	UpdatedTime Timestamp `json:"updatedTime"`
+}
+
 type TradeExecutionListReq struct {
 	Category ProductType `url:"category"`
 	OrderId  string      `url:"orderId"`
 	Limit    uint        `url:"limit"`
+	Cursor   string      `url:"cursor,omitempty"` // Use the nextPageCursor token from the response to retrieve the next page of the result set.
 }
 type TradeExecutionListRes struct {
 	ResponseBase `json:",inline"`
 
 	Result struct {
-		Category ProductType `json:"category"`
-		List     []struct {
-			OrderId   string    `json:"orderId"`
-			ExecPrice Amount    `json:"execPrice"`
-			ExecQty   Amount    `json:"execQty"`
-			ExecTime  Timestamp `json:"execTime"`
-		} `json:"list"`
+		Category       ProductType `json:"category"`
+		List           []Execution `json:"list"`
+		NextPageCursor string      `json:"nextPageCursor"`
 	} `json:"result"`
 }
 
+func (r TradeExecutionListReq) WithCursor(cursor string) TradeExecutionListReq {
+	r.Cursor = cursor
+	return r
+}
+
+func (r *TradeExecutionListRes) Page() ([]Execution, string) {
+	return r.Result.List, r.Result.NextPageCursor
+}
+
+type Execution struct {
+	OrderId   string      `json:"orderId"`
+	Category  ProductType `json:"category"`
+	Symbol    Symbol      `json:"symbol"`
+	Side      OrderSide   `json:"side"`
+	ExecPrice Amount      `json:"execPrice"`
+	ExecQty   Amount      `json:"execQty"`
+	ExecFee   Amount      `json:"execFee"`
+	ExecTime  Timestamp   `json:"execTime"`
+}
+
 type tradeApi struct {
 	client *client
 }
@@ -74,6 +139,18 @@ func (a *tradeApi) OrderCreate(ctx context.Context, req TradeOrderCreateApiReq)
 	return
 }
 
+func (a *tradeApi) OrderAmend(ctx context.Context, req TradeOrderAmendReq) (res TradeOrderAmendRes, err error) {
+	url := a.client.conf.endpoint.Get("/v5/order/amend")
+	err = a.client.post(ctx, url, &req, &res)
+	return
+}
+
+func (a *tradeApi) OrderCancel(ctx context.Context, req TradeOrderCancelReq) (res TradeOrderCancelRes, err error) {
+	url := a.client.conf.endpoint.Get("/v5/order/cancel")
+	err = a.client.post(ctx, url, &req, &res)
+	return
+}
+
 func (a *tradeApi) OrderHistory(ctx context.Context, req TradeOrderHistoryReq) (res TradeOrderHistoryRes, err error) {
 	url := a.client.conf.endpoint.Get("/v5/order/history")
 	err = a.client.get(ctx, url, &req, &res)
@@ -3,6 +3,7 @@ package bybit
 import (
 	"encoding/json"
 	"fmt"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
@@ -41,6 +42,17 @@ func (t *Timestamp) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// EncodeValues encodes the timestamp in milliseconds into a query.
+// Zero timestamp is omitted.
+func (t Timestamp) EncodeValues(key string, v *url.Values) error {
+	if time.Time(t).IsZero() {
+		return nil
+	}
+
+	v.Set(key, strconv.FormatInt(time.Time(t).UnixMilli(), 10))
+	return nil
+}
+
 type UserId uint64
 
 func (i UserId) String() string {
@@ -106,8 +118,9 @@ const (
 type AccountType string
 
 const (
-	AccountTypeFund    = AccountType("FUND")
-	AccountTypeUnified = AccountType("UNIFIED")
+	AccountTypeFund     = AccountType("FUND")
+	AccountTypeUnified  = AccountType("UNIFIED")
+	AccountTypeContract = AccountType("CONTRACT") // Classic account.
 )
 
 type ContractType string
@@ -135,6 +148,29 @@ const (
 	OrderSideSell = OrderSide("Sell")
 )
 
+type OrderStatus string
+
+const (
+	OrderStatusNew             = OrderStatus("New")
+	OrderStatusPartiallyFilled = OrderStatus("PartiallyFilled")
+	OrderStatusFilled          = OrderStatus("Filled")
+	OrderStatusCancelled       = OrderStatus("Cancelled")
+	OrderStatusRejected        = OrderStatus("Rejected")
+
+	// Market order of which remaining quantity is cancelled.
+	OrderStatusPartiallyFilledCanceled = OrderStatus("PartiallyFilledCanceled")
+)
+
+// IsClosed reports whether the order will not be filled anymore.
+func (s OrderStatus) IsClosed() bool {
+	switch s {
+	case OrderStatusFilled, OrderStatusCancelled, OrderStatusRejected, OrderStatusPartiallyFilledCanceled:
+		return true
+	default:
+		return false
+	}
+}
+
 type OrderType string
 
 const (
@@ -154,6 +190,10 @@ func (c Coin) InvPerceptual() Symbol {
 	return Symbol(fmt.Sprintf("%sUSD", c))
 }
 
+func (c Coin) LinPerpetual() Symbol {
+	return Symbol(fmt.Sprintf("%sUSDT", c))
+}
+
 type ResponseBase struct {
 	RetCode int    `json:"retCode"`
 	RetMsg  string `json:"retMsg"`
@@ -165,7 +205,17 @@ func (r *ResponseBase) Ok() bool {
 }
 
 func (r *ResponseBase) Err() error {
-	return fmt.Errorf("%s (%d)", r.RetMsg, r.RetCode)
+	return &ResponseError{Code: r.RetCode, Msg: r.RetMsg}
+}
+
+// ResponseError is an error returned by Bybit.
+type ResponseError struct {
+	Code int
+	Msg  string
+}
+
+func (e *ResponseError) Error() string {
+	return fmt.Sprintf("%s (%d)", e.Msg, e.Code)
 }
 
 type Amount float64
@@ -178,6 +228,11 @@ func (a Amount) StringPrec(prec int) string {
 	return strconv.FormatFloat(float64(a), 'f', prec, 64)
 }
 
+// MarshalJSON encodes the amount in a string literal as Bybit does.
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return json.Marshal(a.String())
+}
+
 func (a *Amount) UnmarshalJSON(data []byte) error {
 	var s string
 	err := json.Unmarshal(data, &s)
@@ -199,10 +254,11 @@ func (a *Amount) UnmarshalJSON(data []byte) error {
 }
 
 type AccountInfo struct {
-	UserId   UserId
-	Nickname string
-	Username string
-	Secret   SecretRecord
+	UserId      UserId
+	Nickname    string
+	Username    string
+	AccountType AccountType // Account to transfer from or to.
+	Secret      SecretRecord
 }
 
 func (i *AccountInfo) DisplayName() string {
@@ -5,6 +5,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/google/go-querystring/query"
 	"github.com/lesomnus/tiny-short/bybit"
 	"github.com/stretchr/testify/require"
 )
@@ -29,6 +30,19 @@ func TestTimestampJson(t *testing.T) {
 	})
 }
 
+func TestTimestampQuery(t *testing.T) {
+	require := require.New(t)
+
+	vs, err := query.Values(struct {
+		Start bybit.Timestamp `url:"start"`
+		End   bybit.Timestamp `url:"end"`
+	}{
+		Start: bybit.Timestamp(time.Unix(0, 42*1_000_000)),
+	})
+	require.NoError(err)
+	require.Equal("start=42", vs.Encode())
+}
+
 func TestTransferIdJSON(t *testing.T) {
 	t.Run("marshal", func(t *testing.T) {
 		require := require.New(t)
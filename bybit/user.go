@@ -8,8 +8,11 @@ import (
 type UserApi interface {
 	QueryApi(ctx context.Context, req UserQueryApiReq) (UserQueryApiRes, error)
 	QuerySubMembers(ctx context.Context, req UserQuerySubMembersReq) (UserQuerySubMembersRes, error)
+	SubMembers(ctx context.Context, req UserSubMembersReq) (UserSubMembersRes, error)
 	CreateSubApiKey(ctx context.Context, req UserCreateSubApiKeyReq) (UserCreateSubApiKeyRes, error)
-	// SubApiKeys(ctx context.Context, req UserSubApiKeysReq) (UserSubApiKeysRes, error)
+	SubApiKeys(ctx context.Context, req UserSubApiKeysReq) (UserSubApiKeysRes, error)
+	UpdateSubApiKey(ctx context.Context, req UserUpdateSubApiKeyReq) (UserUpdateSubApiKeyRes, error)
+	DeleteSubApiKey(ctx context.Context, req UserDeleteSubApiKeyReq) (UserDeleteSubApiKeyRes, error)
 }
 
 type UserQueryApiReq struct{}
@@ -33,17 +36,54 @@ type UserQuerySubMembersRes struct {
 	ResponseBase `json:",inline"`
 
 	Result struct {
-		SubMembers []struct {
-			UserId      UserId `json:"uid"`
-			Username    string `json:"username"`
-			MemberType  int    `json:"memberType"`
-			Status      int    `json:"status"`
-			AccountMode int    `json:"accountMode"`
-			Remark      string `json:"remark"`
-		} `json:"subMembers"`
+		SubMembers []SubMember `json:"subMembers"`
 	} `json:"result"`
 }
 
+// UserSubMembersReq queries sub members page by page.
+// Use it instead of `QuerySubMembers`, which returns at most 10k sub members.
+type UserSubMembersReq struct {
+	PageSize   uint   `url:"pageSize,omitempty"`   // Limit for data size per page. [1, 100]. Default: 10
+	NextCursor string `url:"nextCursor,omitempty"` // Use the nextCursor from the response to retrieve the next page of the result set.
+}
+type UserSubMembersRes struct {
+	ResponseBase `json:",inline"`
+
+	Result struct {
+		SubMembers []SubMember `json:"subMembers"`
+		NextCursor string      `json:"nextCursor"` // "0" if it is the last page.
+	} `json:"result"`
+}
+
+func (r UserSubMembersReq) WithCursor(cursor string) UserSubMembersReq {
+	r.NextCursor = cursor
+	return r
+}
+
+func (r *UserSubMembersRes) Page() ([]SubMember, string) {
+	if r.Result.NextCursor == "0" {
+		return r.Result.SubMembers, ""
+	}
+	return r.Result.SubMembers, r.Result.NextCursor
+}
+
+type SubMemberStatus int
+
+const (
+	SubMemberStatusNormal      = SubMemberStatus(1)
+	SubMemberStatusLoginBanned = SubMemberStatus(2)
+	SubMemberStatusFrozen      = SubMemberStatus(4)
+)
+
+type SubMember struct {
+	UserId      UserId          `json:"uid"`
+	Username    string          `json:"username"`
+	MemberType  int             `json:"memberType"`
+	Status      SubMemberStatus `json:"status"`
+	AccountMode int             `json:"accountMode"`
+	Remark      string          `json:"remark"`
+}
+
 type UserCreateSubApiKeyReq struct {
 	SubUserId   UserId         `json:"subuid"`
 	Note        string         `json:"note"`
@@ -59,19 +99,68 @@ type UserCreateSubApiKeyRes struct {
 	} `json:"result"`
 }
 
-// type UserSubApiKeysReq struct {
-// 	SubUserId UserId `json:"subMemberId"`
-// 	Limit     uint   `json:"limit"`  // Limit for data size per page. [1, 20]. Default: 20
-// 	Cursor    string `json:"cursor"` // Use the nextPageCursor token from the response to retrieve the next page of the result set.
-// }
-// type UserSubApiKeysRes struct {
-// 	ResponseBase `json:",inline"`
+type UserSubApiKeysReq struct {
+	SubUserId UserId `url:"subMemberId"`
+	Limit     uint   `url:"limit,omitempty"`  // Limit for data size per page. [1, 20]. Default: 20
+	Cursor    string `url:"cursor,omitempty"` // Use the nextPageCursor token from the response to retrieve the next page of the result set.
+}
+type UserSubApiKeysRes struct {
+	ResponseBase `json:",inline"`
+
+	Result struct {
+		List           []SubApiKeyInfo `json:"result"`
+		NextPageCursor string          `json:"nextPageCursor"`
+	} `json:"result"`
+}
+
+func (r UserSubApiKeysReq) WithCursor(cursor string) UserSubApiKeysReq {
+	r.Cursor = cursor
+	return r
+}
+
+func (r *UserSubApiKeysRes) Page() ([]SubApiKeyInfo, string) {
+	return r.Result.List, r.Result.NextPageCursor
+}
+
+type SubApiKeyInfo struct {
+	Id          string         `json:"id"`
+	Ips         []string       `json:"ips"`
+	ApiKey      string         `json:"apiKey"`
+	Note        string         `json:"note"`
+	Status      int            `json:"status"`
+	ExpiredAt   time.Time      `json:"expiredAt"`
+	CreatedAt   time.Time      `json:"createdAt"`
+	Permissions ApiPermissions `json:"permissions"`
+	ReadOnly    bool           `json:"readOnly"`
+	DeadlineDay int            `json:"deadlineDay"`
+	Flag        string         `json:"flag"` // "hmac" | "rsa"
+}
+
+type UserUpdateSubApiKeyReq struct {
+	ApiKey      string          `json:"apikey,omitempty"` // Sub account's API key. Required if master's API key is used.
+	ReadOnly    int             `json:"readOnly"`
+	Ips         string          `json:"ips,omitempty"` // Comma separated. "*" if no restriction.
+	Permissions *ApiPermissions `json:"permissions,omitempty"`
+}
+type UserUpdateSubApiKeyRes struct {
+	ResponseBase `json:",inline"`
 
-// 	Result struct {
-// 		ApiKey string `json:"apiKey"`
-// 		Secret string `json:"secret"`
-// 	} `json:"result"`
-// }
+	Result struct {
+		Id          string         `json:"id"`
+		Note        string         `json:"note"`
+		ApiKey      string         `json:"apiKey"`
+		ReadOnly    int            `json:"readOnly"`
+		Permissions ApiPermissions `json:"permissions"`
+		Ips         []string       `json:"ips"`
+	} `json:"result"`
+}
+
+type UserDeleteSubApiKeyReq struct {
+	ApiKey string `json:"apikey,omitempty"` // Sub account's API key. Required if master's API key is used.
+}
+type UserDeleteSubApiKeyRes struct {
+	ResponseBase `json:",inline"`
+}
 
 type userApi struct {
 	client *client
@@ -89,8 +178,32 @@ func (a *userApi) QuerySubMembers(ctx context.Context, req UserQuerySubMembersRe
 	return
 }
 
+func (a *userApi) SubMembers(ctx context.Context, req UserSubMembersReq) (res UserSubMembersRes, err error) {
+	url := a.client.conf.endpoint.Get("/v5/user/submembers")
+	err = a.client.get(ctx, url, &req, &res)
+	return
+}
+
 func (a *userApi) CreateSubApiKey(ctx context.Context, req UserCreateSubApiKeyReq) (res UserCreateSubApiKeyRes, err error) {
 	url := a.client.conf.endpoint.Get("/v5/user/create-sub-api")
 	err = a.client.post(ctx, url, &req, &res)
 	return
 }
+
+func (a *userApi) SubApiKeys(ctx context.Context, req UserSubApiKeysReq) (res UserSubApiKeysRes, err error) {
+	url := a.client.conf.endpoint.Get("/v5/user/sub-apikeys")
+	err = a.client.get(ctx, url, &req, &res)
+	return
+}
+
+func (a *userApi) UpdateSubApiKey(ctx context.Context, req UserUpdateSubApiKeyReq) (res UserUpdateSubApiKeyRes, err error) {
+	url := a.client.conf.endpoint.Get("/v5/user/update-sub-api")
+	err = a.client.post(ctx, url, &req, &res)
+	return
+}
+
+func (a *userApi) DeleteSubApiKey(ctx context.Context, req UserDeleteSubApiKeyReq) (res UserDeleteSubApiKeyRes, err error) {
+	url := a.client.conf.endpoint.Get("/v5/user/delete-sub-api")
+	err = a.client.post(ctx, url, &req, &res)
+	return
+}
@@ -0,0 +1,80 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/lesomnus/tiny-short/bybit"
+)
+
+// Position is an update of the position.
+type Position struct {
+	Category      bybit.ProductType `json:"category"`
+	Symbol        bybit.Symbol      `json:"symbol"`
+	Side          bybit.OrderSide   `json:"side"` // Empty if there is no position.
+	Size          bybit.Amount      `json:"size"`
+	EntryPrice    bybit.Amount      `json:"entryPrice"`
+	MarkPrice     bybit.Amount      `json:"markPrice"`
+	PositionValue bybit.Amount      `json:"positionValue"`
+	UnrealisedPnl bybit.Amount      `json:"unrealisedPnl"`
+	LiqPrice      bybit.Amount      `json:"liqPrice"`
+	UpdatedTime   bybit.Timestamp   `json:"updatedTime"`
+}
+
+// Wallet is an update of the wallet balance.
+type Wallet struct {
+	AccountType bybit.AccountType `json:"accountType"`
+	TotalEquity bybit.Amount      `json:"totalEquity"`
+	Coins       []WalletCoin      `json:"coin"`
+}
+
+type WalletCoin struct {
+	Coin                bybit.Coin   `json:"coin"`
+	Equity              bybit.Amount `json:"equity"`
+	WalletBalance       bybit.Amount `json:"walletBalance"`
+	AvailableToWithdraw bybit.Amount `json:"availableToWithdraw"`
+	UnrealisedPnl       bybit.Amount `json:"unrealisedPnl"`
+}
+
+// Balance returns the wallet balance of the coin.
+func (w *Wallet) Balance(coin bybit.Coin) (bybit.Amount, bool) {
+	for _, c := range w.Coins {
+		if c.Coin == coin {
+			return c.WalletBalance, true
+		}
+	}
+	return 0, false
+}
+
+// SubscribeOrders subscribes "order" of every category.
+func (s *Stream) SubscribeOrders(ctx context.Context, handle func(vs []bybit.Order)) error {
+	return subscribeList(ctx, s, "order", handle)
+}
+
+// SubscribeExecutions subscribes "execution" of every category.
+func (s *Stream) SubscribeExecutions(ctx context.Context, handle func(vs []bybit.Execution)) error {
+	return subscribeList(ctx, s, "execution", handle)
+}
+
+// SubscribePositions subscribes "position" of every category.
+func (s *Stream) SubscribePositions(ctx context.Context, handle func(vs []Position)) error {
+	return subscribeList(ctx, s, "position", handle)
+}
+
+// SubscribeWallet subscribes "wallet".
+func (s *Stream) SubscribeWallet(ctx context.Context, handle func(vs []Wallet)) error {
+	return subscribeList(ctx, s, "wallet", handle)
+}
+
+func subscribeList[T any](ctx context.Context, s *Stream, topic string, handle func(vs []T)) error {
+	return s.Subscribe(ctx, topic, func(msg Message) error {
+		vs := []T{}
+		if err := json.Unmarshal(msg.Data, &vs); err != nil {
+			return fmt.Errorf("unmarshal %s: %w", topic, err)
+		}
+
+		handle(vs)
+		return nil
+	})
+}
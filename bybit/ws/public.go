@@ -0,0 +1,151 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/lesomnus/tiny-short/bybit"
+)
+
+// Ticker is the latest state of the ticker.
+// Deltas are merged so every field is filled.
+type Ticker struct {
+	Symbol          bybit.Symbol    `json:"symbol"`
+	LastPrice       bybit.Amount    `json:"lastPrice"`
+	MarkPrice       bybit.Amount    `json:"markPrice"`
+	IndexPrice      bybit.Amount    `json:"indexPrice"`
+	Bid1Price       bybit.Amount    `json:"bid1Price"`
+	Bid1Size        bybit.Amount    `json:"bid1Size"`
+	Ask1Price       bybit.Amount    `json:"ask1Price"`
+	Ask1Size        bybit.Amount    `json:"ask1Size"`
+	FundingRate     bybit.Amount    `json:"fundingRate"`
+	NextFundingTime bybit.Timestamp `json:"nextFundingTime"`
+	OpenInterest    bybit.Amount    `json:"openInterest"`
+	Volume24h       bybit.Amount    `json:"volume24h"`
+	Turnover24h     bybit.Amount    `json:"turnover24h"`
+
+	Time time.Time `json:"-"`
+}
+
+// SubscribeTickers subscribes "tickers.{symbol}".
+func (s *Stream) SubscribeTickers(ctx context.Context, symbol bybit.Symbol, handle func(v Ticker)) error {
+	v := Ticker{}
+	return s.Subscribe(ctx, fmt.Sprintf("tickers.%s", symbol), func(msg Message) error {
+		if msg.Type == "snapshot" {
+			v = Ticker{}
+		}
+		if err := json.Unmarshal(msg.Data, &v); err != nil {
+			return fmt.Errorf("unmarshal ticker: %w", err)
+		}
+
+		v.Time = msg.Time()
+		handle(v)
+		return nil
+	})
+}
+
+type OrderbookLevel struct {
+	Price bybit.Amount
+	Size  bybit.Amount
+}
+
+// Orderbook is the latest state of the order book.
+type Orderbook struct {
+	Symbol   bybit.Symbol
+	Bids     []OrderbookLevel // Higher price first.
+	Asks     []OrderbookLevel // Lower price first.
+	UpdateId int64
+	Seq      int64
+
+	Time time.Time
+}
+
+type orderbookData struct {
+	Symbol   bybit.Symbol `json:"s"`
+	Bids     [][2]string  `json:"b"`
+	Asks     [][2]string  `json:"a"`
+	UpdateId int64        `json:"u"`
+	Seq      int64        `json:"seq"`
+}
+
+// SubscribeOrderbook subscribes "orderbook.{depth}.{symbol}".
+func (s *Stream) SubscribeOrderbook(ctx context.Context, depth int, symbol bybit.Symbol, handle func(v Orderbook)) error {
+	bids := map[string]bybit.Amount{}
+	asks := map[string]bybit.Amount{}
+	return s.Subscribe(ctx, fmt.Sprintf("orderbook.%d.%s", depth, symbol), func(msg Message) error {
+		var d orderbookData
+		if err := json.Unmarshal(msg.Data, &d); err != nil {
+			return fmt.Errorf("unmarshal orderbook: %w", err)
+		}
+
+		// Update ID 1 means the service restarted and it is a snapshot.
+		if msg.Type == "snapshot" || d.UpdateId == 1 {
+			clear(bids)
+			clear(asks)
+		}
+		if err := applyLevels(bids, d.Bids); err != nil {
+			return err
+		}
+		if err := applyLevels(asks, d.Asks); err != nil {
+			return err
+		}
+
+		v := Orderbook{
+			Symbol:   d.Symbol,
+			Bids:     sortLevels(bids, true),
+			Asks:     sortLevels(asks, false),
+			UpdateId: d.UpdateId,
+			Seq:      d.Seq,
+			Time:     msg.Time(),
+		}
+		handle(v)
+		return nil
+	})
+}
+
+// Level of size 0 is removed.
+func applyLevels(book map[string]bybit.Amount, levels [][2]string) error {
+	for _, l := range levels {
+		if _, err := strconv.ParseFloat(l[0], 64); err != nil {
+			return fmt.Errorf("invalid price: %w", err)
+		}
+		size, err := strconv.ParseFloat(l[1], 64)
+		if err != nil {
+			return fmt.Errorf("invalid size: %w", err)
+		}
+		if size == 0 {
+			delete(book, l[0])
+		} else {
+			book[l[0]] = bybit.Amount(size)
+		}
+	}
+
+	return nil
+}
+
+func sortLevels(book map[string]bybit.Amount, desc bool) []OrderbookLevel {
+	vs := make([]OrderbookLevel, 0, len(book))
+	for p, size := range book {
+		price, _ := strconv.ParseFloat(p, 64)
+		vs = append(vs, OrderbookLevel{Price: bybit.Amount(price), Size: size})
+	}
+	slices.SortFunc(vs, func(a, b OrderbookLevel) int {
+		if desc {
+			a, b = b, a
+		}
+		switch {
+		case a.Price < b.Price:
+			return -1
+		case a.Price > b.Price:
+			return 1
+		default:
+			return 0
+		}
+	})
+
+	return vs
+}
@@ -0,0 +1,487 @@
+// Package ws implements clients of Bybit v5 WebSocket streams.
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/lesomnus/tiny-short/bybit"
+	"github.com/lesomnus/tiny-short/log"
+)
+
+const (
+	PublicLinearAddr  = "wss://stream.bybit.com/v5/public/linear"
+	PublicInverseAddr = "wss://stream.bybit.com/v5/public/inverse"
+
+	TestNetPublicLinearAddr  = "wss://stream-testnet.bybit.com/v5/public/linear"
+	TestNetPublicInverseAddr = "wss://stream-testnet.bybit.com/v5/public/inverse"
+
+	PrivateAddr        = "wss://stream.bybit.com/v5/private"
+	TestNetPrivateAddr = "wss://stream-testnet.bybit.com/v5/private"
+
+	TradeAddr        = "wss://stream.bybit.com/v5/trade"
+	TestNetTradeAddr = "wss://stream-testnet.bybit.com/v5/trade"
+)
+
+var ErrNotConnected = errors.New("not connected")
+
+// Max number of topics in a subscribe request.
+const maxArgs = 10
+
+// Message is a push message of a topic.
+type Message struct {
+	Topic        string          `json:"topic"`
+	Type         string          `json:"type"`         // "snapshot" | "delta"; empty for private topics.
+	Ts           int64           `json:"ts"`           // Milliseconds.
+	CreationTime int64           `json:"creationTime"` // Milliseconds; only for private topics.
+	Data         json.RawMessage `json:"data"`
+}
+
+func (m *Message) Time() time.Time {
+	if m.Ts == 0 {
+		return time.UnixMilli(m.CreationTime)
+	}
+	return time.UnixMilli(m.Ts)
+}
+
+// Handler handles messages of a topic.
+// It is called by the goroutine reading the stream so it must not block.
+type Handler = func(msg Message) error
+
+type opReq struct {
+	ReqId string `json:"req_id,omitempty"`
+	Op    string `json:"op"`
+	Args  []any  `json:"args,omitempty"`
+}
+
+// callReq is a request of the trade API.
+type callReq struct {
+	ReqId  string            `json:"reqId"`
+	Header map[string]string `json:"header"`
+	Op     string            `json:"op"`
+	Args   []any             `json:"args"`
+}
+
+// frame is any message from the stream.
+type frame struct {
+	Message
+
+	Success *bool  `json:"success"`
+	RetMsg  string `json:"ret_msg"`
+	ConnId  string `json:"conn_id"`
+	ReqId   string `json:"req_id"`
+	Op      string `json:"op"`
+
+	// Trade API responds in different form.
+	CallReqId  string `json:"reqId"`
+	RetCode    *int   `json:"retCode"`
+	CallRetMsg string `json:"retMsg"`
+}
+
+// ok reports whether the operation succeeded.
+func (f *frame) ok() bool {
+	if f.RetCode != nil {
+		return *f.RetCode == bybit.RetCodeOk
+	}
+	return f.Success != nil && *f.Success
+}
+
+func (f *frame) retMsg() string {
+	if f.RetCode != nil {
+		return f.CallRetMsg
+	}
+	return f.RetMsg
+}
+
+type streamConfig struct {
+	ping_interval  time.Duration
+	reconnect_wait time.Duration
+	dialer         *websocket.Dialer
+}
+
+type StreamOption = func(c *streamConfig)
+
+// WithPingInterval sets the interval of heartbeats.
+// The connection is considered dead if nothing is received in twice of the interval.
+func WithPingInterval(d time.Duration) StreamOption {
+	return func(c *streamConfig) {
+		c.ping_interval = d
+	}
+}
+
+// WithReconnectWait sets the time to wait before reconnecting.
+func WithReconnectWait(d time.Duration) StreamOption {
+	return func(c *streamConfig) {
+		c.reconnect_wait = d
+	}
+}
+
+func WithDialer(d *websocket.Dialer) StreamOption {
+	return func(c *streamConfig) {
+		c.dialer = d
+	}
+}
+
+// Stream is a connection to a Bybit WebSocket stream.
+// It reconnects and subscribes the topics again if the connection is lost
+// until the context given to `Run` is done.
+type Stream struct {
+	addr   string
+	conf   streamConfig
+	secret *bybit.SecretRecord // Authenticates the connection if given.
+
+	req_id atomic.Uint64
+
+	stopped chan struct{} // Closed when `Run` returns.
+	err     error         // Returned by `Run`.
+
+	mu       sync.Mutex
+	conn     *websocket.Conn // nil if not connected.
+	ready    chan struct{}   // Closed when every topic is subscribed.
+	topics   []string
+	handlers map[string]Handler
+	calls    map[string]chan frame // Trade API requests waiting for responses by request ID.
+
+	write_mu sync.Mutex
+}
+
+// NewPrivateStream returns a stream authenticated by the secret.
+func NewPrivateStream(addr string, secret bybit.SecretRecord, opts ...StreamOption) *Stream {
+	s := NewStream(addr, opts...)
+	s.secret = &secret
+	return s
+}
+
+func NewStream(addr string, opts ...StreamOption) *Stream {
+	c := streamConfig{
+		ping_interval:  20 * time.Second,
+		reconnect_wait: time.Second,
+		dialer:         websocket.DefaultDialer,
+	}
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	return &Stream{
+		addr:     addr,
+		conf:     c,
+		stopped:  make(chan struct{}),
+		ready:    make(chan struct{}),
+		handlers: map[string]Handler{},
+		calls:    map[string]chan frame{},
+	}
+}
+
+// Ready waits until the stream is connected and topics subscribed so far are subscribed.
+// It returns the error of `Run` if it returns before.
+func (s *Stream) Ready(ctx context.Context) error {
+	s.mu.Lock()
+	ready := s.ready
+	s.mu.Unlock()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-s.stopped:
+		return s.err
+	case <-ready:
+		return nil
+	}
+}
+
+// Subscribe registers the handler of the topic.
+// The topic is subscribed immediately if the stream is connected, otherwise on the connection.
+func (s *Stream) Subscribe(ctx context.Context, topic string, handler Handler) error {
+	s.mu.Lock()
+	if _, ok := s.handlers[topic]; ok {
+		s.mu.Unlock()
+		return fmt.Errorf("already subscribed: %s", topic)
+	}
+	s.topics = append(s.topics, topic)
+	s.handlers[topic] = handler
+	conn := s.conn
+	s.mu.Unlock()
+
+	if conn == nil {
+		return nil
+	}
+	_, err := s.send(conn, opReq{Op: "subscribe", Args: []any{topic}})
+	return err
+}
+
+// Run connects to the stream and dispatches messages to the handlers until the context is done.
+// Error returned by a handler or rejection of the authentication closes the stream.
+// It must be called once.
+func (s *Stream) Run(ctx context.Context) error {
+	s.err = s.run(ctx)
+	close(s.stopped)
+	return s.err
+}
+
+func (s *Stream) run(ctx context.Context) error {
+	l := log.From(ctx)
+	for {
+		err := s.session(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		var f_err *fatalError
+		if errors.As(err, &f_err) {
+			return f_err.err
+		}
+
+		l.Warn("stream disconnected", slog.String("addr", s.addr), slog.String("err", err.Error()))
+
+		t := time.NewTimer(s.conf.reconnect_wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+type fatalError struct {
+	err error
+}
+
+func (e *fatalError) Error() string {
+	return e.err.Error()
+}
+
+func (s *Stream) session(ctx context.Context) error {
+	l := log.From(ctx)
+
+	conn, _, err := s.conf.dialer.DialContext(ctx, s.addr, nil)
+	if err != nil {
+		return fmt.Errorf("dial: %w", err)
+	}
+	defer conn.Close()
+
+	deadline := func() {
+		conn.SetReadDeadline(time.Now().Add(2 * s.conf.ping_interval))
+	}
+	deadline()
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		t := time.NewTicker(s.conf.ping_interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				// Unblocks the read.
+				conn.Close()
+				return
+			case <-t.C:
+				if _, err := s.send(conn, opReq{Op: "ping"}); err != nil {
+					conn.Close()
+					return
+				}
+			}
+		}
+	}()
+
+	if s.secret != nil {
+		if err := s.auth(ctx, conn); err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	topics := append([]string{}, s.topics...)
+	ready := s.ready
+	s.conn = conn
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.conn = nil
+		for id, c := range s.calls {
+			close(c)
+			delete(s.calls, id)
+		}
+		select {
+		case <-s.ready:
+			s.ready = make(chan struct{})
+		default:
+		}
+		s.mu.Unlock()
+	}()
+
+	pending := map[string]bool{}
+	for i := 0; i < len(topics); i += maxArgs {
+		args := []any{}
+		for _, t := range topics[i:min(i+maxArgs, len(topics))] {
+			args = append(args, t)
+		}
+
+		req_id, err := s.send(conn, opReq{Op: "subscribe", Args: args})
+		if err != nil {
+			return fmt.Errorf("subscribe: %w", err)
+		}
+		pending[req_id] = true
+	}
+	if len(pending) == 0 {
+		close(ready)
+	}
+
+	l.Info("stream connected", slog.String("addr", s.addr))
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return fmt.Errorf("read: %w", err)
+		}
+		deadline()
+
+		var f frame
+		if err := json.Unmarshal(data, &f); err != nil {
+			l.Warn("invalid message", slog.String("data", string(data)), slog.String("err", err.Error()))
+			continue
+		}
+		if f.CallReqId != "" {
+			s.mu.Lock()
+			c, ok := s.calls[f.CallReqId]
+			delete(s.calls, f.CallReqId)
+			s.mu.Unlock()
+			if ok {
+				c <- f
+			}
+			continue
+		}
+		if f.Op != "" {
+			if (f.Success != nil || f.RetCode != nil) && !f.ok() {
+				l.Warn("stream operation failed", slog.String("op", f.Op), slog.String("ret_msg", f.retMsg()))
+			}
+			if f.Op == "subscribe" && pending[f.ReqId] {
+				delete(pending, f.ReqId)
+				if len(pending) == 0 {
+					close(ready)
+				}
+			}
+			continue
+		}
+		if f.Topic == "" {
+			continue
+		}
+
+		s.mu.Lock()
+		handler, ok := s.handlers[f.Topic]
+		s.mu.Unlock()
+		if !ok {
+			continue
+		}
+		if err := handler(f.Message); err != nil {
+			return &fatalError{fmt.Errorf("handle %s: %w", f.Topic, err)}
+		}
+	}
+}
+
+// auth authenticates the connection by a signature of "GET/realtime{expires}".
+func (s *Stream) auth(ctx context.Context, conn *websocket.Conn) error {
+	signer, err := s.secret.NewSigner()
+	if err != nil {
+		return fmt.Errorf("auth: signer: %w", err)
+	}
+
+	expires := time.Now().Add(10 * time.Second).UnixMilli()
+	signature, err := signer.Sign(ctx, []byte(fmt.Sprintf("GET/realtime%d", expires)))
+	if err != nil {
+		return fmt.Errorf("auth: sign: %w", err)
+	}
+
+	req_id, err := s.send(conn, opReq{Op: "auth", Args: []any{s.secret.ApiKey, expires, signature}})
+	if err != nil {
+		return fmt.Errorf("auth: %w", err)
+	}
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return fmt.Errorf("auth: read: %w", err)
+		}
+
+		var f frame
+		if err := json.Unmarshal(data, &f); err != nil {
+			return fmt.Errorf("auth: invalid message: %w", err)
+		}
+		if f.Op != "auth" || (f.ReqId != "" && f.ReqId != req_id) {
+			continue
+		}
+		if !f.ok() {
+			return &fatalError{fmt.Errorf("auth rejected: %s", f.retMsg())}
+		}
+
+		return nil
+	}
+}
+
+// call sends a request of the trade API and waits for its response.
+func (s *Stream) call(ctx context.Context, op string, arg any) (frame, error) {
+	req := callReq{
+		ReqId: strconv.FormatUint(s.req_id.Add(1), 10),
+		Header: map[string]string{
+			"X-BAPI-TIMESTAMP":   strconv.FormatInt(time.Now().UnixMilli(), 10),
+			"X-BAPI-RECV-WINDOW": "5000",
+		},
+		Op:   op,
+		Args: []any{arg},
+	}
+
+	c := make(chan frame, 1)
+	s.mu.Lock()
+	conn := s.conn
+	if conn != nil {
+		s.calls[req.ReqId] = c
+	}
+	s.mu.Unlock()
+	if conn == nil {
+		return frame{}, ErrNotConnected
+	}
+
+	s.write_mu.Lock()
+	err := conn.WriteJSON(req)
+	s.write_mu.Unlock()
+	if err != nil {
+		s.mu.Lock()
+		delete(s.calls, req.ReqId)
+		s.mu.Unlock()
+		return frame{}, fmt.Errorf("write: %w", err)
+	}
+
+	select {
+	case <-ctx.Done():
+		s.mu.Lock()
+		delete(s.calls, req.ReqId)
+		s.mu.Unlock()
+		return frame{}, ctx.Err()
+	case f, ok := <-c:
+		if !ok {
+			return frame{}, errors.New("connection lost before the response")
+		}
+		return f, nil
+	}
+}
+
+// send returns the request ID.
+func (s *Stream) send(conn *websocket.Conn, req opReq) (string, error) {
+	if req.ReqId == "" {
+		req.ReqId = strconv.FormatUint(s.req_id.Add(1), 10)
+	}
+
+	s.write_mu.Lock()
+	defer s.write_mu.Unlock()
+	return req.ReqId, conn.WriteJSON(req)
+}
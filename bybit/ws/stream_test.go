@@ -0,0 +1,281 @@
+package ws_test
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/lesomnus/tiny-short/bybit"
+	"github.com/lesomnus/tiny-short/bybit/ws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type op struct {
+	ReqId string   `json:"req_id"`
+	Op    string   `json:"op"`
+	Args  []string `json:"args"`
+}
+
+// serve runs a WebSocket server that calls `f` with each connection.
+func serve(t *testing.T, f func(conn *websocket.Conn)) string {
+	upgrader := websocket.Upgrader{}
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		f(conn)
+	}))
+	t.Cleanup(s.Close)
+
+	return "ws" + strings.TrimPrefix(s.URL, "http")
+}
+
+func readOp(t *testing.T, conn *websocket.Conn) op {
+	var v op
+	assert.NoError(t, conn.ReadJSON(&v))
+	return v
+}
+
+func ack(t *testing.T, conn *websocket.Conn, v op) {
+	assert.NoError(t, conn.WriteJSON(map[string]any{
+		"success": true,
+		"ret_msg": "",
+		"conn_id": "test",
+		"req_id":  v.ReqId,
+		"op":      v.Op,
+	}))
+}
+
+func push(t *testing.T, conn *websocket.Conn, topic string, typ string, data string) {
+	assert.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"topic":"`+topic+`","type":"`+typ+`","ts":1700000000000,"data":`+data+`}`)))
+}
+
+func TestStream(t *testing.T) {
+	t.Run("tickers", func(t *testing.T) {
+		require := require.New(t)
+
+		addr := serve(t, func(conn *websocket.Conn) {
+			v := readOp(t, conn)
+			assert.Equal(t, "subscribe", v.Op)
+			assert.Equal(t, []string{"tickers.BTCUSD"}, v.Args)
+			ack(t, conn, v)
+
+			push(t, conn, "tickers.BTCUSD", "snapshot", `{"symbol":"BTCUSD","markPrice":"42000.5","fundingRate":"0.0001","nextFundingTime":"1700006400000"}`)
+			push(t, conn, "tickers.BTCUSD", "delta", `{"symbol":"BTCUSD","markPrice":"42001"}`)
+			conn.ReadMessage()
+		})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		tickers := []ws.Ticker{}
+		s := ws.NewStream(addr)
+		err := s.SubscribeTickers(ctx, bybit.CoinBtc.InvPerceptual(), func(v ws.Ticker) {
+			tickers = append(tickers, v)
+			if len(tickers) == 2 {
+				cancel()
+			}
+		})
+		require.NoError(err)
+
+		err = s.Run(ctx)
+		require.ErrorIs(err, context.Canceled)
+		require.Len(tickers, 2)
+		require.Equal(bybit.Amount(42000.5), tickers[0].MarkPrice)
+		require.Equal(bybit.Amount(42001), tickers[1].MarkPrice)
+		require.Equal(bybit.Amount(0.0001), tickers[1].FundingRate)
+		require.Equal(int64(1700006400000), tickers[1].NextFundingTime.Time().UnixMilli())
+	})
+
+	t.Run("orderbook", func(t *testing.T) {
+		require := require.New(t)
+
+		addr := serve(t, func(conn *websocket.Conn) {
+			ack(t, conn, readOp(t, conn))
+			push(t, conn, "orderbook.50.BTCUSD", "snapshot", `{"s":"BTCUSD","b":[["100","1"],["99","2"]],"a":[["101","3"],["102","4"]],"u":10,"seq":1}`)
+			push(t, conn, "orderbook.50.BTCUSD", "delta", `{"s":"BTCUSD","b":[["100","0"],["98","5"]],"a":[["101","1"]],"u":11,"seq":2}`)
+			conn.ReadMessage()
+		})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		var book ws.Orderbook
+		s := ws.NewStream(addr)
+		err := s.SubscribeOrderbook(ctx, 50, bybit.CoinBtc.InvPerceptual(), func(v ws.Orderbook) {
+			book = v
+			if v.Seq == 2 {
+				cancel()
+			}
+		})
+		require.NoError(err)
+		require.ErrorIs(s.Run(ctx), context.Canceled)
+
+		require.Equal([]ws.OrderbookLevel{{99, 2}, {98, 5}}, book.Bids)
+		require.Equal([]ws.OrderbookLevel{{101, 1}, {102, 4}}, book.Asks)
+		require.Equal(int64(11), book.UpdateId)
+	})
+
+	t.Run("heartbeat", func(t *testing.T) {
+		require := require.New(t)
+
+		once := sync.Once{}
+		pinged := make(chan struct{})
+		addr := serve(t, func(conn *websocket.Conn) {
+			for {
+				v := op{}
+				if err := conn.ReadJSON(&v); err != nil {
+					return
+				}
+				if v.Op == "ping" {
+					ack(t, conn, v)
+					once.Do(func() { close(pinged) })
+					return
+				}
+			}
+		})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		s := ws.NewStream(addr, ws.WithPingInterval(10*time.Millisecond))
+		go s.Run(ctx)
+
+		select {
+		case <-pinged:
+		case <-ctx.Done():
+			require.Fail("not pinged")
+		}
+	})
+
+	t.Run("resubscribe on reconnect", func(t *testing.T) {
+		require := require.New(t)
+
+		n := atomic.Int32{}
+		addr := serve(t, func(conn *websocket.Conn) {
+			v := readOp(t, conn)
+			assert.Equal(t, []string{"tickers.BTCUSD"}, v.Args)
+			ack(t, conn, v)
+
+			// Drops the first connection.
+			if n.Add(1) == 1 {
+				return
+			}
+
+			data, _ := json.Marshal(map[string]string{"symbol": "BTCUSD", "markPrice": "1"})
+			push(t, conn, "tickers.BTCUSD", "snapshot", string(data))
+			conn.ReadMessage()
+		})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		s := ws.NewStream(addr, ws.WithReconnectWait(time.Millisecond))
+		err := s.SubscribeTickers(ctx, bybit.CoinBtc.InvPerceptual(), func(v ws.Ticker) {
+			cancel()
+		})
+		require.NoError(err)
+		require.ErrorIs(s.Run(ctx), context.Canceled)
+		require.Equal(int32(2), n.Load())
+	})
+}
+
+func TestPrivateStream(t *testing.T) {
+	secret := bybit.SecretRecord{
+		Type:   bybit.SecretTypeHmac,
+		ApiKey: "key",
+		Secret: "secret",
+	}
+
+	// Accepts the auth if the signature is valid.
+	auth := func(t *testing.T, conn *websocket.Conn) bool {
+		var v struct {
+			ReqId string `json:"req_id"`
+			Op    string `json:"op"`
+			Args  []any  `json:"args"`
+		}
+		if !assert.NoError(t, conn.ReadJSON(&v)) || !assert.Equal(t, "auth", v.Op) || !assert.Len(t, v.Args, 3) {
+			return false
+		}
+
+		h := hmac.New(sha256.New, []byte("secret"))
+		fmt.Fprintf(h, "GET/realtime%d", int64(v.Args[1].(float64)))
+		ok := v.Args[0] == "key" && v.Args[2] == hex.EncodeToString(h.Sum(nil))
+
+		assert.NoError(t, conn.WriteJSON(map[string]any{
+			"success": ok,
+			"ret_msg": "",
+			"req_id":  v.ReqId,
+			"op":      "auth",
+		}))
+		return ok
+	}
+
+	t.Run("orders", func(t *testing.T) {
+		require := require.New(t)
+
+		addr := serve(t, func(conn *websocket.Conn) {
+			if !auth(t, conn) {
+				return
+			}
+
+			v := readOp(t, conn)
+			assert.Equal(t, []string{"order"}, v.Args)
+			ack(t, conn, v)
+
+			conn.WriteMessage(websocket.TextMessage, []byte(`{"id":"1","topic":"order","creationTime":1700000000000,"data":[{"orderId":"foo","symbol":"BTCUSD","orderStatus":"Filled","qty":"100","avgPrice":"42000"}]}`))
+			conn.ReadMessage()
+		})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		orders := []bybit.Order{}
+		s := ws.NewPrivateStream(addr, secret)
+		err := s.SubscribeOrders(ctx, func(vs []bybit.Order) {
+			orders = append(orders, vs...)
+			cancel()
+		})
+		require.NoError(err)
+
+		go s.Run(ctx)
+		require.NoError(s.Ready(ctx))
+
+		<-ctx.Done()
+		require.Len(orders, 1)
+		require.Equal("foo", orders[0].OrderId)
+		require.Equal(bybit.OrderStatusFilled, orders[0].OrderStatus)
+		require.Equal(bybit.Amount(42000), orders[0].AvgPrice)
+	})
+
+	t.Run("auth rejected", func(t *testing.T) {
+		require := require.New(t)
+
+		addr := serve(t, func(conn *websocket.Conn) {
+			auth(t, conn)
+			conn.ReadMessage()
+		})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		secret := secret
+		secret.Secret = "wrong"
+		s := ws.NewPrivateStream(addr, secret)
+		require.ErrorContains(s.Run(ctx), "auth rejected")
+	})
+}
@@ -0,0 +1,192 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/lesomnus/tiny-short/bybit"
+	"github.com/lesomnus/tiny-short/log"
+)
+
+// TradeApi places, amends, and cancels orders over the WebSocket trade API.
+// Queries are made by the REST API since the trade API does not serve them.
+// Orders are placed by the REST API too if the stream cannot be connected.
+type TradeApi struct {
+	rest   bybit.TradeApi
+	stream *Stream
+
+	once   sync.Once
+	err    error // Set if the stream cannot be connected.
+	cancel context.CancelFunc
+}
+
+var _ bybit.TradeApi = (*TradeApi)(nil)
+
+func NewTradeApi(addr string, secret bybit.SecretRecord, rest bybit.TradeApi, opts ...StreamOption) *TradeApi {
+	return &TradeApi{
+		rest:   rest,
+		stream: NewPrivateStream(addr, secret, opts...),
+		cancel: func() {},
+	}
+}
+
+// Close disconnects the stream.
+func (a *TradeApi) Close() error {
+	a.once.Do(func() {
+		a.err = errors.New("closed")
+	})
+	a.cancel()
+	return nil
+}
+
+// connect connects to the stream at first call.
+func (a *TradeApi) connect(ctx context.Context) error {
+	a.once.Do(func() {
+		// Stream outlives the request.
+		ctx_, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		a.cancel = cancel
+		go a.stream.Run(ctx_)
+
+		ready_ctx, ready_cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer ready_cancel()
+		if err := a.stream.Ready(ready_ctx); err != nil {
+			cancel()
+			a.err = err
+
+			l := log.From(ctx)
+			l.Warn("trade stream not connected; fall back to REST", slog.String("err", err.Error()))
+		}
+	})
+
+	return a.err
+}
+
+// call sends the request by the trade API and decodes the response into `res`,
+// which has the same form with the one of REST API.
+func (a *TradeApi) call(ctx context.Context, op string, req any, res any) error {
+	f, err := a.stream.call(ctx, op, req)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if f.RetCode == nil {
+		return fmt.Errorf("%s: response without retCode", op)
+	}
+
+	data := f.Data
+	if len(data) == 0 {
+		data = json.RawMessage("{}")
+	}
+
+	body, err := json.Marshal(struct {
+		RetCode int             `json:"retCode"`
+		RetMsg  string          `json:"retMsg"`
+		Result  json.RawMessage `json:"result"`
+	}{
+		RetCode: *f.RetCode,
+		RetMsg:  f.CallRetMsg,
+		Result:  data,
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := json.Unmarshal(body, res); err != nil {
+		return fmt.Errorf("%s: unmarshal: %w", op, err)
+	}
+
+	return nil
+}
+
+func (a *TradeApi) OrderCreate(ctx context.Context, req bybit.TradeOrderCreateApiReq) (res bybit.TradeOrderCreateApiRes, err error) {
+	if a.connect(ctx) != nil {
+		return a.rest.OrderCreate(ctx, req)
+	}
+	err = a.call(ctx, "order.create", req, &res)
+	return
+}
+
+func (a *TradeApi) OrderAmend(ctx context.Context, req bybit.TradeOrderAmendReq) (res bybit.TradeOrderAmendRes, err error) {
+	if a.connect(ctx) != nil {
+		return a.rest.OrderAmend(ctx, req)
+	}
+	err = a.call(ctx, "order.amend", req, &res)
+	return
+}
+
+func (a *TradeApi) OrderCancel(ctx context.Context, req bybit.TradeOrderCancelReq) (res bybit.TradeOrderCancelRes, err error) {
+	if a.connect(ctx) != nil {
+		return a.rest.OrderCancel(ctx, req)
+	}
+	err = a.call(ctx, "order.cancel", req, &res)
+	return
+}
+
+func (a *TradeApi) OrderHistory(ctx context.Context, req bybit.TradeOrderHistoryReq) (bybit.TradeOrderHistoryRes, error) {
+	return a.rest.OrderHistory(ctx, req)
+}
+
+func (a *TradeApi) ExecutionList(ctx context.Context, req bybit.TradeExecutionListReq) (bybit.TradeExecutionListRes, error) {
+	return a.rest.ExecutionList(ctx, req)
+}
+
+// TradeClient is a client of which `Trade()` uses the WebSocket trade API.
+// Clones share connections so an account has at most one connection.
+type TradeClient struct {
+	bybit.Client
+
+	addr   string
+	secret bybit.SecretRecord
+	opts   []StreamOption
+	pool   *tradePool
+}
+
+type tradePool struct {
+	mu   sync.Mutex
+	apis map[string]*TradeApi // By API key.
+}
+
+func NewTradeClient(client bybit.Client, addr string, secret bybit.SecretRecord, opts ...StreamOption) *TradeClient {
+	return &TradeClient{
+		Client: client,
+		addr:   addr,
+		secret: secret,
+		opts:   opts,
+		pool:   &tradePool{apis: map[string]*TradeApi{}},
+	}
+}
+
+func (c *TradeClient) Trade() bybit.TradeApi {
+	c.pool.mu.Lock()
+	defer c.pool.mu.Unlock()
+
+	a, ok := c.pool.apis[c.secret.ApiKey]
+	if !ok {
+		a = NewTradeApi(c.addr, c.secret, c.Client.Trade(), c.opts...)
+		c.pool.apis[c.secret.ApiKey] = a
+	}
+	return a
+}
+
+func (c *TradeClient) Clone(secret bybit.SecretRecord) bybit.Client {
+	return &TradeClient{
+		Client: c.Client.Clone(secret),
+		addr:   c.addr,
+		secret: secret,
+		opts:   c.opts,
+		pool:   c.pool,
+	}
+}
+
+// Close disconnects every connection made by the client and its clones.
+func (c *TradeClient) Close() error {
+	c.pool.mu.Lock()
+	defer c.pool.mu.Unlock()
+	for _, a := range c.pool.apis {
+		a.Close()
+	}
+	return nil
+}
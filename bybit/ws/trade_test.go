@@ -0,0 +1,118 @@
+package ws_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/lesomnus/tiny-short/bybit"
+	"github.com/lesomnus/tiny-short/bybit/ws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// restTrade fails every call so the test knows which transport is used.
+type restTrade struct {
+	bybit.TradeApi
+	n int
+}
+
+func (a *restTrade) OrderCreate(ctx context.Context, req bybit.TradeOrderCreateApiReq) (bybit.TradeOrderCreateApiRes, error) {
+	a.n++
+	res := bybit.TradeOrderCreateApiRes{}
+	res.Result.OrderId = "rest"
+	return res, nil
+}
+
+func TestTradeApi(t *testing.T) {
+	secret := bybit.SecretRecord{
+		Type:   bybit.SecretTypeHmac,
+		ApiKey: "key",
+		Secret: "secret",
+	}
+
+	t.Run("order create", func(t *testing.T) {
+		require := require.New(t)
+
+		addr := serve(t, func(conn *websocket.Conn) {
+			v := map[string]any{}
+			assert.NoError(t, conn.ReadJSON(&v))
+			assert.Equal(t, "auth", v["op"])
+			assert.NoError(t, conn.WriteJSON(map[string]any{"retCode": 0, "retMsg": "OK", "op": "auth", "connId": "test"}))
+
+			for {
+				var req struct {
+					ReqId  string            `json:"reqId"`
+					Header map[string]string `json:"header"`
+					Op     string            `json:"op"`
+					Args   []map[string]any  `json:"args"`
+				}
+				if err := conn.ReadJSON(&req); err != nil {
+					return
+				}
+				assert.Equal(t, "order.create", req.Op)
+				assert.NotEmpty(t, req.Header["X-BAPI-TIMESTAMP"])
+				assert.Equal(t, "BTCUSD", req.Args[0]["symbol"])
+
+				res := map[string]any{"reqId": req.ReqId, "retCode": 0, "retMsg": "OK", "op": req.Op, "data": map[string]any{"orderId": "foo"}}
+				switch req.Args[0]["qty"] {
+				case "0":
+					res = map[string]any{"reqId": req.ReqId, "retCode": 10001, "retMsg": "params error", "op": req.Op, "data": map[string]any{}}
+				case "-1":
+					res = map[string]any{"reqId": req.ReqId, "op": req.Op}
+				}
+				assert.NoError(t, conn.WriteJSON(res))
+			}
+		})
+
+		rest := &restTrade{}
+		api := ws.NewTradeApi(addr, secret, rest)
+		defer api.Close()
+
+		ctx := context.Background()
+		res, err := api.OrderCreate(ctx, bybit.TradeOrderCreateApiReq{
+			Category:  bybit.ProductTypeInverse,
+			Symbol:    bybit.CoinBtc.InvPerceptual(),
+			Side:      bybit.OrderSideSell,
+			OrderType: bybit.OrderTypeMarket,
+			Quantity:  "100",
+		})
+		require.NoError(err)
+		require.True(res.Ok())
+		require.Equal("foo", res.Result.OrderId)
+
+		res, err = api.OrderCreate(ctx, bybit.TradeOrderCreateApiReq{
+			Symbol:   bybit.CoinBtc.InvPerceptual(),
+			Quantity: "0",
+		})
+		require.NoError(err)
+		require.False(res.Ok())
+		require.ErrorContains(res.Err(), "params error (10001)")
+
+		_, err = api.OrderCreate(ctx, bybit.TradeOrderCreateApiReq{
+			Symbol:   bybit.CoinBtc.InvPerceptual(),
+			Quantity: "-1",
+		})
+		require.ErrorContains(err, "without retCode")
+		require.Zero(rest.n)
+	})
+
+	t.Run("fall back to REST", func(t *testing.T) {
+		require := require.New(t)
+
+		addr := serve(t, func(conn *websocket.Conn) {
+			// Rejects the auth.
+			conn.ReadMessage()
+			conn.WriteJSON(map[string]any{"retCode": 10004, "retMsg": "invalid signature", "op": "auth"})
+		})
+
+		rest := &restTrade{}
+		api := ws.NewTradeApi(addr, secret, rest)
+		defer api.Close()
+
+		res, err := api.OrderCreate(context.Background(), bybit.TradeOrderCreateApiReq{})
+		require.NoError(err)
+		require.Equal("rest", res.Result.OrderId)
+		require.Equal(1, rest.n)
+	})
+}
@@ -0,0 +1,163 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/lesomnus/tiny-short/bybit"
+	"github.com/lesomnus/tiny-short/bybit/cassette"
+	"github.com/lesomnus/tiny-short/log"
+)
+
+// Notes of the cassette.
+const (
+	noteStoredKeys = "stored_keys" // UIDs whose API keys were read from the secret store.
+	noteState      = "state"       // Run state when it was loaded.
+)
+
+// cassetteSession records or replays REST interactions of a run by `.cassette`.
+// Local files that change requests made, the secret store and the run state,
+// are noted in the cassette when recorded and are not touched when replayed.
+type cassetteSession struct {
+	mode string
+	path string
+
+	recorder *cassette.Recorder
+	player   *cassette.Player
+
+	stored_keys []bybit.UserId
+}
+
+func openCassette(conf *Config) (*cassetteSession, error) {
+	s := &cassetteSession{
+		mode: conf.Cassette.Mode,
+		path: conf.Cassette.Path,
+	}
+
+	switch s.mode {
+	case "record":
+		s.recorder = cassette.NewRecorder()
+
+	case "replay":
+		c, err := cassette.Load(s.path)
+		if err != nil {
+			return nil, fmt.Errorf("load cassette: %w", err)
+		}
+		s.player = cassette.NewPlayer(c)
+	}
+
+	return s, nil
+}
+
+func (s *cassetteSession) enabled() bool {
+	return s.recorder != nil || s.player != nil
+}
+
+func (s *cassetteSession) replaying() bool {
+	return s.player != nil
+}
+
+func (s *cassetteSession) clientOptions() []bybit.ClientOption {
+	switch {
+	case s.recorder != nil:
+		return []bybit.ClientOption{bybit.WithHttpClient(&http.Client{Transport: s.recorder})}
+	case s.player != nil:
+		return []bybit.ClientOption{bybit.WithHttpClient(&http.Client{Transport: s.player})}
+	default:
+		return nil
+	}
+}
+
+// actingSecret reads the secret of the acting account.
+// A dummy is used when replaying so the keys of the recorder are not needed.
+func (s *cassetteSession) actingSecret(ctx context.Context, conf *Config) (bybit.SecretRecord, error) {
+	if s.player == nil {
+		return readActingSecret(ctx, conf)
+	}
+
+	return bybit.SecretRecord{
+		Type:   bybit.SecretTypeHmac,
+		ApiKey: "replayed",
+		Secret: "replayed",
+	}, nil
+}
+
+// secrets returns the secret store to use.
+// Stored keys noted are replaced with dummies when replaying.
+func (s *cassetteSession) secrets(secrets bybit.SecretStore) (bybit.SecretStore, error) {
+	if s.player == nil {
+		return secrets, nil
+	}
+
+	uids := []bybit.UserId{}
+	if _, err := s.player.Note(noteStoredKeys, &uids); err != nil {
+		return nil, err
+	}
+
+	secrets = bybit.SecretStore{}
+	for _, uid := range uids {
+		secrets.Set(uid, bybit.SecretRecord{
+			Type:        bybit.SecretTypeHmac,
+			ApiKey:      "replayed",
+			Secret:      "replayed",
+			DateExpired: time.Now().Add(365 * 24 * time.Hour),
+		})
+	}
+	return secrets, nil
+}
+
+// keyStored notes that the API key of the account was read from the secret store.
+func (s *cassetteSession) keyStored(uid bybit.UserId) error {
+	if s.recorder == nil {
+		return nil
+	}
+
+	s.stored_keys = append(s.stored_keys, uid)
+	return s.recorder.Note(noteStoredKeys, s.stored_keys)
+}
+
+// loadState loads the run state, which is read from the cassette and never saved when replaying.
+func (s *cassetteSession) loadState(ctx context.Context, path string) (*runState, error) {
+	if s.player != nil {
+		state := &runState{FundingUntil: map[string]time.Time{}, FundingCarried: map[string]bybit.Amount{}}
+		if _, err := s.player.Note(noteState, state); err != nil {
+			return nil, err
+		}
+		return state, nil
+	}
+
+	state, err := loadRunState(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	if s.recorder != nil {
+		if err := s.recorder.Note(noteState, state); err != nil {
+			return nil, err
+		}
+	}
+	return state, nil
+}
+
+// Close saves the cassette if recording.
+// It must be called even if the run fails.
+func (s *cassetteSession) Close(ctx context.Context) {
+	l := log.From(ctx)
+	switch {
+	case s.recorder != nil:
+		c := s.recorder.Cassette()
+		if err := c.Save(s.path); err != nil {
+			p_fail.Printf("Failed to save cassette to %s ", s.path)
+			p_fail_why.Println(err.Error())
+			return
+		}
+		l.Info("cassette saved", slog.String("path", s.path), slog.Int("interactions", len(c.Interactions)))
+
+	case s.player != nil:
+		if n := s.player.Remaining(); n > 0 {
+			l.Warn("cassette not fully replayed", slog.String("path", s.path), slog.Int("remaining", n))
+		}
+	}
+}
@@ -1,61 +1,253 @@
 package cmd
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"net/url"
 	"os"
+	"reflect"
+	"regexp"
 	"slices"
+	"strconv"
+	"strings"
 
 	"github.com/lesomnus/tiny-short/bybit"
 	"github.com/lesomnus/tiny-short/log"
+	"github.com/lesomnus/tiny-short/secret"
 	"gopkg.in/yaml.v3"
 )
 
 type Config struct {
-	path string
+	path    string
+	profile string
+	locs    map[string]string // Locations of the values in the config file.
 
 	Secret SecretConfig `yaml:"secret"`
 
-	Coins    []bybit.Coin   `yaml:"coins"`
+	Coins    []CoinConfig   `yaml:"coins"` // Plain symbol, e.g. "BTC", is also accepted.
 	Transfer TransferConfig `yaml:"transfer"`
 
+	Lock  LockConfig  `yaml:"lock"`
 	Log   LogConfig   `yaml:"log"`
 	Misc  MiscConfig  `yaml:"misc"`
 	Debug DebugConfig `yaml:"debug"`
+
+	Cassette CassetteConfig `yaml:"cassette"`
 }
 
 type SecretConfig struct {
-	Type           bybit.SecretType `yaml:"type"`
-	ApiKeyFile     string           `yaml:"api_key_file"`
-	PrivateKeyFile string           `yaml:"private_key_file"`
+	Type       bybit.SecretType   `yaml:"type" enum:"RSA,HMAC"`
+	ApiKey     SecretSourceConfig `yaml:"api_key"`
+	PrivateKey SecretSourceConfig `yaml:"private_key"`
+
+	// Decrypts the private key if it is encrypted.
+	PrivateKeyPassphrase SecretSourceConfig `yaml:"private_key_passphrase"`
+
+	// Signs requests by an external command instead of the private key.
+	Signer SignerConfig `yaml:"signer"`
+
+	// Shorthands for `{ provider: file, path: ... }`.
+	ApiKeyFile     string `yaml:"api_key_file"`
+	PrivateKeyFile string `yaml:"private_key_file"`
+
+	Store SecretStoreConfig `yaml:"store"`
+}
+
+type SecretSourceConfig struct {
+	Provider   string            `yaml:"provider" enum:"file,env,systemd-creds,secret-service"`
+	Path       string            `yaml:"path"`       // for "file"
+	Name       string            `yaml:"name"`       // for "env" and "systemd-creds"
+	Label      string            `yaml:"label"`      // for "secret-service"
+	Attributes map[string]string `yaml:"attributes"` // for "secret-service"
+}
+
+func (c *SecretSourceConfig) NewProvider() (secret.Provider, error) {
+	switch c.Provider {
+	case "file":
+		return &secret.File{Path: c.Path}, nil
+	case "env":
+		return &secret.Env{Name: c.Name}, nil
+	case "systemd-creds":
+		return &secret.SystemdCreds{Name: c.Name}, nil
+	case "secret-service":
+		return &secret.SecretService{Label: c.Label, Attributes: c.Attributes}, nil
+	default:
+		return nil, fmt.Errorf("unknown provider: %s", c.Provider)
+	}
+}
+
+func (c *SecretSourceConfig) validate(field string) []error {
+	errs := []error{}
+	switch c.Provider {
+	case "":
+		errs = append(errs, fmt.Errorf(".%s.provider cannot be empty", field))
+	case "file":
+		if c.Path == "" {
+			errs = append(errs, fmt.Errorf(`.%s.path cannot be empty if provider is "file"`, field))
+		}
+	case "env", "systemd-creds":
+		if c.Name == "" {
+			errs = append(errs, fmt.Errorf(`.%s.name cannot be empty if provider is "%s"`, field, c.Provider))
+		}
+	case "secret-service":
+		if len(c.Attributes) == 0 {
+			errs = append(errs, fmt.Errorf(`.%s.attributes cannot be empty if provider is "secret-service"`, field))
+		}
+	default:
+		errs = append(errs, fmt.Errorf(`.%s.provider must be one of "file", "env", "systemd-creds", or "secret-service": %s`, field, c.Provider))
+	}
+
+	return errs
+}
+
+type SignerConfig struct {
+	Command  []string `yaml:"command"`
+	Encoding string   `yaml:"encoding" enum:"base64,raw"`
+}
+
+type SecretStoreConfig struct {
+	Enabled            bool             `yaml:"enabled"`
+	SecretSourceConfig `yaml:",inline"` // Provider is "file" if not given.
+
+	Encryption SecretStoreEncryptionConfig `yaml:"encryption"`
+}
+
+type SecretStoreEncryptionConfig struct {
+	Type string `yaml:"type" enum:"none,passphrase,env,rsa"`
+	Env  string `yaml:"env"` // Name of env var that holds a passphrase or a base64 encoded key.
+}
+
+// CoinConfig is a strategy for a coin.
+type CoinConfig struct {
+	Coin    bybit.Coin        `yaml:"coin"`
+	Product bybit.ProductType `yaml:"product" enum:"inverse,linear"`
+	Mode    string            `yaml:"mode" enum:"short,transfer"` // "transfer" does not place an order.
+
+	MinTransfer bybit.Amount `yaml:"min_transfer"` // Transfer amount less than this, after the rules of the source, is not transferred.
+	MinOrder    bybit.Amount `yaml:"min_order"`    // Order of less contracts, or less coins for linear, is not placed.
+	Reserve     bybit.Amount `yaml:"reserve"`      // Amount of the coin kept unhedged.
+	MaxNotional bybit.Amount `yaml:"max_notional"` // Max value of an order in USD; unlimited if 0.
+
+	Transfer CoinTransferConfig `yaml:"transfer"`
+}
 
-	Store struct {
-		Enabled bool   `yaml:"enabled"`
-		Path    string `yaml:"path"`
+type CoinTransferConfig struct {
+	From []string `yaml:"from"` // Usernames in `.transfer.from`; all of them if empty.
+}
+
+// coinConfig is `CoinConfig` without its methods.
+type coinConfig CoinConfig
+
+func (c *CoinConfig) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*c = CoinConfig{}
+		return node.Decode(&c.Coin)
 	}
+
+	return node.Decode((*coinConfig)(c))
+}
+
+func (CoinConfig) jsonSchema() map[string]any {
+	return map[string]any{
+		"anyOf": []any{
+			map[string]any{"type": "string"},
+			schemaOf(reflect.TypeOf(coinConfig{}), ""),
+		},
+	}
+}
+
+func (c *CoinConfig) Symbol() bybit.Symbol {
+	if c.Product == bybit.ProductTypeLinear {
+		return c.Coin.LinPerpetual()
+	}
+	return c.Coin.InvPerceptual()
 }
 
 type AccountDescription struct {
-	Nickname string `yaml:"nickname"`
-	Username string `yaml:"username"`
+	Nickname    string            `yaml:"nickname"`
+	Username    string            `yaml:"username"`
+	AccountType bybit.AccountType `yaml:"account_type" enum:"UNIFIED,FUND,CONTRACT"` // "UNIFIED" if not given.
+}
+
+// TransferSource is an account whose balances are transferred to `transfer.to`.
+// Sub accounts can be selected by a selector instead of the username.
+type TransferSource struct {
+	AccountDescription `yaml:",inline"`
+	SubAccountSelector `yaml:",inline"`
+	TransferRule       `yaml:",inline"`
+}
+
+// TransferRule limits the amount transferred from a source.
+// Whole balance is transferred if no rule is given.
+type TransferRule struct {
+	Keep    map[bybit.Coin]bybit.Amount `yaml:"keep"`    // Amount of the coin left in the source.
+	Above   map[bybit.Coin]bybit.Amount `yaml:"above"`   // Transfers only if the balance is above this.
+	Percent float64                     `yaml:"percent"` // Transfers only this percent of the balance; 100 if 0.
+	Funding bool                        `yaml:"funding"` // Transfers only the funding income earned since the last run; income not transferred is carried over.
+}
+
+func (r *TransferRule) String(coin bybit.Coin) string {
+	vs := []string{}
+	if v, ok := r.Keep[coin]; ok {
+		vs = append(vs, fmt.Sprintf("keep %s", v))
+	}
+	if v, ok := r.Above[coin]; ok {
+		vs = append(vs, fmt.Sprintf("above %s", v))
+	}
+	if r.Percent > 0 && r.Percent < 100 {
+		vs = append(vs, fmt.Sprintf("%s%%", strconv.FormatFloat(r.Percent, 'f', -1, 64)))
+	}
+	if r.Funding {
+		vs = append(vs, "funding")
+	}
+
+	return strings.Join(vs, ", ")
 }
 
 type TransferConfig struct {
-	Enabled bool                 `yaml:"enabled"`
-	From    []AccountDescription `yaml:"from"`
-	To      AccountDescription   `yaml:"to"`
+	Enabled bool               `yaml:"enabled"`
+	From    []TransferSource   `yaml:"from"`
+	To      AccountDescription `yaml:"to"`
+
+	// Keeps the time of the last transfer of funding income.
+	StatePath string `yaml:"state_path"`
+}
+
+// interTransfers reports whether balances are moved between accounts of the UID of `to`
+// by the sources given by username, which needs "AccountTransfer" permission.
+func (c *TransferConfig) interTransfers() bool {
+	if c.To.AccountType != bybit.AccountTypeUnified {
+		return true
+	}
+	return slices.ContainsFunc(c.From, func(v TransferSource) bool {
+		return v.Username == c.To.Username
+	})
+}
+
+type LockConfig struct {
+	Path string `yaml:"path"`
+	Wait bool   `yaml:"wait"` // Block until the lock is released instead of fail.
 }
 
 type LogConfig struct {
 	Enabled bool     `yaml:"enabled"`
-	Format  string   `yaml:"format"` // "text" | "json"
+	Format  string   `yaml:"format" enum:"text,json"`
 	Output  []string `yaml:"output"` // filepath | "$STDOUT" | "$STDERR"
 }
 
 type MiscConfig struct {
-	UseColorOutput string `yaml:"use_color_output"` // "auto" | "always" | "never"
+	UseColorOutput string `yaml:"use_color_output" enum:"auto,always,never"`
+	OrderTransport string `yaml:"order_transport" enum:"rest,websocket"` // Transport orders are placed by.
+}
+
+// CassetteConfig records REST interactions of a run into a file or replays them.
+// Streams are not used while recording or replaying so the same requests are made.
+type CassetteConfig struct {
+	Mode string `yaml:"mode" enum:"off,record,replay"`
+	Path string `yaml:"path"`
 }
 
 type DebugConfig struct {
@@ -63,6 +255,10 @@ type DebugConfig struct {
 	IgnoreChecklist bool `yaml:"ignore_checklist"`
 	SkipTransaction bool `yaml:"skip_transaction"`
 	SkipTransfer    bool `yaml:"skip_transfer"`
+
+	// Endpoint replaces the REST endpoint of the main net, e.g. with a fake server.
+	// WebSocket streams are connected to the same host.
+	Endpoint string `yaml:"endpoint"`
 }
 
 func (c *LogConfig) NewLogger() (*slog.Logger, error) {
@@ -103,52 +299,324 @@ func (c *LogConfig) NewLogger() (*slog.Logger, error) {
 	return logger, nil
 }
 
-func ReadConfig(path string) (*Config, error) {
-	conf := &Config{path: path}
+func withLogger(ctx context.Context, conf *Config) (context.Context, error) {
+	l, err := conf.Log.NewLogger()
+	if err != nil {
+		return nil, fmt.Errorf("create logger: %w", err)
+	}
+
+	l.Info("read config", slog.String("path", conf.path), slog.String("profile", conf.profile))
+	return log.Into(ctx, l), nil
+}
+
+// ReadConfig reads the config at given path.
+// Fields of the profile, if given, override the ones at the top level
+// and env vars prefixed by `EnvPrefix` override them again.
+// "${VAR}" in values are replaced by env vars.
+// Problems in the config are reported as `*ConfigError`.
+func ReadConfig(path string, profile string) (*Config, error) {
+	conf := &Config{path: path, profile: profile, locs: map[string]string{}}
 
 	data, err := os.ReadFile(conf.path)
 	if err != nil {
 		return nil, fmt.Errorf("read config at %s: %w", conf.path, err)
 	}
-	if err := yaml.Unmarshal(data, &conf); err != nil {
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
 		return nil, fmt.Errorf("unmarshal config at %s: %w", conf.path, err)
 	}
+
+	// Unknown fields are ignored by decoding so they are reported
+	// together with the problems found by validation.
+	unknowns := []error{}
+	errs := []error{}
+	if len(doc.Content) > 0 {
+		root := doc.Content[0]
+		conf_t := reflect.TypeOf(conf).Elem()
+
+		profiles := popMappingValue(root, "profiles")
+		unknowns = append(unknowns, checkKnownFields(root, conf_t, "")...)
+		if profiles != nil {
+			unknowns = append(unknowns, checkKnownFields(profiles, reflect.MapOf(reflect.TypeOf(""), conf_t), "profiles")...)
+		}
+		if err := applyProfile(root, profiles, profile); err != nil {
+			errs = append(errs, err)
+		}
+		errs = append(errs, unjoin(interpolate(root, os.LookupEnv))...)
+
+		locateNodes(root, "", conf.locs)
+		if err := root.Decode(conf); err != nil {
+			var type_err *yaml.TypeError
+			if errors.As(err, &type_err) {
+				for _, e := range type_err.Errors {
+					errs = append(errs, errors.New(e))
+				}
+			} else {
+				errs = append(errs, err)
+			}
+		}
+	} else if profile != "" {
+		errs = append(errs, fmt.Errorf("profile %s not found: no profiles are defined", profile))
+	}
+	errs = append(errs, unjoin(applyEnvOverrides(conf, os.LookupEnv))...)
+	if len(errs) > 0 {
+		return nil, &ConfigError{Path: conf.path, Errs: append(unknowns, errs...)}
+	}
 	if !conf.Transfer.Enabled {
 		conf.Transfer.From = nil
 	}
 
+	if conf.Secret.ApiKey.Provider == "" && conf.Secret.ApiKeyFile != "" {
+		conf.Secret.ApiKey = SecretSourceConfig{Provider: "file", Path: conf.Secret.ApiKeyFile}
+		if loc, ok := conf.locs["secret.api_key_file"]; ok {
+			conf.locs["secret.api_key.path"] = loc
+		}
+	}
+	if conf.Secret.PrivateKey.Provider == "" && conf.Secret.PrivateKeyFile != "" {
+		conf.Secret.PrivateKey = SecretSourceConfig{Provider: "file", Path: conf.Secret.PrivateKeyFile}
+		if loc, ok := conf.locs["secret.private_key_file"]; ok {
+			conf.locs["secret.private_key.path"] = loc
+		}
+	}
+	defaultV(&conf.Secret.Signer.Encoding, "base64")
+	defaultV(&conf.Secret.Store.Provider, "file")
+	defaultV(&conf.Secret.Store.Encryption.Type, "none")
+	switch conf.Secret.Store.Encryption.Type {
+	case "passphrase":
+		defaultV(&conf.Secret.Store.Encryption.Env, "TINY_SHORT_STORE_PASSPHRASE")
+	case "env":
+		defaultV(&conf.Secret.Store.Encryption.Env, "TINY_SHORT_STORE_KEY")
+	}
+	for i := range conf.Coins {
+		defaultV(&conf.Coins[i].Product, bybit.ProductTypeInverse)
+		defaultV(&conf.Coins[i].Mode, "short")
+	}
+	defaultV(&conf.Transfer.To.AccountType, bybit.AccountTypeUnified)
+	for i := range conf.Transfer.From {
+		defaultV(&conf.Transfer.From[i].AccountType, bybit.AccountTypeUnified)
+	}
+	defaultV(&conf.Transfer.StatePath, ".tiny-short.state.json")
+	defaultV(&conf.Lock.Path, ".tiny-short.lock")
 	defaultV(&conf.Log.Format, "text")
 	defaultV(&conf.Misc.UseColorOutput, "auto")
+	defaultV(&conf.Misc.OrderTransport, "rest")
+	defaultV(&conf.Cassette.Mode, "off")
 
 	conf.Log.Output = removeDuplicate(conf.Log.Output)
 
+	if errs := append(unknowns, conf.validate()...); len(errs) > 0 {
+		return nil, &ConfigError{Path: conf.path, Errs: errs}
+	}
+
+	return conf, nil
+}
+
+// Coins are symbols in upper case, e.g. "BTC".
+var coinPattern = regexp.MustCompile(`^[A-Z0-9]+$`)
+
+func (c *Config) validate() []error {
 	errs := []error{}
-	if !fileExists(conf.Secret.ApiKeyFile) {
-		errs = append(errs, errors.New(".api_key_file: file not exist"))
+	errorf := func(path string, format string, args ...any) {
+		errs = append(errs, c.errorAt(path, fmt.Errorf(format, args...)))
+	}
+	for _, err := range c.Secret.ApiKey.validate("secret.api_key") {
+		errs = append(errs, c.errorAt("secret.api_key", err))
+	}
+
+	switch c.Secret.Type {
+	case bybit.SecretTypeRsa, bybit.SecretTypeHmac:
+	case "":
+		errorf("secret.type", ".secret.type cannot be empty")
+	default:
+		errorf("secret.type", `.secret.type must be one of "RSA" or "HMAC": %s`, c.Secret.Type)
+	}
+	if len(c.Secret.Signer.Command) == 0 {
+		for _, err := range c.Secret.PrivateKey.validate("secret.private_key") {
+			errs = append(errs, c.errorAt("secret.private_key", err))
+		}
+	} else {
+		if c.Secret.Type != bybit.SecretTypeRsa {
+			errorf("secret.signer", `.secret.signer requires .secret.type to be "RSA"`)
+		}
+		if !slices.Contains([]string{"base64", "raw"}, c.Secret.Signer.Encoding) {
+			errorf("secret.signer.encoding", `.secret.signer.encoding must be one of "base64" or "raw": %s`, c.Secret.Signer.Encoding)
+		}
+	}
+	if c.Secret.PrivateKeyPassphrase.Provider != "" {
+		for _, err := range c.Secret.PrivateKeyPassphrase.validate("secret.private_key_passphrase") {
+			errs = append(errs, c.errorAt("secret.private_key_passphrase", err))
+		}
+	}
+	if c.Secret.Store.Enabled {
+		for _, err := range c.Secret.Store.SecretSourceConfig.validate("secret.store") {
+			errs = append(errs, c.errorAt("secret.store", err))
+		}
+		if slices.Contains([]string{"env", "systemd-creds"}, c.Secret.Store.Provider) {
+			// Keys created during a run could not be saved.
+			errorf("secret.store.provider", `.secret.store.provider cannot be read-only: %s`, c.Secret.Store.Provider)
+		}
+	}
+	if !slices.Contains([]string{"none", "passphrase", "env", "rsa"}, c.Secret.Store.Encryption.Type) {
+		errorf("secret.store.encryption.type", `.secret.store.encryption.type must be one of "none", "passphrase", "env", or "rsa": %s`, c.Secret.Store.Encryption.Type)
+	}
+	if c.Secret.Store.Encryption.Type == "rsa" && c.Secret.Type != bybit.SecretTypeRsa {
+		errorf("secret.store.encryption.type", `.secret.store.encryption.type "rsa" requires .secret.type to be "RSA"`)
 	}
-	if !fileExists(conf.Secret.PrivateKeyFile) {
-		errs = append(errs, errors.New(".private_key_file: file not exist"))
+
+	if len(c.Coins) == 0 {
+		errorf("coins", ".coins cannot be empty")
+	}
+	for i, coin := range c.Coins {
+		path := fmt.Sprintf("coins[%d]", i)
+		if !coinPattern.MatchString(string(coin.Coin)) {
+			errorf(path+".coin", ".%s.coin must be a symbol in upper case, e.g. \"BTC\": %q", path, coin.Coin)
+		} else if slices.ContainsFunc(c.Coins[:i], func(v CoinConfig) bool { return v.Coin == coin.Coin }) {
+			errorf(path+".coin", ".%s.coin is duplicated: %s", path, coin.Coin)
+		}
+		if !slices.Contains([]bybit.ProductType{bybit.ProductTypeInverse, bybit.ProductTypeLinear}, coin.Product) {
+			errorf(path+".product", `.%s.product must be one of "inverse" or "linear": %s`, path, coin.Product)
+		}
+		if !slices.Contains([]string{"short", "transfer"}, coin.Mode) {
+			errorf(path+".mode", `.%s.mode must be one of "short" or "transfer": %s`, path, coin.Mode)
+		}
+		for _, v := range []struct {
+			key string
+			v   bybit.Amount
+		}{
+			{"min_transfer", coin.MinTransfer},
+			{"min_order", coin.MinOrder},
+			{"reserve", coin.Reserve},
+			{"max_notional", coin.MaxNotional},
+		} {
+			if v.v < 0 {
+				errorf(path+"."+v.key, ".%s.%s cannot be negative: %s", path, v.key, v.v)
+			}
+		}
+		for j, username := range coin.Transfer.From {
+			if !c.Transfer.Enabled {
+				break
+			}
+			// Usernames selected are known only at run.
+			if !slices.ContainsFunc(c.Transfer.From, func(v TransferSource) bool {
+				return v.Username == username || v.SubAccountSelector.mayMatch(username)
+			}) {
+				p := fmt.Sprintf("%s.transfer.from[%d]", path, j)
+				errorf(p, ".%s must be one of usernames in .transfer.from: %s", p, username)
+			}
+		}
+	}
+
+	if !slices.Contains([]string{"text", "json"}, c.Log.Format) {
+		errorf("log.format", `.log.format must be one of "text" or "json": %s`, c.Log.Format)
+	}
+	for i, o := range c.Log.Output {
+		if strings.HasPrefix(o, "$") && o != "$STDOUT" && o != "$STDERR" {
+			errorf(fmt.Sprintf("log.output[%d]", i), `.log.output[%d] must be a file path, "$STDOUT", or "$STDERR": %s`, i, o)
+		}
 	}
-	if !slices.Contains([]string{"text", "json"}, conf.Log.Format) {
-		errs = append(errs, fmt.Errorf(`.log.format must be one of "text" or "json": %s`, conf.Log.Format))
+	if !slices.Contains([]string{"auto", "always", "never"}, c.Misc.UseColorOutput) {
+		errorf("misc.use_color_output", `.misc.use_color_output must be one of "auto", "always", or "never": %s`, c.Misc.UseColorOutput)
 	}
-	if !slices.Contains([]string{"auto", "always", "never"}, conf.Misc.UseColorOutput) {
-		conf.Misc.UseColorOutput = "auto"
+	if !slices.Contains([]string{"rest", "websocket"}, c.Misc.OrderTransport) {
+		errorf("misc.order_transport", `.misc.order_transport must be one of "rest" or "websocket": %s`, c.Misc.OrderTransport)
 	}
-	if conf.Transfer.Enabled && conf.Transfer.To.Username == "" {
-		errs = append(errs, fmt.Errorf(`".move.to.username" cannot be empty if ".move.enabled" is true`))
+	if !slices.Contains([]string{"off", "record", "replay"}, c.Cassette.Mode) {
+		errorf("cassette.mode", `.cassette.mode must be one of "off", "record", or "replay": %s`, c.Cassette.Mode)
+	} else if c.Cassette.Mode != "off" && c.Cassette.Path == "" {
+		errorf("cassette.path", ".cassette.path cannot be empty if .cassette.mode is %q", c.Cassette.Mode)
 	}
-	for _, v := range conf.Transfer.From {
-		if v.Username == "" {
-			errs = append(errs, fmt.Errorf(`".move.from[].username" cannot be empty`))
+	if c.Debug.Endpoint != "" {
+		if u, err := url.Parse(c.Debug.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errorf("debug.endpoint", ".debug.endpoint must be an HTTP(S) URL: %s", c.Debug.Endpoint)
 		}
 	}
 
-	if len(errs) != 0 {
-		return nil, errors.Join(errs...)
+	if c.Transfer.Enabled {
+		if c.Transfer.To.Username == "" {
+			errorf("transfer.to.username", ".transfer.to.username cannot be empty if .transfer.enabled is true")
+		}
+
+		account_types := []bybit.AccountType{bybit.AccountTypeUnified, bybit.AccountTypeFund, bybit.AccountTypeContract}
+		if !slices.Contains(account_types, c.Transfer.To.AccountType) {
+			errorf("transfer.to.account_type", `.transfer.to.account_type must be one of "UNIFIED", "FUND", or "CONTRACT": %s`, c.Transfer.To.AccountType)
+		}
+		for i, v := range c.Transfer.From {
+			if !slices.Contains(account_types, v.AccountType) {
+				errorf(fmt.Sprintf("transfer.from[%d].account_type", i), `.transfer.from[%d].account_type must be one of "UNIFIED", "FUND", or "CONTRACT": %s`, i, v.AccountType)
+			}
+		}
+
+		usernames := map[string]string{}
+		// Same account can be given with different account types,
+		// e.g. to transfer from FUND to UNIFIED of the main account.
+		check := func(path string, username string, account_type bybit.AccountType) {
+			if username == "" {
+				errorf(path, ".%s cannot be empty", path)
+				return
+			}
+			if strings.HasPrefix(username, "$") && username != "$MAIN" {
+				errorf(path, `.%s must be a username or "$MAIN": %s`, path, username)
+				return
+			}
+			k := fmt.Sprintf("%s/%s", username, account_type)
+			if p, ok := usernames[k]; ok {
+				errorf(path, ".%s is duplicated with .%s: %s", path, p, username)
+				return
+			}
+			usernames[k] = path
+		}
+		if c.Transfer.To.Username != "" {
+			check("transfer.to.username", c.Transfer.To.Username, c.Transfer.To.AccountType)
+		}
+		for i, v := range c.Transfer.From {
+			path := fmt.Sprintf("transfer.from[%d]", i)
+			switch {
+			case v.SubAccountSelector.IsEmpty():
+				if len(v.Exclude) > 0 {
+					errorf(path+".exclude", ".%s.exclude requires a selector", path)
+				}
+				check(path+".username", v.Username, v.AccountType)
+			case v.Username != "":
+				errorf(path+".username", ".%s.username cannot be given with a selector", path)
+			default:
+				for _, err := range v.SubAccountSelector.validate(path) {
+					errs = append(errs, c.errorAt(path, err))
+				}
+			}
+			if v.Funding && v.AccountType != bybit.AccountTypeUnified {
+				// Funding income is read from the transaction log of the unified account.
+				errorf(path+".funding", `.%s.funding requires .%s.account_type to be "UNIFIED": %s`, path, path, v.AccountType)
+			}
+			if v.Percent < 0 || v.Percent > 100 {
+				errorf(path+".percent", ".%s.percent must be in [0, 100] where 0 is 100: %s", path, strconv.FormatFloat(v.Percent, 'f', -1, 64))
+			}
+			for _, rule := range []struct {
+				key string
+				vs  map[bybit.Coin]bybit.Amount
+			}{
+				{"keep", v.Keep},
+				{"above", v.Above},
+			} {
+				coins := []bybit.Coin{}
+				for coin := range rule.vs {
+					coins = append(coins, coin)
+				}
+				slices.Sort(coins)
+				for _, coin := range coins {
+					amount := rule.vs[coin]
+					p := fmt.Sprintf("%s.%s.%s", path, rule.key, coin)
+					if !slices.ContainsFunc(c.Coins, func(v CoinConfig) bool { return v.Coin == coin }) {
+						errorf(p, ".%s is not in .coins", p)
+					} else if amount < 0 {
+						errorf(p, ".%s cannot be negative: %s", p, amount)
+					}
+				}
+			}
+		}
 	}
 
-	return conf, nil
+	return errs
 }
 
 func defaultV[T comparable](target *T, v T) {
@@ -158,15 +626,6 @@ func defaultV[T comparable](target *T, v T) {
 	}
 }
 
-func fileExists(p string) bool {
-	if p == "" {
-		return false
-	}
-
-	_, err := os.Stat(p)
-	return !os.IsNotExist(err)
-}
-
 func removeDuplicate[T comparable](sliceList []T) []T {
 	allKeys := make(map[T]bool)
 	list := []T{}
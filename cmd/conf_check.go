@@ -0,0 +1,168 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigError holds every problem found in the config.
+type ConfigError struct {
+	Path string
+	Errs []error
+}
+
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("invalid config at %s:\n%s", e.Path, errors.Join(e.Errs...))
+}
+
+func (e *ConfigError) Unwrap() []error {
+	return e.Errs
+}
+
+// unjoin returns errors joined by `errors.Join`.
+func unjoin(err error) []error {
+	if err == nil {
+		return nil
+	}
+	if e, ok := err.(interface{ Unwrap() []error }); ok {
+		return e.Unwrap()
+	}
+	return []error{err}
+}
+
+// popMappingValue removes the key from the mapping node and returns its value.
+func popMappingValue(node *yaml.Node, key string) *yaml.Node {
+	i, v := mappingValue(node, key)
+	if i < 0 {
+		return nil
+	}
+
+	node.Content = append(node.Content[:i], node.Content[i+2:]...)
+	return v
+}
+
+var unmarshalerType = reflect.TypeOf((*yaml.Unmarshaler)(nil)).Elem()
+
+// checkKnownFields reports keys in the node that do not match any field of the type.
+// It works as `yaml.Decoder.KnownFields` which is not available for `yaml.Node.Decode`
+// but it reports every unknown key instead of the first one.
+func checkKnownFields(node *yaml.Node, t reflect.Type, path string) []error {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if reflect.PointerTo(t).Implements(unmarshalerType) && !(t.Kind() == reflect.Struct && node.Kind == yaml.MappingNode) {
+		// Types that unmarshal themselves are checked only if they are given as a mapping.
+		return nil
+	}
+
+	errs := []error{}
+	switch {
+	case t.Kind() == reflect.Struct && node.Kind == yaml.MappingNode:
+		fields := map[string]reflect.Type{}
+		collectFields(t, fields)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			k, v := node.Content[i], node.Content[i+1]
+			ft, ok := fields[k.Value]
+			if !ok {
+				errs = append(errs, fmt.Errorf("%d:%d: unknown field %q in .%s", k.Line, k.Column, k.Value, path))
+				continue
+			}
+			errs = append(errs, checkKnownFields(v, ft, joinPath(path, k.Value))...)
+		}
+
+	case t.Kind() == reflect.Slice && node.Kind == yaml.SequenceNode:
+		for i, v := range node.Content {
+			errs = append(errs, checkKnownFields(v, t.Elem(), fmt.Sprintf("%s[%d]", path, i))...)
+		}
+
+	case t.Kind() == reflect.Map && node.Kind == yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			k, v := node.Content[i], node.Content[i+1]
+			errs = append(errs, checkKnownFields(v, t.Elem(), joinPath(path, k.Value))...)
+		}
+	}
+
+	return errs
+}
+
+func collectFields(t reflect.Type, fields map[string]reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		}
+		if opts == "inline" {
+			collectFields(f.Type, fields)
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		fields[name] = f.Type
+	}
+}
+
+func joinPath(path string, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// locateNodes maps paths of the values, e.g. "transfer.from[1].username",
+// to their locations in the form of "line:column".
+func locateNodes(node *yaml.Node, path string, locs map[string]string) {
+	if path != "" {
+		locs[path] = fmt.Sprintf("%d:%d", node.Line, node.Column)
+	}
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			locateNodes(node.Content[i+1], joinPath(path, node.Content[i].Value), locs)
+		}
+	case yaml.SequenceNode:
+		for i, v := range node.Content {
+			locateNodes(v, fmt.Sprintf("%s[%d]", path, i), locs)
+		}
+	}
+}
+
+// errorAt prefixes the location of the value at the path to the error.
+// The location of the closest parent is used if the value is not in the config,
+// e.g. it is omitted or overridden by an env var.
+func (c *Config) errorAt(path string, err error) error {
+	for p := path; ; {
+		if loc, ok := c.locs[p]; ok {
+			return fmt.Errorf("%s: %w", loc, err)
+		}
+		if p == "" {
+			return err
+		}
+
+		i := strings.LastIndexAny(p, ".[")
+		if i < 0 {
+			p = ""
+		} else {
+			p = p[:i]
+		}
+	}
+}
+
+// setOverridden marks the value at the path as overridden by the env var.
+func (c *Config) setOverridden(path string, env string) {
+	for p := range c.locs {
+		if strings.HasPrefix(p, path+".") || strings.HasPrefix(p, path+"[") {
+			delete(c.locs, p)
+		}
+	}
+	c.locs[path] = "env " + env
+}
@@ -0,0 +1,195 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Prefix of env vars that override config fields,
+// e.g. `TINY_SHORT_TRANSFER_ENABLED=false` overrides `.transfer.enabled`.
+const EnvPrefix = "TINY_SHORT_"
+
+// Matches "${VAR}", "${VAR:-default}", and "$${" that escapes "${".
+var interpolationPattern = regexp.MustCompile(`\$\$\{|\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// interpolate replaces "${VAR}" in scalar values with the value of env var.
+// Note that "$MAIN" or "$STDOUT" are not interpolated since they are not in braces.
+func interpolate(node *yaml.Node, lookup func(string) (string, bool)) error {
+	errs := []error{}
+	var walk func(n *yaml.Node)
+	walk = func(n *yaml.Node) {
+		if n.Kind != yaml.ScalarNode {
+			for _, c := range n.Content {
+				walk(c)
+			}
+			return
+		}
+		if !strings.Contains(n.Value, "${") {
+			return
+		}
+
+		v := interpolationPattern.ReplaceAllStringFunc(n.Value, func(s string) string {
+			if s == "$${" {
+				return "${"
+			}
+
+			m := interpolationPattern.FindStringSubmatch(s)
+			if v, ok := lookup(m[1]); ok {
+				return v
+			}
+			if m[2] != "" {
+				return m[3]
+			}
+
+			errs = append(errs, fmt.Errorf("%d:%d: env %s not set", n.Line, n.Column, m[1]))
+			return ""
+		})
+
+		n.Value = v
+		if n.Style&(yaml.SingleQuotedStyle|yaml.DoubleQuotedStyle|yaml.LiteralStyle|yaml.FoldedStyle) == 0 {
+			// Resolves the tag again so "${ENABLED}" can be a boolean.
+			n.Tag = ""
+		}
+	}
+	walk(node)
+
+	return errors.Join(errs...)
+}
+
+// mappingValue returns the value node of the key in the mapping node.
+func mappingValue(node *yaml.Node, key string) (int, *yaml.Node) {
+	if node.Kind != yaml.MappingNode {
+		return -1, nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return i, node.Content[i+1]
+		}
+	}
+
+	return -1, nil
+}
+
+// applyProfile merges the profile of given name into the root.
+// Mappings are merged recursively and other values are replaced.
+func applyProfile(root *yaml.Node, profiles *yaml.Node, name string) error {
+	if name == "" {
+		return nil
+	}
+	if profiles == nil {
+		return fmt.Errorf("profile %s not found: no profiles are defined", name)
+	}
+
+	_, profile := mappingValue(profiles, name)
+	if profile == nil {
+		return fmt.Errorf("profile %s not found", name)
+	}
+	if profile.Kind != yaml.MappingNode {
+		return fmt.Errorf("%d:%d: profile %s must be a mapping", profile.Line, profile.Column, name)
+	}
+
+	mergeNode(root, profile)
+	return nil
+}
+
+func mergeNode(dst *yaml.Node, src *yaml.Node) {
+	if dst.Kind != yaml.MappingNode || src.Kind != yaml.MappingNode {
+		*dst = *src
+		return
+	}
+
+	for i := 0; i+1 < len(src.Content); i += 2 {
+		k, v := src.Content[i], src.Content[i+1]
+		if _, d := mappingValue(dst, k.Value); d != nil {
+			mergeNode(d, v)
+		} else {
+			dst.Content = append(dst.Content, k, v)
+		}
+	}
+}
+
+// applyEnvOverrides sets fields of the config by env vars
+// named by the path of the field, e.g. `TINY_SHORT_LOG_FORMAT` for `.log.format`.
+// Values are parsed as YAML so lists can be given like "[BTC, SOL]" or "BTC,SOL".
+func applyEnvOverrides(conf *Config, lookup func(string) (string, bool)) error {
+	errs := []error{}
+	var walk func(v reflect.Value, path []string)
+	walk = func(v reflect.Value, path []string) {
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+
+			name, opts, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+			if name == "-" {
+				continue
+			}
+			if opts == "inline" {
+				walk(v.Field(i), path)
+				continue
+			}
+			if name == "" {
+				name = strings.ToLower(f.Name)
+			}
+
+			p := append(append([]string{}, path...), name)
+			fv := v.Field(i)
+			if fv.Kind() == reflect.Struct && !implementsUnmarshaler(fv) {
+				walk(fv, p)
+				continue
+			}
+
+			env := EnvPrefix + strings.ToUpper(strings.Join(p, "_"))
+			s, ok := lookup(env)
+			if !ok {
+				continue
+			}
+			if err := setFromEnv(fv, s); err != nil {
+				errs = append(errs, fmt.Errorf("env %s: %w", env, err))
+				continue
+			}
+			conf.setOverridden(strings.Join(p, "."), env)
+		}
+	}
+	walk(reflect.ValueOf(conf).Elem(), nil)
+
+	return errors.Join(errs...)
+}
+
+func implementsUnmarshaler(v reflect.Value) bool {
+	_, ok := v.Addr().Interface().(yaml.Unmarshaler)
+	return ok
+}
+
+func setFromEnv(v reflect.Value, s string) error {
+	nv := reflect.New(v.Type())
+	err := yaml.Unmarshal([]byte(s), nv.Interface())
+	if err != nil && v.Kind() == reflect.Slice {
+		// Try comma separated list.
+		items := strings.Split(s, ",")
+		for i := range items {
+			items[i] = strings.TrimSpace(items[i])
+		}
+
+		data, err_ := yaml.Marshal(items)
+		if err_ != nil {
+			return err
+		}
+
+		nv = reflect.New(v.Type())
+		err = yaml.Unmarshal(data, nv.Interface())
+	}
+	if err != nil {
+		return err
+	}
+
+	v.Set(nv.Elem())
+	return nil
+}
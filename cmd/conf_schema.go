@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Matches a value to be interpolated, e.g. "${ENABLED}", so it can be given
+// to a field that is not a string.
+const interpolationSchemaPattern = `^\$\{[A-Za-z_][A-Za-z0-9_]*(:-[^}]*)?\}$`
+
+// ConfigSchema returns the JSON Schema of the config file.
+// Values allowed for a field are given by its `enum` tag, e.g. `enum:"text,json"`.
+func ConfigSchema() ([]byte, error) {
+	schema := schemaOf(reflect.TypeOf(Config{}), "")
+	schema["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	schema["title"] = "tiny-short config"
+	schema["$defs"] = map[string]any{
+		"interpolation": map[string]any{
+			"type":    "string",
+			"pattern": interpolationSchemaPattern,
+		},
+	}
+
+	// Profiles override any field at the top level.
+	props := schema["properties"].(map[string]any)
+	props["profiles"] = map[string]any{
+		"type":                 "object",
+		"additionalProperties": map[string]any{"$ref": "#"},
+	}
+
+	data, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("marshal schema: %w", err)
+	}
+
+	return append(data, '\n'), nil
+}
+
+// Types that unmarshal themselves describe their schema.
+type jsonSchemer interface {
+	jsonSchema() map[string]any
+}
+
+var jsonSchemerType = reflect.TypeOf((*jsonSchemer)(nil)).Elem()
+
+func schemaOf(t reflect.Type, enum string) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Implements(jsonSchemerType) {
+		return reflect.Zero(t).Interface().(jsonSchemer).jsonSchema()
+	}
+
+	var s map[string]any
+	switch t.Kind() {
+	case reflect.Struct:
+		props := map[string]any{}
+		schemaProperties(t, props)
+		return map[string]any{
+			"type":                 "object",
+			"properties":           props,
+			"additionalProperties": false,
+		}
+
+	case reflect.Slice, reflect.Array:
+		return map[string]any{
+			"type":  "array",
+			"items": schemaOf(t.Elem(), enum),
+		}
+
+	case reflect.Map:
+		return map[string]any{
+			"type":                 "object",
+			"additionalProperties": schemaOf(t.Elem(), enum),
+		}
+
+	case reflect.String:
+		s = map[string]any{"type": "string"}
+		if enum == "" {
+			// Interpolation is a string anyway.
+			return s
+		}
+		s["enum"] = strings.Split(enum, ",")
+
+	case reflect.Bool:
+		s = map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		s = map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		s = map[string]any{"type": "number"}
+	default:
+		panic(fmt.Sprintf("schema for %s is not supported", t))
+	}
+
+	return map[string]any{
+		"anyOf": []any{s, map[string]any{"$ref": "#/$defs/interpolation"}},
+	}
+}
+
+func schemaProperties(t reflect.Type, props map[string]any) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		}
+		if opts == "inline" {
+			schemaProperties(f.Type, props)
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		props[name] = schemaOf(f.Type, f.Tag.Get("enum"))
+	}
+}
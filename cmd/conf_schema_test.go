@@ -0,0 +1,48 @@
+package cmd_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/lesomnus/tiny-short/cmd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigSchema(t *testing.T) {
+	require := require.New(t)
+
+	expected, err := os.ReadFile("../tiny-short.schema.json")
+	require.NoError(err)
+
+	actual, err := cmd.ConfigSchema()
+	require.NoError(err)
+	require.True(bytes.Equal(expected, actual), "schema is out of date; run `go run . config schema > tiny-short.schema.json`")
+}
+
+func TestConfigSchemaEnumInterpolation(t *testing.T) {
+	require := require.New(t)
+
+	data, err := cmd.ConfigSchema()
+	require.NoError(err)
+
+	var schema struct {
+		Properties struct {
+			Misc struct {
+				Properties struct {
+					UseColorOutput struct {
+						AnyOf []map[string]any `json:"anyOf"`
+					} `json:"use_color_output"`
+				} `json:"properties"`
+			} `json:"misc"`
+		} `json:"properties"`
+	}
+	require.NoError(json.Unmarshal(data, &schema))
+
+	// Enum field accepts `${VAR}` as the loader does.
+	any_of := schema.Properties.Misc.Properties.UseColorOutput.AnyOf
+	require.Len(any_of, 2)
+	require.Equal([]any{"auto", "always", "never"}, any_of[0]["enum"])
+	require.Equal("#/$defs/interpolation", any_of[1]["$ref"])
+}
@@ -0,0 +1,389 @@
+package cmd_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lesomnus/tiny-short/bybit"
+	"github.com/lesomnus/tiny-short/cmd"
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfig(t *testing.T, data string) string {
+	p := filepath.Join(t.TempDir(), "conf.yaml")
+	require.NoError(t, os.WriteFile(p, []byte(data), 0644))
+	return p
+}
+
+func coinsOf(conf *cmd.Config) []bybit.Coin {
+	coins := []bybit.Coin{}
+	for _, c := range conf.Coins {
+		coins = append(coins, c.Coin)
+	}
+	return coins
+}
+
+func TestReadConfig(t *testing.T) {
+	const base = `
+secret:
+  type: RSA
+  api_key_file: ${TEST_DIR}/api.key
+  private_key_file: ${TEST_DIR:-.}/key.pem
+
+coins: [BTC]
+
+transfer:
+  enabled: true
+  to:
+    username: $MAIN
+  from:
+    - username: foo
+
+profiles:
+  alice:
+    secret:
+      api_key_file: alice.key
+    coins: [SOL]
+    transfer:
+      enabled: false
+`
+
+	t.Run("interpolate", func(t *testing.T) {
+		require := require.New(t)
+
+		t.Setenv("TEST_DIR", "/secrets")
+		conf, err := cmd.ReadConfig(writeConfig(t, base), "")
+		require.NoError(err)
+		require.Equal("/secrets/api.key", conf.Secret.ApiKey.Path)
+		require.Equal("/secrets/key.pem", conf.Secret.PrivateKey.Path)
+		require.Equal("$MAIN", conf.Transfer.To.Username)
+	})
+
+	t.Run("interpolate with default", func(t *testing.T) {
+		require := require.New(t)
+
+		_, err := cmd.ReadConfig(writeConfig(t, base), "")
+		require.ErrorContains(err, "TEST_DIR not set")
+
+		conf, err := cmd.ReadConfig(writeConfig(t, `
+secret:
+  type: RSA
+  api_key_file: api.key
+  private_key_file: ${TEST_DIR:-.}/key.pem
+coins: [BTC]
+`), "")
+		require.NoError(err)
+		require.Equal("./key.pem", conf.Secret.PrivateKey.Path)
+	})
+
+	t.Run("profile", func(t *testing.T) {
+		require := require.New(t)
+
+		t.Setenv("TEST_DIR", "/secrets")
+		conf, err := cmd.ReadConfig(writeConfig(t, base), "alice")
+		require.NoError(err)
+		require.Equal("alice.key", conf.Secret.ApiKey.Path)
+		require.Equal("/secrets/key.pem", conf.Secret.PrivateKey.Path)
+		require.Equal([]bybit.Coin{bybit.CoinSol}, coinsOf(conf))
+		require.False(conf.Transfer.Enabled)
+
+		_, err = cmd.ReadConfig(writeConfig(t, base), "bob")
+		require.ErrorContains(err, "profile bob not found")
+	})
+
+	t.Run("env overrides", func(t *testing.T) {
+		require := require.New(t)
+
+		t.Setenv("TEST_DIR", "/secrets")
+		t.Setenv("TINY_SHORT_TRANSFER_ENABLED", "false")
+		t.Setenv("TINY_SHORT_COINS", "BTC,SOL")
+		t.Setenv("TINY_SHORT_SECRET_API_KEY_FILE", "env.key")
+		t.Setenv("TINY_SHORT_SECRET_STORE_PATH", "store.json")
+		conf, err := cmd.ReadConfig(writeConfig(t, base), "alice")
+		require.NoError(err)
+		require.False(conf.Transfer.Enabled)
+		require.Equal([]bybit.Coin{bybit.CoinBtc, bybit.CoinSol}, coinsOf(conf))
+		require.Equal("store.json", conf.Secret.Store.Path)
+
+		// `api_key_file` is resolved into `api_key` after overrides are applied.
+		require.Equal("env.key", conf.Secret.ApiKey.Path)
+	})
+
+	t.Run("problems with location", func(t *testing.T) {
+		require := require.New(t)
+
+		_, err := cmd.ReadConfig(writeConfig(t, `
+secret:
+  type: RSA
+  api_key_file: api.key
+  privte_key_file: key.pem
+coins: [BTC, btc]
+transfer:
+  enabled: true
+  to:
+    username: $Main
+  from:
+    - username: foo
+    - username: foo
+`), "")
+
+		var conf_err *cmd.ConfigError
+		require.ErrorAs(err, &conf_err)
+
+		msgs := []string{}
+		for _, e := range conf_err.Errs {
+			msgs = append(msgs, e.Error())
+		}
+		require.Contains(msgs, `5:3: unknown field "privte_key_file" in .secret`)
+		require.Contains(msgs, `6:14: .coins[1].coin must be a symbol in upper case, e.g. "BTC": "btc"`)
+		require.Contains(msgs, `10:15: .transfer.to.username must be a username or "$MAIN": $Main`)
+		require.Contains(msgs, `13:17: .transfer.from[1].username is duplicated with .transfer.from[0].username: foo`)
+	})
+
+	t.Run("read-only store", func(t *testing.T) {
+		require := require.New(t)
+
+		_, err := cmd.ReadConfig(writeConfig(t, `
+secret:
+  type: RSA
+  api_key_file: api.key
+  private_key_file: key.pem
+  store:
+    enabled: true
+    provider: env
+    name: TINY_SHORT_STORE
+coins: [BTC]
+`), "")
+		require.ErrorContains(err, `8:15: .secret.store.provider cannot be read-only: env`)
+	})
+
+	t.Run("coin strategies", func(t *testing.T) {
+		require := require.New(t)
+
+		conf, err := cmd.ReadConfig(writeConfig(t, `
+secret:
+  type: RSA
+  api_key_file: api.key
+  private_key_file: key.pem
+coins:
+  - BTC
+  - coin: SOL
+    product: linear
+    reserve: 0.5
+    mode: transfer
+    transfer:
+      from: [foo]
+transfer:
+  enabled: true
+  to:
+    username: $MAIN
+  from:
+    - username: foo
+    - username: bar
+`), "")
+		require.NoError(err)
+		require.Equal([]cmd.CoinConfig{
+			{Coin: bybit.CoinBtc, Product: bybit.ProductTypeInverse, Mode: "short"},
+			{
+				Coin:     bybit.CoinSol,
+				Product:  bybit.ProductTypeLinear,
+				Mode:     "transfer",
+				Reserve:  0.5,
+				Transfer: cmd.CoinTransferConfig{From: []string{"foo"}},
+			},
+		}, conf.Coins)
+
+		_, err = cmd.ReadConfig(writeConfig(t, `
+secret:
+  type: RSA
+  api_key_file: api.key
+  private_key_file: key.pem
+coins:
+  - coin: SOL
+    produkt: linear
+    transfer:
+      from: [baz]
+transfer:
+  enabled: true
+  to:
+    username: $MAIN
+  from:
+    - username: foo
+`), "")
+		require.ErrorContains(err, `8:5: unknown field "produkt" in .coins[0]`)
+		require.ErrorContains(err, `10:14: .coins[0].transfer.from[0] must be one of usernames in .transfer.from: baz`)
+	})
+
+	t.Run("transfer rules", func(t *testing.T) {
+		require := require.New(t)
+
+		conf, err := cmd.ReadConfig(writeConfig(t, `
+secret:
+  type: RSA
+  api_key_file: api.key
+  private_key_file: key.pem
+coins: [BTC, SOL]
+transfer:
+  enabled: true
+  to:
+    username: $MAIN
+  from:
+    - username: foo
+      keep: { BTC: 0.1 }
+      percent: 50
+      funding: true
+`), "")
+		require.NoError(err)
+		require.Equal("keep 0.1, 50%, funding", conf.Transfer.From[0].TransferRule.String(bybit.CoinBtc))
+		require.Equal("50%, funding", conf.Transfer.From[0].TransferRule.String(bybit.CoinSol))
+
+		_, err = cmd.ReadConfig(writeConfig(t, `
+secret:
+  type: RSA
+  api_key_file: api.key
+  private_key_file: key.pem
+coins: [BTC]
+transfer:
+  enabled: true
+  to:
+    username: $MAIN
+  from:
+    - username: foo
+      keep: { ETH: 1 }
+      percent: 150
+`), "")
+		require.ErrorContains(err, `13:20: .transfer.from[0].keep.ETH is not in .coins`)
+		require.ErrorContains(err, `14:16: .transfer.from[0].percent must be in [0, 100] where 0 is 100: 150`)
+	})
+
+	t.Run("account types", func(t *testing.T) {
+		require := require.New(t)
+
+		conf, err := cmd.ReadConfig(writeConfig(t, `
+secret:
+  type: RSA
+  api_key_file: api.key
+  private_key_file: key.pem
+coins: [BTC]
+transfer:
+  enabled: true
+  to:
+    username: $MAIN
+  from:
+    - username: $MAIN
+      account_type: FUND
+    - username: foo
+`), "")
+		require.NoError(err)
+		require.Equal(bybit.AccountTypeUnified, conf.Transfer.To.AccountType)
+		require.Equal(bybit.AccountTypeFund, conf.Transfer.From[0].AccountType)
+		require.Equal(bybit.AccountTypeUnified, conf.Transfer.From[1].AccountType)
+
+		_, err = cmd.ReadConfig(writeConfig(t, `
+secret:
+  type: RSA
+  api_key_file: api.key
+  private_key_file: key.pem
+coins: [BTC]
+transfer:
+  enabled: true
+  to:
+    username: $MAIN
+  from:
+    - username: $MAIN
+    - username: foo
+      account_type: SPOT
+`), "")
+		require.ErrorContains(err, `12:17: .transfer.from[0].username is duplicated with .transfer.to.username: $MAIN`)
+		require.ErrorContains(err, `14:21: .transfer.from[1].account_type must be one of "UNIFIED", "FUND", or "CONTRACT": SPOT`)
+
+		_, err = cmd.ReadConfig(writeConfig(t, `
+secret:
+  type: RSA
+  api_key_file: api.key
+  private_key_file: key.pem
+coins: [BTC]
+transfer:
+  enabled: true
+  to:
+    username: $MAIN
+  from:
+    - username: foo
+      account_type: FUND
+      funding: true
+`), "")
+		require.ErrorContains(err, `14:16: .transfer.from[0].funding requires .transfer.from[0].account_type to be "UNIFIED": FUND`)
+	})
+
+	t.Run("sub account selectors", func(t *testing.T) {
+		require := require.New(t)
+
+		conf, err := cmd.ReadConfig(writeConfig(t, `
+secret:
+  type: RSA
+  api_key_file: api.key
+  private_key_file: key.pem
+coins:
+  - coin: BTC
+    transfer:
+      from: [bot-1]
+transfer:
+  enabled: true
+  to:
+    username: $MAIN
+  from:
+    - glob: bot-*
+      exclude: [bot-test]
+`), "")
+		require.NoError(err)
+
+		s := conf.Transfer.From[0].SubAccountSelector
+		require.True(s.Match(bybit.SubMember{Username: "bot-1"}))
+		require.False(s.Match(bybit.SubMember{Username: "bot-test"}))
+		require.False(s.Match(bybit.SubMember{Username: "foo"}))
+
+		_, err = cmd.ReadConfig(writeConfig(t, `
+secret:
+  type: RSA
+  api_key_file: api.key
+  private_key_file: key.pem
+coins: [BTC]
+transfer:
+  enabled: true
+  to:
+    username: $MAIN
+  from:
+    - username: foo
+      glob: bot-*
+    - regex: "bot-("
+    - exclude: [bot-test]
+`), "")
+		require.ErrorContains(err, `12:17: .transfer.from[0].username cannot be given with a selector`)
+		require.ErrorContains(err, `.transfer.from[1].regex: error parsing regexp`)
+		require.ErrorContains(err, `.transfer.from[2].exclude requires a selector`)
+
+		// Usernames that cannot be selected are still checked.
+		_, err = cmd.ReadConfig(writeConfig(t, `
+secret:
+  type: RSA
+  api_key_file: api.key
+  private_key_file: key.pem
+coins:
+  - coin: BTC
+    transfer:
+      from: [foo, bot-test, bto-1]
+transfer:
+  enabled: true
+  to:
+    username: $MAIN
+  from:
+    - username: foo
+    - glob: bot-*
+      exclude: [bot-test]
+`), "")
+		require.ErrorContains(err, `.coins[0].transfer.from[1] must be one of usernames in .transfer.from: bot-test`)
+		require.ErrorContains(err, `.coins[0].transfer.from[2] must be one of usernames in .transfer.from: bto-1`)
+		require.NotContains(err.Error(), `.coins[0].transfer.from[0]`)
+	})
+}
@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/lesomnus/tiny-short/bybit"
+)
+
+type ConfigCheckOptions struct {
+	Path    string
+	Profile string
+	Remote  bool // Contacts Bybit to check the API key and usernames.
+}
+
+// ConfigCheck reads the config and prints every problem found in it.
+// Nothing is written to Bybit even if `opts.Remote` is set.
+func ConfigCheck(ctx context.Context, opts ConfigCheckOptions) error {
+	fmt.Print("📝 ")
+	h1.Print("Config Check\n")
+	h2.Print("   Path ")
+	fmt.Println(opts.Path)
+	if opts.Profile != "" {
+		h2.Print("Profile ")
+		fmt.Println(opts.Profile)
+	}
+
+	conf, err := ReadConfig(opts.Path, opts.Profile)
+	if err != nil {
+		var conf_err *ConfigError
+		if !errors.As(err, &conf_err) {
+			p_fail.Print("✗ ")
+			p_fail_why.Println(err.Error())
+			return errors.New("config not readable")
+		}
+
+		for _, e := range conf_err.Errs {
+			p_fail.Print("✗ ")
+			p_fail_why.Println(e.Error())
+		}
+		return fmt.Errorf("%d problem(s) found in the config", len(conf_err.Errs))
+	}
+	p_good.Println("✓ OK")
+
+	if !opts.Remote {
+		return nil
+	}
+
+	fmt.Println()
+	fmt.Print("🌐 ")
+	h1.Print("Remote Check\n")
+
+	s, err := readActingSecret(ctx, conf)
+	if err != nil {
+		return err
+	}
+	client, err := newClient(conf, s)
+	if err != nil {
+		return err
+	}
+
+	is_master := false
+	h2.Print("API Key ")
+	if res, err := client.User().QueryApi(ctx, bybit.UserQueryApiReq{}); err != nil {
+		return fmt.Errorf("request for user query API: %w", err)
+	} else if !res.Ok() {
+		p_fail.Print("✗ ")
+		p_fail_why.Println(res.Err().Error())
+		return fmt.Errorf("user query API: %w", res.Err())
+	} else {
+		is_master = res.Result.IsMaster
+		p_good.Print("✓ OK ")
+		p_dimmed.Printf("UID %s\n", res.Result.UserId)
+	}
+
+	if !conf.Transfer.Enabled {
+		return nil
+	}
+
+	members, err := listSubMembers(ctx, client)
+	if err != nil {
+		return err
+	}
+
+	accounts := []AccountDescription{conf.Transfer.To}
+	for _, v := range resolveTransferSources(conf.Transfer, members) {
+		accounts = append(accounts, v.AccountDescription)
+	}
+	failed := false
+	for _, a := range accounts {
+		h2.Printf("%8s ", a.Username)
+
+		ok := false
+		if a.Username == "$MAIN" {
+			ok = is_master
+		} else {
+			for _, v := range members {
+				if v.Username == a.Username {
+					ok = true
+					break
+				}
+			}
+		}
+		switch {
+		case ok:
+			p_good.Println("✓ OK")
+		case a.Username == "$MAIN":
+			p_fail.Print("✗ Sub Account ")
+			p_fail_why.Println("API key must be of the main account")
+		default:
+			p_fail.Println("✗ Not found")
+		}
+
+		failed = failed || !ok
+	}
+	if failed {
+		return errors.New("some users are not resolved")
+	}
+
+	return nil
+}
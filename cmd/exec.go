@@ -2,8 +2,12 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"math"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -15,6 +19,7 @@ import (
 
 type TransferPlan struct {
 	Users []bybit.AccountInfo // [to, from...]
+	Rules []TransferRule      // Rules of `Source()`.
 }
 
 func (p *TransferPlan) Dest() *bybit.AccountInfo {
@@ -25,29 +30,73 @@ func (p *TransferPlan) Source() []bybit.AccountInfo {
 	return p.Users[1:]
 }
 
+// InterTransfers reports whether balances are moved between accounts of the UID of `Dest()`,
+// which is done by the API key of `Dest()` and needs "AccountTransfer" permission.
+func (p *TransferPlan) InterTransfers() bool {
+	dst := p.Dest()
+	if dst.AccountType != bybit.AccountTypeUnified {
+		return true
+	}
+	return slices.ContainsFunc(p.Source(), func(v bybit.AccountInfo) bool {
+		return v.UserId == dst.UserId
+	})
+}
+
+func (p *TransferPlan) Rule(i int) TransferRule {
+	if i < len(p.Rules) {
+		return p.Rules[i]
+	}
+	return TransferRule{}
+}
+
 type Exec struct {
 	Client bybit.Client
 
 	TransferPlan TransferPlan
 	Secrets      bybit.SecretStore
+	State        *runState // Required if a source transfers funding income.
+
+	PrivateStream string // Address of the private stream to learn fills; order history is polled if empty.
+
+	Out io.Writer        // Progress is rendered to; `color.Output` if nil.
+	Now func() time.Time // `time.Now` if nil.
 
 	Debug DebugConfig
 }
 
-func (e *Exec) Do(ctx context.Context, coin bybit.Coin) error {
+func (e *Exec) out() io.Writer {
+	if e.Out == nil {
+		return color.Output
+	}
+	return e.Out
+}
+
+func (e *Exec) now() time.Time {
+	if e.Now == nil {
+		return time.Now()
+	}
+	return e.Now()
+}
+
+func (e *Exec) Do(ctx context.Context, coin_conf CoinConfig) error {
 	l := log.From(ctx)
+	w := e.out()
+	coin := coin_conf.Coin
 	p_coin := pCoin(coin)
 
-	fmt.Printf("\n----------------\n")
-	color.New(color.BgMagenta, color.FgHiWhite).Print(" SHORT ")
-	fmt.Print(" ")
-	pCoin(coin).Add(color.Underline).Printf("%s", coin)
+	fmt.Fprintf(w, "\n----------------\n")
+	color.New(color.BgMagenta, color.FgHiWhite).Fprintf(w, " %s ", strings.ToUpper(coin_conf.Mode))
+	fmt.Fprint(w, " ")
+	pCoin(coin).Add(color.Underline).Fprintf(w, "%s", coin)
+	if coin_conf.Product != bybit.ProductTypeInverse {
+		p_dimmed.Fprintf(w, " %s", coin_conf.Symbol())
+	}
 
 	if res, err := e.Client.Market().FundingHistory(ctx, bybit.MarketFundingHistoryReq{
-		Category:  bybit.ProductTypeInverse,
-		Symbol:    coin.InvPerceptual(),
-		StartTime: bybit.Timestamp(time.Now().Add(-8 * time.Hour)),
-		EndTime:   bybit.Timestamp(time.Now()),
+		Category:  coin_conf.Product,
+		Symbol:    coin_conf.Symbol(),
+		StartTime: bybit.Timestamp(e.now().Add(-8 * time.Hour)),
+		EndTime:   bybit.Timestamp(e.now()),
 		Limit:     1,
 	}); err != nil {
 		l.Warn("request for funding history", slog.String("err", err.Error()))
@@ -57,11 +106,11 @@ func (e *Exec) Do(ctx context.Context, coin bybit.Coin) error {
 		l.Warn("funding history empty")
 	} else {
 		history := res.Result.List[0]
-		fmt.Print("⚡")
-		color.New(color.FgHiYellow).Printf("%s%% ", (history.FundingRate * 100).String())
-		fmt.Print(time.Since(history.FundingRateTimestamp.Time()).Truncate(time.Second))
-		p_dimmed.Print(" ago ")
-		fmt.Print("|")
+		fmt.Fprint(w, "⚡")
+		color.New(color.FgHiYellow).Fprintf(w, "%s%% ", (history.FundingRate * 100).String())
+		fmt.Fprint(w, e.now().Sub(history.FundingRateTimestamp.Time()).Truncate(time.Second))
+		p_dimmed.Fprint(w, " ago ")
+		fmt.Fprint(w, "|")
 	}
 
 	var (
@@ -69,8 +118,8 @@ func (e *Exec) Do(ctx context.Context, coin bybit.Coin) error {
 		bid1_price bybit.Amount
 	)
 	if res, err := e.Client.Market().Tickers(ctx, bybit.MarketTickersReq{
-		Category: bybit.ProductTypeInverse,
-		Symbol:   coin.InvPerceptual(),
+		Category: coin_conf.Product,
+		Symbol:   coin_conf.Symbol(),
 	}); err != nil {
 		return fmt.Errorf("request for tickers: %w", err)
 	} else if !res.Ok() {
@@ -82,15 +131,15 @@ func (e *Exec) Do(ctx context.Context, coin bybit.Coin) error {
 		mark_price = ticker.MarkPrice
 		bid1_price = ticker.Bid1Price
 
-		p_dimmed.Print("⚡")
-		fmt.Printf("%s%%", (ticker.FundingRate * 100).String())
-		p_dimmed.Print(" M")
-		fmt.Print(mark_price)
-		p_dimmed.Print(" B")
-		fmt.Print(bid1_price, "\n")
+		p_dimmed.Fprint(w, "⚡")
+		fmt.Fprintf(w, "%s%%", (ticker.FundingRate * 100).String())
+		p_dimmed.Fprint(w, " M")
+		fmt.Fprint(w, mark_price)
+		p_dimmed.Fprint(w, " B")
+		fmt.Fprint(w, bid1_price, "\n")
 	}
 
-	fmt.Println()
+	fmt.Fprintln(w)
 
 	dst := e.TransferPlan.Dest()
 
@@ -98,12 +147,12 @@ func (e *Exec) Do(ctx context.Context, coin bybit.Coin) error {
 	//  + nickname...0.01084342 ≈ 42 USD
 	{
 		name := dst.DisplayNameTrunc(8)
-		h2.Print(name)
-		p_dimmed.Print(strings.Repeat(".", (3+8+3)-len(name)))
+		h2.Fprint(w, name)
+		p_dimmed.Fprint(w, strings.Repeat(".", (3+8+3)-len(name)))
 	}
 	if res, err := e.Client.Asset().QueryAccountCoinBalance(ctx, bybit.AssetQueryAccountCoinBalanceReq{
 		MemberId:    dst.UserId.String(),
-		AccountType: bybit.AccountTypeUnified,
+		AccountType: dst.AccountType,
 		Coin:        coin,
 	}); err != nil {
 		return fmt.Errorf("request for query account coin balance: %w", err)
@@ -111,24 +160,28 @@ func (e *Exec) Do(ctx context.Context, coin bybit.Coin) error {
 		return fmt.Errorf("query account coin balance: %w", res.Err())
 	} else {
 		b := res.Result.Balance.TransferBalance
-		p_coin.Printf("%8f", b)
-		p_dimmed.Printf(" ≈ %8f USD\n", b*mark_price)
+		p_coin.Fprintf(w, "%8f", b)
+		p_dimmed.Fprintf(w, " ≈ %8f USD\n", b*mark_price)
 	}
 
-	for _, src := range e.TransferPlan.Source() {
+	for i, src := range e.TransferPlan.Source() {
+		if len(coin_conf.Transfer.From) > 0 && !slices.Contains(coin_conf.Transfer.From, src.Username) {
+			continue
+		}
+
 		{
 			name := src.DisplayNameTrunc(8)
-			fmt.Print(" + ")
-			h2.Print(name)
-			p_dimmed.Print(strings.Repeat(".", (8+3)-len(name)))
+			fmt.Fprint(w, " + ")
+			h2.Fprint(w, name)
+			p_dimmed.Fprint(w, strings.Repeat(".", (8+3)-len(name)))
 		}
 
 		var balance bybit.Amount
 		if res, err := e.Client.Asset().QueryAccountCoinBalance(ctx, bybit.AssetQueryAccountCoinBalanceReq{
 			MemberId:      src.UserId.String(),
 			ToMemberId:    dst.UserId.String(),
-			AccountType:   bybit.AccountTypeUnified,
-			ToAccountType: bybit.AccountTypeUnified,
+			AccountType:   src.AccountType,
+			ToAccountType: dst.AccountType,
 			Coin:          coin,
 		}); err != nil {
 			return fmt.Errorf("request for query account coin balance: %w", err)
@@ -138,64 +191,99 @@ func (e *Exec) Do(ctx context.Context, coin bybit.Coin) error {
 			balance = res.Result.Balance.TransferBalance
 		}
 
-		p_coin.Printf("%8f", balance)
-		p_dimmed.Printf(" ≈ %8f USD ", balance*mark_price)
+		rule := e.TransferPlan.Rule(i)
+		p_coin.Fprintf(w, "%8f", balance)
+		p_dimmed.Fprintf(w, " ≈ %8f USD ", balance*mark_price)
+		if v := rule.String(coin); v != "" {
+			p_dimmed.Fprintf(w, "[%s] ", v)
+		}
 
 		if balance == 0 {
-			fmt.Println("= SKIP")
+			fmt.Fprintln(w, "= SKIP")
 			continue
 		}
 
-		if e.Debug.Enabled && (e.Debug.SkipTransaction || e.Debug.SkipTransfer) {
-			p_warn.Print("= SKIP ")
-			p_dimmed.Println("by config")
-		} else if res, err := e.Client.Asset().UniversalTransfer(ctx, bybit.AssetUniversalTransferReq{
-			Coin:            coin,
-			Amount:          balance.String(),
-			FromMember:      src.UserId,
-			ToMember:        dst.UserId,
-			FromAccountType: bybit.AccountTypeUnified,
-			ToAccountType:   bybit.AccountTypeUnified,
-		}); err != nil {
-			p_fail.Print("✗ REQ FAILED ")
-			p_fail_why.Println(err.Error())
-			return fmt.Errorf("request for asset transfer: %w", err)
-		} else if !res.Ok() {
-			switch res.RetCode {
-			case bybit.RetCodeUnacceptableAmountAccuracy:
-				p_warn.Print("✗ IGNORE ")
-				p_dimmed.Println("amount too small")
+		d, err := e.decideTransfer(ctx, src, rule, coin, balance)
+		if err != nil {
+			p_fail.Fprint(w, "✗ ABORTED ")
+			p_fail_why.Fprintln(w, err.Error())
+			return err
+		}
+		if !d.FundingClamped.IsZero() {
+			p_warn.Fprint(w, "⚠ ")
+			p_dimmed.Fprintf(w, "funding before %s not counted ", d.FundingClamped.Format(time.DateTime))
+		}
+		if d.Amount > 0 && d.Amount < coin_conf.MinTransfer {
+			d.Amount = 0
+			d.Reason = "less than min_transfer"
+		}
+		if d.Amount == 0 {
+			fmt.Fprint(w, "= SKIP ")
+			p_dimmed.Fprintln(w, d.Reason)
+			if d.FundingIncome > 0 {
+				// Income is transferred by the next run.
 				continue
-			default:
-				p_fail.Print("✗ ABORTED ")
-				p_fail_why.Println(res.RetMsg)
-				return fmt.Errorf("asset transfer: %w", res.Err())
 			}
-		} else if res.Result.Status != bybit.TransferStatusSuccess {
-			switch res.Result.Status {
-			case bybit.TransferStatusUnknown:
-				fmt.Print("? UNKNOWN ")
-				p_dimmed.Println(res.RetMsg)
-			case bybit.TransferStatusPending:
-				fmt.Print("~ PENDING ")
-				p_dimmed.Println(res.RetMsg)
-			case bybit.TransferStatusFailed:
-				p_fail.Print("✗ FAILED ")
-				p_fail_why.Println(res.RetMsg)
-			default:
-				p_fail.Print("? UNSUPPORTED ")
-				p_fail_why.Println("unknown status: ", res.Result.Status)
+			if err := e.commitFunding(ctx, src, coin, d); err != nil {
+				return err
 			}
-			return fmt.Errorf("transfer not succeed: %s", res.Result.Status)
+			continue
+		}
+		if d.Amount != balance {
+			fmt.Fprint(w, "→ ")
+			p_coin.Fprintf(w, "%8f ", d.Amount)
+		}
+
+		if e.Debug.Enabled && (e.Debug.SkipTransaction || e.Debug.SkipTransfer) {
+			p_warn.Fprint(w, "= SKIP ")
+			p_dimmed.Fprintln(w, "by config")
+		} else if ok, err := e.checkTransfer(e.transfer(ctx, src, *dst, coin, d.Amount)); err != nil {
+			return err
+		} else if ok {
+			if err := e.commitFunding(ctx, src, coin, d); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Balance in the other account of the trading account is moved into the unified account to be shorted.
+	if dst.AccountType != bybit.AccountTypeUnified {
+		fmt.Fprint(w, " ↳ ")
+		h2.Fprint(w, dst.AccountType)
+		p_dimmed.Fprint(w, strings.Repeat(".", max(0, 8+3-len(dst.AccountType))))
+
+		var balance bybit.Amount
+		if res, err := e.Client.Asset().QueryAccountCoinBalance(ctx, bybit.AssetQueryAccountCoinBalanceReq{
+			MemberId:    dst.UserId.String(),
+			AccountType: dst.AccountType,
+			Coin:        coin,
+		}); err != nil {
+			return fmt.Errorf("request for query account coin balance: %w", err)
+		} else if !res.Ok() {
+			return fmt.Errorf("query account coin balance: %w", res.Err())
 		} else {
-			p_good.Println("✓ SUCCESS")
+			balance = res.Result.Balance.TransferBalance
+		}
+
+		p_coin.Fprintf(w, "%8f", balance)
+		p_dimmed.Fprintf(w, " ≈ %8f USD → %s ", balance*mark_price, bybit.AccountTypeUnified)
+
+		unified := *dst
+		unified.AccountType = bybit.AccountTypeUnified
+		if balance == 0 {
+			fmt.Fprintln(w, "= SKIP")
+		} else if e.Debug.Enabled && (e.Debug.SkipTransaction || e.Debug.SkipTransfer) {
+			p_warn.Fprint(w, "= SKIP ")
+			p_dimmed.Fprintln(w, "by config")
+		} else if _, err := e.checkTransfer(e.transfer(ctx, *dst, unified, coin, balance)); err != nil {
+			return err
 		}
 	}
 
 	trading_client := e.Client.Clone(e.TransferPlan.Dest().Secret)
 
 	var balance bybit.Amount
-	p_dimmed.Println("              ----------")
+	p_dimmed.Fprintln(w, "              ----------")
 	if res, err := trading_client.Account().TransferableAmount(ctx, bybit.AccountTransferableAmountReq{
 		CoinName: coin,
 	}); err != nil {
@@ -205,55 +293,84 @@ func (e *Exec) Do(ctx context.Context, coin bybit.Coin) error {
 	} else {
 		balance = res.Result.AvailableWithdrawal
 
-		p_dimmed.Print("              ")
-		p_coin.Printf("%8f", balance)
-		p_dimmed.Printf(" ≈ %8f USD\n", balance*mark_price)
+		p_dimmed.Fprint(w, "              ")
+		p_coin.Fprintf(w, "%8f", balance)
+		p_dimmed.Fprintf(w, " ≈ %8f USD\n", balance*mark_price)
 	}
 
-	fmt.Printf("\nShort by market order\n")
+	if coin_conf.Reserve > 0 {
+		balance = max(balance-coin_conf.Reserve, 0)
 
-	qty := int(balance * bid1_price * (1 - bybit.FeePerpTake))
-	fmt.Print("Places ")
-	h2.Print(qty)
-	if qty == 1 {
-		h2.Print(" contract ")
-	} else {
-		h2.Print(" contracts ")
+		p_dimmed.Fprint(w, "              ")
+		p_coin.Fprintf(w, "%8f", balance)
+		p_dimmed.Fprintf(w, " ≈ %8f USD after reserve of %s\n", balance*mark_price, coin_conf.Reserve)
+	}
+	if coin_conf.Mode == "transfer" {
+		p_dimmed.Fprintln(w, "\nNo order is placed by mode \"transfer\"")
+		return nil
 	}
 
-	if qty == 0 {
-		fmt.Println("= SKIP")
+	fmt.Fprintf(w, "\nShort by market order\n")
+
+	qty, min_qty, err := e.orderQty(ctx, coin_conf, balance, bid1_price, mark_price)
+	if err != nil {
+		return err
+	}
+	fmt.Fprint(w, "Places ")
+	printQty(w, coin_conf, qty)
+
+	if qty == "0" {
+		fmt.Fprintln(w, "= SKIP")
+		return nil
+	}
+	if v, _ := strconv.ParseFloat(qty, 64); bybit.Amount(v) < coin_conf.MinOrder {
+		fmt.Fprint(w, "= SKIP ")
+		p_dimmed.Fprintln(w, "less than min_order")
+		return nil
+	} else if bybit.Amount(v) < min_qty {
+		fmt.Fprint(w, "= SKIP ")
+		p_dimmed.Fprintf(w, "less than min order qty of %s\n", min_qty)
 		return nil
 	}
 	if e.Debug.Enabled && e.Debug.SkipTransaction {
-		p_warn.Print("= SKIP ")
-		p_dimmed.Println("by config")
+		p_warn.Fprint(w, "= SKIP ")
+		p_dimmed.Fprintln(w, "by config")
 		return nil
 	}
 
+	var watcher *orderWatcher
+	if e.PrivateStream != "" {
+		if w, err := watchOrders(ctx, e.PrivateStream, e.TransferPlan.Dest().Secret); err != nil {
+			l.Warn("watch orders", slog.String("err", err.Error()))
+		} else {
+			watcher = w
+			defer w.Close()
+		}
+	}
+
 	order_id := ""
 	if e.Debug.Enabled && e.Debug.SkipTransaction {
 		// Do NOT remove this block to prevent mistake.
-		fmt.Println()
+		fmt.Fprintln(w)
 	} else if res, err := trading_client.Trade().OrderCreate(ctx, bybit.TradeOrderCreateApiReq{
-		Category:  bybit.ProductTypeInverse,
-		Symbol:    coin.InvPerceptual(),
+		Category:  coin_conf.Product,
+		Symbol:    coin_conf.Symbol(),
 		Side:      bybit.OrderSideSell,
 		OrderType: bybit.OrderTypeMarket,
-		Quantity:  strconv.Itoa(qty),
+		Quantity:  qty,
 	}); err != nil {
-		p_fail.Print("✗ REQ FAILED ")
-		p_fail_why.Println(err.Error())
+		p_fail.Fprint(w, "✗ REQ FAILED ")
+		p_fail_why.Fprintln(w, err.Error())
 		return fmt.Errorf("request for order create: %w", err)
 	} else if !res.Ok() {
-		p_fail.Print("✗ FAILED ")
-		p_fail_why.Println(res.RetMsg)
+		p_fail.Fprint(w, "✗ FAILED ")
+		p_fail_why.Fprintln(w, res.RetMsg)
 		return fmt.Errorf("order create: %w", res.Err())
 	} else {
 		order_id = res.Result.OrderId
 
-		p_good.Print("✓ SUCCESS ")
-		p_dimmed.Println(order_id)
+		p_good.Fprint(w, "✓ SUCCESS ")
+		p_dimmed.Fprintln(w, order_id)
 	}
 
 	if order_id == "" {
@@ -261,66 +378,329 @@ func (e *Exec) Do(ctx context.Context, coin bybit.Coin) error {
 		// Anyway, code does not reach here if there is no order made.
 	} else {
 		//        "Places N contracts ..."
-		fmt.Print("     ↳ ")
+		fmt.Fprint(w, "     ↳ ")
 
+		order, err := e.waitOrder(ctx, trading_client, watcher, coin_conf.Product, order_id)
+		if err != nil {
+			p_warn.Fprint(w, "failed to get order details ")
+			p_dimmed.Fprintln(w, err.Error())
+			l.Warn("wait order", slog.String("err", err.Error()))
+		} else {
+			printQty(w, coin_conf, qty)
+			if order.Qty == 1 {
+				fmt.Fprint(w, "was")
+			} else {
+				fmt.Fprint(w, "were")
+			}
+			fmt.Fprint(w, " sold at the price of ")
+			h2.Fprintf(w, "%s USD\n", order.AvgPrice.String())
+
+			//              "Places N contracts ..."
+			p_dimmed.Fprintf(w, "       %s\n", order.UpdatedTime.Time())
+		}
+
+		if watcher != nil {
+			if v, ok := watcher.Balance(coin); ok {
+				p_dimmed.Fprint(w, "       balance ")
+				p_coin.Fprintf(w, "%8f\n", v)
+			}
+		}
+	}
+
+	return nil
+}
+
+// waitOrder returns the order when it is closed.
+// It is learned from the watcher if given, otherwise or if the watcher fails, the order history is polled.
+func (e *Exec) waitOrder(ctx context.Context, client bybit.Client, watcher *orderWatcher, category bybit.ProductType, order_id string) (bybit.Order, error) {
+	l := log.From(ctx)
+	if watcher != nil {
+		ctx_, cancel := context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+
+		order, err := watcher.WaitOrder(ctx_, order_id)
+		if err == nil {
+			return order, nil
+		}
+		l.Warn("order not closed by private stream", slog.String("err", err.Error()))
+	} else {
 		// Wait for the trading system closes the order.
 		// Note that `Tarde.GetOrderHistory` only queries closed orders.
 		time.Sleep(3 * time.Second)
+	}
 
-		RetryCount := 3
-		i := 0
-		for ; i < RetryCount; i++ {
-			res, err := trading_client.Trade().OrderHistory(ctx, bybit.TradeOrderHistoryReq{
-				Category: bybit.ProductTypeInverse,
-				OrderId:  order_id,
-				Limit:    1,
-			})
-			if err != nil {
-				p_warn.Print("failed to get order details ")
-				p_dimmed.Println(err.Error())
-				l.Warn("request for get order history", slog.String("err", err.Error()))
-				break
-			}
-			if !res.Ok() {
-				p_warn.Print("failed to get order details ")
-				p_dimmed.Println(res.Err())
-				l.Warn("get order history", slog.String("err", res.Err().Error()))
-				break
-			}
-			if len(res.Result.List) == 0 {
-				// Order not yes closed?
-				continue
-			}
-			if res.Result.List[0].OrderId != order_id {
-				p_warn.Print("failed to get order details ")
-				p_dimmed.Println("different order ID")
-				l.Warn("different order ID")
-				break
-			}
+	RetryCount := 3
+	for i := 0; i < RetryCount; i++ {
+		res, err := client.Trade().OrderHistory(ctx, bybit.TradeOrderHistoryReq{
+			Category: category,
+			OrderId:  order_id,
+			Limit:    1,
+		})
+		if err != nil {
+			return bybit.Order{}, fmt.Errorf("request for get order history: %w", err)
+		}
+		if !res.Ok() {
+			return bybit.Order{}, fmt.Errorf("get order history: %w", res.Err())
+		}
+		if len(res.Result.List) == 0 {
+			// Order not yes closed?
+			continue
+		}
+		if res.Result.List[0].OrderId != order_id {
+			return bybit.Order{}, errors.New("different order ID")
+		}
 
-			order := res.Result.List[0]
-			h2.Print(qty)
-			if order.Qty == 1 {
-				h2.Print(" contract ")
-				fmt.Print("was")
-			} else {
-				h2.Print(" contracts ")
-				fmt.Print("were")
-			}
-			fmt.Print(" sold at the price of ")
-			h2.Printf("%s USD\n", order.AvgPrice.String())
+		return res.Result.List[0], nil
+	}
 
-			//              "Places N contracts ..."
-			p_dimmed.Printf("       %s\n", order.UpdatedTime.Time())
-			break
+	return bybit.Order{}, errors.New("order does not closed")
+}
+
+// orderQty returns quantity of the short order for the balance and the min order qty of the exchange.
+// It is number of contracts, each worth 1 USD, for inverse
+// or amount of the coin in multiple of the qty step for linear.
+func (e *Exec) orderQty(ctx context.Context, coin_conf CoinConfig, balance bybit.Amount, bid1_price bybit.Amount, mark_price bybit.Amount) (string, bybit.Amount, error) {
+	if coin_conf.Product != bybit.ProductTypeLinear {
+		qty := math.Floor(float64(balance * bid1_price * (1 - bybit.FeePerpTake)))
+		if coin_conf.MaxNotional > 0 {
+			qty = min(qty, math.Floor(float64(coin_conf.MaxNotional)))
+		}
+		return strconv.Itoa(int(qty)), 0, nil
+	}
+
+	var (
+		step    float64
+		min_qty bybit.Amount
+	)
+	if res, err := e.Client.Market().InstrumentsInfo(ctx, bybit.MarketInstrumentsInfoReq{
+		Category: coin_conf.Product,
+		Symbol:   coin_conf.Symbol(),
+	}); err != nil {
+		return "", 0, fmt.Errorf("request for instruments info: %w", err)
+	} else if !res.Ok() {
+		return "", 0, fmt.Errorf("instruments info: %w", res.Err())
+	} else if len(res.Result.List) == 0 || res.Result.List[0].LotSizeFilter.QtyStep <= 0 {
+		return "", 0, fmt.Errorf("qty step of %s not found", coin_conf.Symbol())
+	} else {
+		step = float64(res.Result.List[0].LotSizeFilter.QtyStep)
+		min_qty = res.Result.List[0].LotSizeFilter.MinOrderQty
+	}
+
+	steps := math.Floor(float64(balance*(1-bybit.FeePerpTake)) / step)
+	if coin_conf.MaxNotional > 0 && mark_price > 0 {
+		steps = min(steps, math.Floor(float64(coin_conf.MaxNotional/mark_price)/step))
+	}
+	if steps <= 0 {
+		return "0", min_qty, nil
+	}
+
+	prec := max(0, int(-math.Floor(math.Log10(step))))
+	return strconv.FormatFloat(steps*step, 'f', prec, 64), min_qty, nil
+}
+
+func printQty(w io.Writer, coin_conf CoinConfig, qty string) {
+	h2.Fprint(w, qty)
+	switch {
+	case coin_conf.Product == bybit.ProductTypeLinear:
+		h2.Fprintf(w, " %s ", coin_conf.Coin)
+	case qty == "1":
+		h2.Fprint(w, " contract ")
+	default:
+		h2.Fprint(w, " contracts ")
+	}
+}
+
+type transferDecision struct {
+	Amount bybit.Amount
+	Reason string // Why nothing is transferred.
+
+	// Funding income until this time is counted if the rule transfers funding income.
+	FundingUntil time.Time
+	// Funding income counted including the one carried over from the previous runs;
+	// the rest of the transferred amount is carried over to the next run.
+	FundingIncome bybit.Amount
+	// Funding income before this time is not counted since the transaction log is kept only for 7 days.
+	FundingClamped time.Time
+}
+
+// decideTransfer decides the amount to transfer from the source by the rule.
+func (e *Exec) decideTransfer(ctx context.Context, src bybit.AccountInfo, rule TransferRule, coin bybit.Coin, balance bybit.Amount) (transferDecision, error) {
+	d := transferDecision{}
+	if v, ok := rule.Above[coin]; ok && balance <= v {
+		d.Reason = fmt.Sprintf("not above %s", v)
+		return d, nil
+	}
+
+	amount := balance
+	if rule.Funding {
+		if e.State == nil {
+			return d, errors.New("state is required to transfer funding income")
+		}
+
+		d.FundingUntil = e.now()
+		since, ok := e.State.FundingUntil[fundingKey(src.UserId, coin)]
+		if !ok {
+			d.Reason = "funding income is counted from now"
+			return d, nil
 		}
 
-		if i == RetryCount {
-			p_warn.Print("failed to get order details ")
-			p_dimmed.Println("order does not closed")
-			l.Warn("order does not closed")
+		if v := d.FundingUntil.Add(-fundingWindow); since.Before(v) {
+			since = v
+			d.FundingClamped = v
+			log.From(ctx).Warn("funding income before the window is not counted",
+				slog.String("username", src.Username),
+				slog.Time("since", v),
+			)
 		}
+
+		income, err := e.fundingIncome(ctx, src, coin, since, d.FundingUntil)
+		if err != nil {
+			return d, err
+		}
+		income += e.State.FundingCarried[fundingKey(src.UserId, coin)]
+		d.FundingIncome = income
+		if income <= 0 {
+			d.Reason = "no funding income"
+			return d, nil
+		}
+		amount = min(amount, income)
+	}
+	if rule.Percent > 0 {
+		amount = amount * bybit.Amount(rule.Percent) / 100
+	}
+	if v, ok := rule.Keep[coin]; ok {
+		amount = min(amount, balance-v)
+	}
+
+	// Amount of too fine precision is rejected.
+	amount = bybit.Amount(math.Floor(float64(amount)*1e8) / 1e8)
+	if amount <= 0 {
+		d.Reason = "nothing to transfer by the rule"
+		return d, nil
+	}
+
+	d.Amount = amount
+	return d, nil
+}
+
+// Transaction log cannot be queried beyond this.
+const fundingWindow = 7 * 24 * time.Hour
+
+// fundingIncome returns the funding income of the source in the coin between since and until.
+func (e *Exec) fundingIncome(ctx context.Context, src bybit.AccountInfo, coin bybit.Coin, since time.Time, until time.Time) (bybit.Amount, error) {
+	client := e.Client.Clone(src.Secret)
+	logs, err := bybit.CollectPages[bybit.TransactionLog](ctx, client.Account().TransactionLog, bybit.AccountTransactionLogReq{
+		AccountType: bybit.AccountTypeUnified,
+		Currency:    coin,
+		Type:        bybit.TransactionTypeSettlement,
+		StartTime:   bybit.Timestamp(since),
+		EndTime:     bybit.Timestamp(until),
+		Limit:       50,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("transaction log: %w", err)
+	}
+
+	income := bybit.Amount(0)
+	for _, v := range logs {
+		// Positive funding is a fee paid.
+		income -= v.Funding
+	}
+
+	return income, nil
+}
+
+// commitFunding saves the time until which the funding income of the source is counted
+// and the income not transferred, e.g. limited by `keep` or `percent`, to be carried over.
+func (e *Exec) commitFunding(ctx context.Context, src bybit.AccountInfo, coin bybit.Coin, d transferDecision) error {
+	if d.FundingUntil.IsZero() {
+		return nil
+	}
+	if e.Debug.Enabled && (e.Debug.SkipTransaction || e.Debug.SkipTransfer) {
+		return nil
+	}
+
+	key := fundingKey(src.UserId, coin)
+	e.State.FundingUntil[key] = d.FundingUntil
+	if rest := d.FundingIncome - d.Amount; rest > 0 {
+		e.State.FundingCarried[key] = rest
+	} else {
+		delete(e.State.FundingCarried, key)
+	}
+	if err := e.State.Save(ctx); err != nil {
+		return err
 	}
 
 	return nil
 }
+
+// transfer transfers the coin from src to dst.
+// Accounts of the same UID are transferred by inter transfer with the API key of dst
+// so the key must have "AccountTransfer" permission; see `TransferPlan.InterTransfers`.
+func (e *Exec) transfer(ctx context.Context, src bybit.AccountInfo, dst bybit.AccountInfo, coin bybit.Coin, amount bybit.Amount) (bybit.AssetUniversalTransferRes, error) {
+	if src.UserId != dst.UserId {
+		return e.Client.Asset().UniversalTransfer(ctx, bybit.AssetUniversalTransferReq{
+			Coin:            coin,
+			Amount:          amount.String(),
+			FromMember:      src.UserId,
+			ToMember:        dst.UserId,
+			FromAccountType: src.AccountType,
+			ToAccountType:   dst.AccountType,
+		})
+	}
+
+	// Source of the same UID may not have its API key but the trading account always has.
+	res := bybit.AssetUniversalTransferRes{}
+	v, err := e.Client.Clone(dst.Secret).Asset().InterTransfer(ctx, bybit.AssetInterTransferReq{
+		Coin:            coin,
+		Amount:          amount.String(),
+		FromAccountType: src.AccountType,
+		ToAccountType:   dst.AccountType,
+	})
+	res.ResponseBase = v.ResponseBase
+	res.Result = v.Result
+	return res, err
+}
+
+// checkTransfer prints the result of the transfer.
+// It returns false without error if the transfer is ignored.
+func (e *Exec) checkTransfer(res bybit.AssetUniversalTransferRes, err error) (bool, error) {
+	w := e.out()
+	if err != nil {
+		p_fail.Fprint(w, "✗ REQ FAILED ")
+		p_fail_why.Fprintln(w, err.Error())
+		return false, fmt.Errorf("request for asset transfer: %w", err)
+	}
+	if !res.Ok() {
+		switch res.RetCode {
+		case bybit.RetCodeUnacceptableAmountAccuracy:
+			p_warn.Fprint(w, "✗ IGNORE ")
+			p_dimmed.Fprintln(w, "amount too small")
+			return false, nil
+		default:
+			p_fail.Fprint(w, "✗ ABORTED ")
+			p_fail_why.Fprintln(w, res.RetMsg)
+			return false, fmt.Errorf("asset transfer: %w", res.Err())
+		}
+	}
+	if res.Result.Status != bybit.TransferStatusSuccess {
+		switch res.Result.Status {
+		case bybit.TransferStatusUnknown:
+			fmt.Fprint(w, "? UNKNOWN ")
+			p_dimmed.Fprintln(w, res.RetMsg)
+		case bybit.TransferStatusPending:
+			fmt.Fprint(w, "~ PENDING ")
+			p_dimmed.Fprintln(w, res.RetMsg)
+		case bybit.TransferStatusFailed:
+			p_fail.Fprint(w, "✗ FAILED ")
+			p_fail_why.Fprintln(w, res.RetMsg)
+		default:
+			p_fail.Fprint(w, "? UNSUPPORTED ")
+			p_fail_why.Fprintln(w, "unknown status: ", res.Result.Status)
+		}
+		return false, fmt.Errorf("transfer not succeed: %s", res.Result.Status)
+	}
+
+	p_good.Fprintln(w, "✓ SUCCESS")
+	return true, nil
+}
@@ -0,0 +1,216 @@
+package cmd_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/lesomnus/tiny-short/bybit"
+	"github.com/lesomnus/tiny-short/bybit/bybitmock"
+	"github.com/lesomnus/tiny-short/bybit/bybittest"
+	"github.com/lesomnus/tiny-short/cmd"
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+// golden compares the output with "testdata/<name>.ansi.golden"
+// and the output without colors with "testdata/<name>.golden".
+// Run `go test ./cmd -update` to write them.
+func golden(t *testing.T, name string, output string) {
+	for p, actual := range map[string]string{
+		filepath.Join("testdata", name+".ansi.golden"): output,
+		filepath.Join("testdata", name+".golden"):      ansiEscape.ReplaceAllString(output, ""),
+	} {
+		if *update {
+			require.NoError(t, os.MkdirAll(filepath.Dir(p), 0755))
+			require.NoError(t, os.WriteFile(p, []byte(actual), 0644))
+			continue
+		}
+
+		expected, err := os.ReadFile(p)
+		require.NoError(t, err, "run `go test ./cmd -update` to write golden files")
+		require.Equal(t, string(expected), actual, p)
+	}
+}
+
+type execScenario struct {
+	coin  cmd.CoinConfig
+	rules []cmd.TransferRule
+	debug cmd.DebugConfig
+	setup func(s *bybittest.Server, trader bybit.UserId, foo bybit.UserId, bar bybit.UserId)
+	fails bool
+}
+
+func TestExecGolden(t *testing.T) {
+	now := time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)
+
+	no_color, local := color.NoColor, time.Local
+	color.NoColor, time.Local = false, time.UTC
+	t.Cleanup(func() { color.NoColor, time.Local = no_color, local })
+
+	btc := cmd.CoinConfig{Coin: bybit.CoinBtc, Product: bybit.ProductTypeInverse, Mode: "short"}
+	for name, sc := range map[string]execScenario{
+		"transfer_and_short": {
+			coin:  btc,
+			rules: []cmd.TransferRule{{}, {Keep: map[bybit.Coin]bybit.Amount{bybit.CoinBtc: 0.01}}},
+		},
+		"linear": {
+			coin: cmd.CoinConfig{Coin: bybit.CoinSol, Product: bybit.ProductTypeLinear, Mode: "short"},
+			setup: func(s *bybittest.Server, trader bybit.UserId, foo bybit.UserId, bar bybit.UserId) {
+				s.SetBalance(trader, bybit.AccountTypeUnified, bybit.CoinSol, 12.5)
+				s.SetBalance(foo, bybit.AccountTypeUnified, bybit.CoinSol, 3)
+				s.SetTicker("SOLUSDT", bybittest.Ticker{MarkPrice: 100, Bid1Price: 99.9})
+				s.SetQtyStep("SOLUSDT", 0.1)
+			},
+		},
+		"below_min_order_qty": {
+			coin: cmd.CoinConfig{Coin: bybit.CoinSol, Product: bybit.ProductTypeLinear, Mode: "short"},
+			setup: func(s *bybittest.Server, trader bybit.UserId, foo bybit.UserId, bar bybit.UserId) {
+				s.SetBalance(trader, bybit.AccountTypeUnified, bybit.CoinSol, 0.5)
+				s.SetTicker("SOLUSDT", bybittest.Ticker{MarkPrice: 100, Bid1Price: 99.9})
+				s.SetQtyStep("SOLUSDT", 0.1)
+				s.SetMinOrderQty("SOLUSDT", 1)
+			},
+		},
+		"transfer_only": {
+			coin: cmd.CoinConfig{Coin: bybit.CoinBtc, Product: bybit.ProductTypeInverse, Mode: "transfer", Reserve: 0.05},
+		},
+		"skip_by_config": {
+			coin:  btc,
+			debug: cmd.DebugConfig{Enabled: true, SkipTransaction: true},
+		},
+		"pending_transfer": {
+			coin: btc,
+			setup: func(s *bybittest.Server, trader bybit.UserId, foo bybit.UserId, bar bybit.UserId) {
+				s.Fail("/v5/asset/transfer/universal-transfer", bybittest.Failure{Status: bybit.TransferStatusPending})
+			},
+			fails: true,
+		},
+		"amount_too_small": {
+			coin: btc,
+			setup: func(s *bybittest.Server, trader bybit.UserId, foo bybit.UserId, bar bybit.UserId) {
+				s.Fail("/v5/asset/transfer/universal-transfer", bybittest.Failure{RetCode: bybit.RetCodeUnacceptableAmountAccuracy, RetMsg: "amount accuracy error"})
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			require := require.New(t)
+
+			s := bybittest.NewServer()
+			defer s.Close()
+			s.SetNow(func() time.Time { return now })
+
+			main_secret := bybit.SecretRecord{Type: bybit.SecretTypeHmac, ApiKey: "main-key", Secret: "main-secret"}
+			trader_secret := bybit.SecretRecord{Type: bybit.SecretTypeHmac, ApiKey: "trader-key", Secret: "trader-secret"}
+			s.AddKey(bybittest.MainUserId, main_secret, bybittest.FullPermissions)
+			trader := s.AddSubMember("trader", "")
+			foo := s.AddSubMember("foo", "")
+			bar := s.AddSubMember("bar", "")
+			s.AddKey(trader, trader_secret, bybittest.FullPermissions)
+
+			s.SetBalance(trader, bybit.AccountTypeUnified, bybit.CoinBtc, 0.1)
+			s.SetBalance(foo, bybit.AccountTypeUnified, bybit.CoinBtc, 0.5)
+			s.SetBalance(bar, bybit.AccountTypeUnified, bybit.CoinBtc, 0.25)
+			s.SetTicker("BTCUSD", bybittest.Ticker{MarkPrice: 40010, Bid1Price: 40000, FundingRate: 0.0001})
+			s.AddFundingRate("BTCUSD", 0.0002, now.Add(-time.Hour))
+			if sc.setup != nil {
+				sc.setup(s, trader, foo, bar)
+			}
+
+			var out bytes.Buffer
+			exec := cmd.Exec{
+				Client: s.Client(main_secret),
+				TransferPlan: cmd.TransferPlan{
+					Users: []bybit.AccountInfo{
+						{UserId: trader, Username: "trader", AccountType: bybit.AccountTypeUnified, Secret: trader_secret},
+						{UserId: foo, Username: "foo", AccountType: bybit.AccountTypeUnified},
+						{UserId: bar, Nickname: "bar-with-long-name", Username: "bar", AccountType: bybit.AccountTypeUnified},
+					},
+					Rules: sc.rules,
+				},
+				Debug:         sc.debug,
+				PrivateStream: s.PrivateAddr(),
+				Out:           &out,
+				Now:           func() time.Time { return now },
+			}
+
+			err := exec.Do(context.Background(), sc.coin)
+			if sc.fails {
+				require.Error(err)
+			} else {
+				require.NoError(err)
+			}
+
+			golden(t, name, out.String())
+		})
+	}
+}
+
+func TestExecWithMock(t *testing.T) {
+	ctx := context.Background()
+	btc := cmd.CoinConfig{Coin: bybit.CoinBtc, Product: bybit.ProductTypeInverse, Mode: "short"}
+	plan := cmd.TransferPlan{
+		Users: []bybit.AccountInfo{
+			{UserId: 1, Username: "trader", AccountType: bybit.AccountTypeUnified, Secret: bybit.SecretRecord{ApiKey: "trader-key"}},
+			{UserId: 2, Username: "foo", AccountType: bybit.AccountTypeUnified},
+		},
+	}
+
+	tickers := bybit.MarketTickersRes{}
+	tickers.Result.List = append(tickers.Result.List, struct {
+		Symbol      bybit.Symbol `json:"symbol"`
+		MarkPrice   bybit.Amount `json:"markPrice"`
+		FundingRate bybit.Amount `json:"fundingRate"`
+		Bid1Price   bybit.Amount `json:"bid1Price"`
+	}{Symbol: "BTCUSD", MarkPrice: 40000, Bid1Price: 40000})
+
+	t.Run("tickers failure aborts before transfers", func(t *testing.T) {
+		require := require.New(t)
+
+		c := bybitmock.NewClient(bybit.SecretRecord{ApiKey: "main-key"})
+		res := bybit.MarketTickersRes{}
+		res.RetCode, res.RetMsg = bybit.RetCodeTooManyVisits, "Too many visits!"
+		c.MarketApi.TickersFunc = bybitmock.Returns[bybit.MarketTickersReq](res)
+
+		exec := cmd.Exec{Client: c, TransferPlan: plan, Out: &bytes.Buffer{}}
+		err := exec.Do(ctx, btc)
+		require.ErrorContains(err, "tickers: Too many visits!")
+		c.AssertCalled(t, "Market.Tickers", 1)
+		c.AssertNotCalled(t, "Asset.QueryAccountCoinBalance")
+	})
+
+	t.Run("transfer error aborts the short", func(t *testing.T) {
+		require := require.New(t)
+
+		c := bybitmock.NewClient(bybit.SecretRecord{ApiKey: "main-key"})
+		c.MarketApi.TickersFunc = bybitmock.Returns[bybit.MarketTickersReq](tickers)
+		c.AssetApi.QueryAccountCoinBalanceFunc = func(ctx context.Context, req bybit.AssetQueryAccountCoinBalanceReq) (bybit.AssetQueryAccountCoinBalanceRes, error) {
+			res := bybit.AssetQueryAccountCoinBalanceRes{}
+			res.Result.Balance.TransferBalance = 0.5
+			return res, nil
+		}
+		c.AssetApi.UniversalTransferFunc = bybitmock.Fails[bybit.AssetUniversalTransferReq, bybit.AssetUniversalTransferRes](errors.New("connection reset"))
+
+		exec := cmd.Exec{Client: c, TransferPlan: plan, Out: &bytes.Buffer{}}
+		err := exec.Do(ctx, btc)
+		require.ErrorContains(err, "connection reset")
+
+		reqs := bybitmock.Reqs[bybit.AssetUniversalTransferReq](c, "Asset.UniversalTransfer")
+		require.Len(reqs, 1)
+		require.Equal(bybit.UserId(2), reqs[0].FromMember)
+		require.Equal(bybit.UserId(1), reqs[0].ToMember)
+		require.Equal("0.5", reqs[0].Amount)
+		require.Equal("main-key", c.CallsOf("Asset.UniversalTransfer")[0].ApiKey)
+		c.AssertNotCalled(t, "Trade.OrderCreate")
+	})
+}
@@ -0,0 +1,3 @@
+package cmd
+
+var ResolveTransferSources = resolveTransferSources
@@ -0,0 +1,410 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/lesomnus/tiny-short/bybit"
+	"github.com/lesomnus/tiny-short/cmd/key"
+	"github.com/lesomnus/tiny-short/secret"
+)
+
+type InitOptions struct {
+	Path      string // Path to the config to write.
+	SecretDir string // Directory where the key pair and the API key are written.
+	Force     bool   // Overwrite existing config.
+	ReuseKey  bool   // Use the existing key pair in `SecretDir`.
+	Endpoint  string // Bybit to request, e.g. a fake server; main net if empty. Written to `.debug.endpoint`.
+
+	In io.Reader // Answers to the prompts; stdin if nil.
+}
+
+type initConfig struct {
+	ApiKeyPath     string
+	PrivateKeyPath string
+	StorePath      string
+	Coins          []bybit.Coin
+	Transfer       bool
+	To             AccountDescription
+	From           []AccountDescription
+	Endpoint       string
+}
+
+var initConfigTemplate = template.Must(template.New("config").Funcs(template.FuncMap{
+	"quote": strconv.Quote,
+}).Parse(`# Generated by ` + "`tiny-short init`" + `.
+# Run ` + "`tiny-short config schema`" + ` for JSON Schema of this file.
+
+secret:
+  type: RSA
+  api_key_file: {{ quote .ApiKeyPath }}
+  private_key_file: {{ quote .PrivateKeyPath }}
+  # Keeps API keys of the sub account.
+  store:
+    enabled: true
+    path: {{ quote .StorePath }}
+
+# Coins to short.
+coins:
+{{- range .Coins }}
+  - {{ . }}
+{{- end }}
+
+transfer:
+  enabled: {{ .Transfer }}
+{{- if .Transfer }}
+  # Account that trades. "$MAIN" is the account of the API key.
+  to:
+    nickname: {{ quote .To.Nickname }}
+    username: {{ quote .To.Username }}
+  # Accounts whose assets are transferred to the trading account.
+  from:
+{{- range .From }}
+    - nickname: {{ quote .Nickname }}
+      username: {{ quote .Username }}
+{{- else }} []
+{{- end }}
+{{- end }}
+
+log:
+  enabled: true
+  format: text
+  output:
+    - .tiny-short.log
+{{- if .Endpoint }}
+
+debug:
+  enabled: true
+  endpoint: {{ quote .Endpoint }}
+{{- end }}
+`))
+
+// Init sets up tiny-short interactively; it generates a key pair,
+// verifies the API key made by the user, and writes the config.
+func Init(ctx context.Context, opts InitOptions) error {
+	if !opts.Force {
+		if _, err := os.Stat(opts.Path); err == nil {
+			return fmt.Errorf("config already exists at %s; use --force to overwrite", opts.Path)
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("stat %s: %w", opts.Path, err)
+		}
+	}
+
+	if opts.In == nil {
+		opts.In = os.Stdin
+	}
+	in := bufio.NewReader(opts.In)
+	conf := initConfig{
+		ApiKeyPath:     filepath.Join(opts.SecretDir, "api.key"),
+		PrivateKeyPath: filepath.Join(opts.SecretDir, "key.pem"),
+		StorePath:      filepath.Join(opts.SecretDir, "store.json"),
+		Endpoint:       opts.Endpoint,
+	}
+	pub_key_path := filepath.Join(opts.SecretDir, "pub.pem")
+
+	addr := bybit.MainNetAddr1
+	if opts.Endpoint != "" {
+		addr = opts.Endpoint
+	}
+	network, err := url.Parse(addr)
+	if err != nil {
+		return fmt.Errorf("invalid endpoint url: %w", err)
+	}
+
+	fmt.Print("🔐 ")
+	h1.Print("Generate Key Pair\n")
+	if err := os.MkdirAll(opts.SecretDir, 0700); err != nil {
+		return fmt.Errorf("create directory %s: %w", opts.SecretDir, err)
+	}
+	pub_key_pem, reused, err := initKeyPair(conf.PrivateKeyPath, pub_key_path, opts.ReuseKey)
+	if err != nil {
+		return err
+	}
+	h2.Print("private key ")
+	fmt.Print(conf.PrivateKeyPath)
+	if reused {
+		p_dimmed.Print(" (existing)")
+	}
+	fmt.Println()
+	h2.Print(" public key ")
+	fmt.Println(pub_key_path)
+
+	fmt.Println()
+	h2.Println("Create a system-generated API key of \"Self-generated\" RSA type on Bybit with the public key below.")
+	p_dimmed.Println("Permissions: Contract - Orders & Positions, Derivatives - Trade, and Wallet - Subaccount Transfer.")
+	p_dimmed.Println("Wallet - Account Transfer is also needed to transfer from or to FUND or CONTRACT account.")
+	fmt.Println(string(pub_key_pem))
+
+	var (
+		client bybit.Client
+		res    bybit.UserQueryApiRes
+	)
+	for {
+		api_key, err := readLine(in, "API key: ")
+		if err != nil {
+			return err
+		}
+		if api_key == "" {
+			continue
+		}
+
+		prv_key, err := os.ReadFile(conf.PrivateKeyPath)
+		if err != nil {
+			return fmt.Errorf("read private key: %w", err)
+		}
+		client = bybit.NewClient(bybit.SecretRecord{
+			Type:   bybit.SecretTypeRsa,
+			ApiKey: api_key,
+			Secret: string(prv_key),
+		}, bybit.WithNetwork(*network))
+
+		if res, err = client.User().QueryApi(ctx, bybit.UserQueryApiReq{}); err != nil {
+			return fmt.Errorf("request for user query API: %w", err)
+		} else if !res.Ok() {
+			p_fail.Print("✗ ")
+			p_fail_why.Println(res.Err().Error())
+			continue
+		}
+
+		if err := (&secret.File{Path: conf.ApiKeyPath}).Store(ctx, []byte(api_key)); err != nil {
+			return fmt.Errorf("write API key: %w", err)
+		}
+		break
+	}
+
+	fmt.Println()
+	fmt.Print("🔑 ")
+	h1.Print("API Key Status\n")
+	h2.Print("UID ")
+	fmt.Println(res.Result.UserId)
+	can_transfer := slices.Contains(res.Result.Permissions.Wallet, "SubMemberTransfer")
+	checks := []struct {
+		name string
+		ok   bool
+	}{
+		{"Read & Write", res.Result.ReadOnly == 0},
+		{"Contract Order", slices.Contains(res.Result.Permissions.ContractTrade, "Order")},
+		{"DerivativesTrade", slices.Contains(res.Result.Permissions.Derivatives, "DerivativesTrade")},
+		{"SubMemberTransfer", can_transfer},
+		{"Main Account", res.Result.IsMaster},
+	}
+	for _, c := range checks {
+		h2.Printf("%17s ", c.name)
+		if c.ok {
+			p_good.Println("✓ OK")
+		} else {
+			p_warn.Println("✗ Missing")
+		}
+	}
+	conf.Transfer = can_transfer && res.Result.IsMaster
+	if !conf.Transfer {
+		p_warn.Println("Transfer is disabled since the API key cannot transfer assets between accounts.")
+	}
+
+	if conf.Transfer {
+		fmt.Println()
+		fmt.Print("🪪  ")
+		h1.Print("Accounts\n")
+
+		members, err := listSubMembers(ctx, client)
+		if err != nil {
+			return err
+		}
+
+		accounts := []AccountDescription{{Nickname: "main", Username: "$MAIN"}}
+		for _, m := range members {
+			nickname := m.Remark
+			if nickname == "" {
+				nickname = m.Username
+			}
+			accounts = append(accounts, AccountDescription{Nickname: nickname, Username: m.Username})
+		}
+		for i, a := range accounts {
+			h2.Printf("%3d ", i)
+			fmt.Print(a.Username, " ")
+			p_dimmed.Println(a.Nickname)
+		}
+
+		to, err := readIndices(in, "Trading account [0]: ", len(accounts), []int{0})
+		if err != nil {
+			return err
+		}
+		if len(to) != 1 {
+			return errors.New("only one trading account can be selected")
+		}
+		conf.To = accounts[to[0]]
+
+		from, err := readIndices(in, "Accounts to transfer from, e.g. 1,3 []: ", len(accounts), nil)
+		if err != nil {
+			return err
+		}
+		for _, i := range from {
+			if i != to[0] {
+				conf.From = append(conf.From, accounts[i])
+			}
+		}
+	}
+
+	fmt.Println()
+	for {
+		v, err := readLine(in, "Coins to short, e.g. BTC,SOL [BTC]: ")
+		if err != nil {
+			return err
+		}
+		if v == "" {
+			v = string(bybit.CoinBtc)
+		}
+
+		conf.Coins = nil
+		for _, c := range strings.Split(v, ",") {
+			coin := bybit.Coin(strings.ToUpper(strings.TrimSpace(c)))
+			if coinPattern.MatchString(string(coin)) && !slices.Contains(conf.Coins, coin) {
+				conf.Coins = append(conf.Coins, coin)
+			}
+		}
+		if len(conf.Coins) > 0 {
+			break
+		}
+	}
+
+	if err := writeInitConfig(opts.Path, conf); err != nil {
+		return err
+	}
+
+	fmt.Println()
+	p_good.Print("✓ Config is written at ")
+	fmt.Println(opts.Path)
+	return nil
+}
+
+// writeInitConfig writes the config to a temporary file next to `path` and
+// renames it only if it is valid so a failed run does not leave an invalid config.
+func writeInitConfig(path string, conf initConfig) error {
+	f, err := os.CreateTemp(filepath.Dir(path), fmt.Sprintf(".%s.*", filepath.Base(path)))
+	if err != nil {
+		return fmt.Errorf("create config: %w", err)
+	}
+
+	tmp := f.Name()
+	defer os.Remove(tmp)
+
+	if err := func() error {
+		defer f.Close()
+		if err := f.Chmod(0644); err != nil {
+			return fmt.Errorf("chmod: %w", err)
+		}
+		if err := initConfigTemplate.Execute(f, conf); err != nil {
+			return err
+		}
+		return f.Close()
+	}(); err != nil {
+		return fmt.Errorf("write config: %w", err)
+	}
+	if _, err := ReadConfig(tmp, ""); err != nil {
+		return fmt.Errorf("written config is invalid: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("write config: %w", err)
+	}
+
+	return nil
+}
+
+// initKeyPair generates a key pair, or reads the existing one if `reuse` is true.
+// Existing keys are never overwritten since an API key on Bybit may be made of them.
+func initKeyPair(prv_path string, pub_path string, reuse bool) ([]byte, bool, error) {
+	existing := []string{}
+	for _, p := range []string{prv_path, pub_path} {
+		if _, err := os.Stat(p); err == nil {
+			existing = append(existing, p)
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return nil, false, fmt.Errorf("stat %s: %w", p, err)
+		}
+	}
+	if len(existing) > 0 {
+		if !reuse {
+			return nil, false, fmt.Errorf("key already exists at %s; use --reuse-key to use it", strings.Join(existing, ", "))
+		}
+
+		data, err := os.ReadFile(prv_path)
+		if err != nil {
+			return nil, false, fmt.Errorf("read private key: %w", err)
+		}
+		prv_key, err := bybit.ParseRsaPrivateKey(data, nil)
+		if err != nil {
+			return nil, false, fmt.Errorf("private key at %s: %w", prv_path, err)
+		}
+
+		pub_key_pem := pem.EncodeToMemory(&pem.Block{
+			Type:  bybit.PemTypeRsaPublicKey,
+			Bytes: x509.MarshalPKCS1PublicKey(&prv_key.PublicKey),
+		})
+		return pub_key_pem, true, nil
+	}
+
+	pub_key_pem, err := key.GenerateKeyPair(key.GenOptions{
+		PrvOut: prv_path,
+		PubOut: pub_path,
+		Format: "pkcs1",
+		Bits:   4096,
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	if err := os.Chmod(prv_path, 0600); err != nil {
+		return nil, false, fmt.Errorf("chmod %s: %w", prv_path, err)
+	}
+
+	return pub_key_pem, false, nil
+}
+
+func readLine(r *bufio.Reader, prompt string) (string, error) {
+	fmt.Print(prompt)
+	line, err := r.ReadString('\n')
+	if err != nil && (line == "" || !errors.Is(err, io.EOF)) {
+		return "", fmt.Errorf("read input: %w", err)
+	}
+
+	return strings.TrimSpace(line), nil
+}
+
+// readIndices reads comma separated indices less than n.
+func readIndices(r *bufio.Reader, prompt string, n int, fallback []int) ([]int, error) {
+	for {
+		line, err := readLine(r, prompt)
+		if err != nil {
+			return nil, err
+		}
+		if line == "" {
+			return fallback, nil
+		}
+
+		vs := []int{}
+		for _, s := range strings.Split(line, ",") {
+			v, err := strconv.Atoi(strings.TrimSpace(s))
+			if err != nil || v < 0 || v >= n {
+				p_fail.Printf("✗ %q is not a valid index\n", s)
+				vs = nil
+				break
+			}
+			if !slices.Contains(vs, v) {
+				vs = append(vs, v)
+			}
+		}
+		if vs != nil {
+			return vs, nil
+		}
+	}
+}
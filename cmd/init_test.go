@@ -0,0 +1,109 @@
+package cmd_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lesomnus/tiny-short/bybit"
+	"github.com/lesomnus/tiny-short/bybit/bybittest"
+	"github.com/lesomnus/tiny-short/cmd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInit(t *testing.T) {
+	ctx := context.Background()
+
+	// Key pair left by the previous run of init.
+	setup := func(t *testing.T) (cmd.InitOptions, []byte) {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		require.NoError(t, err)
+		prv_key := pem.EncodeToMemory(&pem.Block{Type: bybit.PemTypeRsaPrivateKey, Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+		dir := t.TempDir()
+		opts := cmd.InitOptions{
+			Path:      filepath.Join(dir, "conf.yaml"),
+			SecretDir: filepath.Join(dir, "secrets"),
+			In:        strings.NewReader(""),
+		}
+		require.NoError(t, os.MkdirAll(opts.SecretDir, 0700))
+		require.NoError(t, os.WriteFile(filepath.Join(opts.SecretDir, "key.pem"), prv_key, 0600))
+		return opts, prv_key
+	}
+
+	t.Run("re-run keeps the existing key", func(t *testing.T) {
+		require := require.New(t)
+
+		opts, prv_key := setup(t)
+		opts.Force = true
+		err := cmd.Init(ctx, opts)
+		require.ErrorContains(err, "key already exists")
+
+		data, err := os.ReadFile(filepath.Join(opts.SecretDir, "key.pem"))
+		require.NoError(err)
+		require.Equal(prv_key, data)
+		require.NoFileExists(filepath.Join(opts.SecretDir, "pub.pem"))
+	})
+
+	t.Run("re-run with the existing key", func(t *testing.T) {
+		require := require.New(t)
+
+		opts, prv_key := setup(t)
+		opts.ReuseKey = true
+		err := cmd.Init(ctx, opts)
+		require.ErrorContains(err, "read input") // Passed the key pair and asked for the API key.
+
+		data, err := os.ReadFile(filepath.Join(opts.SecretDir, "key.pem"))
+		require.NoError(err)
+		require.Equal(prv_key, data)
+	})
+
+	t.Run("config is written", func(t *testing.T) {
+		require := require.New(t)
+
+		s := bybittest.NewServer()
+		t.Cleanup(s.Close)
+		s.AddSubMember("trader", "")
+		s.AddSubMember("foo", "")
+
+		opts, prv_key := setup(t)
+		s.AddKey(bybittest.MainUserId, bybit.SecretRecord{
+			Type:   bybit.SecretTypeRsa,
+			ApiKey: "main-key",
+			Secret: string(prv_key),
+		}, bybittest.FullPermissions)
+
+		// Wrong API key is asked again.
+		opts.In = strings.NewReader("wrong-key\nmain-key\n1\n0,2\nbtc, sol\n")
+		opts.ReuseKey = true
+		opts.Endpoint = s.URL
+		require.NoError(cmd.Init(ctx, opts))
+
+		data, err := os.ReadFile(filepath.Join(opts.SecretDir, "api.key"))
+		require.NoError(err)
+		require.Equal("main-key", string(data))
+
+		conf, err := cmd.ReadConfig(opts.Path, "")
+		require.NoError(err)
+		require.Len(conf.Coins, 2)
+		require.Equal(bybit.CoinBtc, conf.Coins[0].Coin)
+		require.Equal(bybit.CoinSol, conf.Coins[1].Coin)
+		require.True(conf.Transfer.Enabled)
+		require.Equal("trader", conf.Transfer.To.Username)
+		require.Len(conf.Transfer.From, 2)
+		require.Equal("$MAIN", conf.Transfer.From[0].Username)
+		require.Equal("foo", conf.Transfer.From[1].Username)
+		require.Equal(s.URL, conf.Debug.Endpoint)
+
+		// Only the config and the secrets are left.
+		entries, err := os.ReadDir(filepath.Dir(opts.Path))
+		require.NoError(err)
+		require.Len(entries, 2)
+	})
+}
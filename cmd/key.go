@@ -0,0 +1,397 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/lesomnus/tiny-short/bybit"
+)
+
+type KeyOptions struct {
+	Username string // Username of the sub account; `.transfer.to.username` if empty.
+	DryRun   bool
+}
+
+// KeyList prints API keys of the sub account.
+func KeyList(ctx context.Context, conf *Config, opts KeyOptions) error {
+	ctx, err := withLogger(ctx, conf)
+	if err != nil {
+		return err
+	}
+
+	client, uid, err := prepareSubKeyCmd(ctx, conf, opts)
+	if err != nil {
+		return err
+	}
+
+	store, err := openSecretStore(ctx, conf)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	secrets, err := store.Load(ctx)
+	if err != nil {
+		return err
+	}
+	stored, _ := secrets.Get(uid)
+
+	keys, err := listSubApiKeys(ctx, client, uid)
+	if err != nil {
+		return err
+	}
+
+	fmt.Print("🔑 ")
+	h1.Printf("API Keys of %s\n", uid)
+	if len(keys) == 0 {
+		p_dimmed.Println("no API keys")
+		return nil
+	}
+	for _, k := range keys {
+		printSubApiKey(k, stored)
+	}
+
+	return nil
+}
+
+// KeyRotate creates a new API key for the sub account, stores it,
+// and revokes the previously stored one.
+func KeyRotate(ctx context.Context, conf *Config, opts KeyOptions) error {
+	if !conf.Secret.Store.Enabled {
+		return errors.New("secret store must be enabled to rotate the key")
+	}
+
+	ctx, err := withLogger(ctx, conf)
+	if err != nil {
+		return err
+	}
+
+	lk, err := acquireLock(ctx, conf)
+	if err != nil {
+		return err
+	}
+	defer lk.Unlock()
+
+	client, uid, err := prepareSubKeyCmd(ctx, conf, opts)
+	if err != nil {
+		return err
+	}
+
+	store, err := openSecretStore(ctx, conf)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	secrets, err := store.Load(ctx)
+	if err != nil {
+		return err
+	}
+	old, has_old := secrets.Get(uid)
+
+	h2.Print("Creating new API key... ")
+	if opts.DryRun {
+		p_warn.Print("= SKIP ")
+		p_dimmed.Println("dry run")
+		return nil
+	}
+
+	is_dest := opts.Username == "" || opts.Username == conf.Transfer.To.Username
+	perms := subApiKeyPermissions(is_dest && conf.Transfer.interTransfers())
+	s, err := createSubApiKey(ctx, client, bybit.AccountInfo{UserId: uid}, perms)
+	if err != nil {
+		p_fail.Print("✗ FAILED ")
+		p_fail_why.Println(err.Error())
+		return fmt.Errorf("create sub API key: %w", err)
+	}
+
+	secrets.Set(uid, s)
+	if err := store.Save(ctx, secrets); err != nil {
+		p_fail.Print("✗ FAILED ")
+		p_fail_why.Println(err.Error())
+
+		// New key must not be leaked.
+		if err_ := deleteSubApiKey(ctx, client, s.ApiKey); err_ != nil {
+			return errors.Join(err, fmt.Errorf("revoke new key %s: %w", s.ApiKey, err_))
+		}
+		return err
+	}
+
+	p_good.Print("✓ OK ")
+	p_dimmed.Println(s.ApiKey)
+
+	if !has_old || old.ApiKey == "" {
+		return nil
+	}
+
+	h2.Print("Revoking old API key... ")
+	if err := deleteSubApiKey(ctx, client, old.ApiKey); err != nil {
+		p_fail.Print("✗ FAILED ")
+		p_fail_why.Println(err.Error())
+		return fmt.Errorf("revoke old key %s: %w", old.ApiKey, err)
+	}
+
+	p_good.Print("✓ OK ")
+	p_dimmed.Println(old.ApiKey)
+	return nil
+}
+
+// KeyRevoke deletes given API key of the sub account.
+// The key is removed from the secret store too if it is stored.
+func KeyRevoke(ctx context.Context, conf *Config, opts KeyOptions, api_key string) error {
+	ctx, err := withLogger(ctx, conf)
+	if err != nil {
+		return err
+	}
+
+	lk, err := acquireLock(ctx, conf)
+	if err != nil {
+		return err
+	}
+	defer lk.Unlock()
+
+	client, uid, err := prepareSubKeyCmd(ctx, conf, opts)
+	if err != nil {
+		return err
+	}
+
+	store, err := openSecretStore(ctx, conf)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	secrets, err := store.Load(ctx)
+	if err != nil {
+		return err
+	}
+
+	keys, err := listSubApiKeys(ctx, client, uid)
+	if err != nil {
+		return err
+	}
+	if !slices.ContainsFunc(keys, func(k bybit.SubApiKeyInfo) bool { return k.ApiKey == api_key }) {
+		return fmt.Errorf("API key %s does not belong to the sub account %d", api_key, uid)
+	}
+
+	h2.Print("Revoking API key... ")
+	if opts.DryRun {
+		p_warn.Print("= SKIP ")
+		p_dimmed.Println("dry run")
+		return nil
+	}
+	if err := deleteSubApiKey(ctx, client, api_key); err != nil {
+		p_fail.Print("✗ FAILED ")
+		p_fail_why.Println(err.Error())
+		return fmt.Errorf("revoke key %s: %w", api_key, err)
+	}
+
+	p_good.Print("✓ OK ")
+	p_dimmed.Println(api_key)
+
+	if s, ok := secrets.Get(uid); ok && s.ApiKey == api_key {
+		secrets.Delete(uid)
+		if err := store.Save(ctx, secrets); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// KeyPrune deletes every API key of the sub account created by tiny-short
+// except the one in the secret store.
+func KeyPrune(ctx context.Context, conf *Config, opts KeyOptions) error {
+	ctx, err := withLogger(ctx, conf)
+	if err != nil {
+		return err
+	}
+
+	lk, err := acquireLock(ctx, conf)
+	if err != nil {
+		return err
+	}
+	defer lk.Unlock()
+
+	client, uid, err := prepareSubKeyCmd(ctx, conf, opts)
+	if err != nil {
+		return err
+	}
+
+	store, err := openSecretStore(ctx, conf)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	secrets, err := store.Load(ctx)
+	if err != nil {
+		return err
+	}
+	stored, ok := secrets.Get(uid)
+	if !ok {
+		// Every key created by tiny-short would be revoked including the one in use.
+		return fmt.Errorf("API key of the sub account %d is not in the secret store", uid)
+	}
+
+	keys, err := listSubApiKeys(ctx, client, uid)
+	if err != nil {
+		return err
+	}
+
+	errs := []error{}
+	for _, k := range keys {
+		if k.Note != SubApiKeyNote || k.ApiKey == stored.ApiKey {
+			continue
+		}
+
+		h2.Print(k.ApiKey, " ")
+		if opts.DryRun {
+			p_warn.Print("= SKIP ")
+			p_dimmed.Println("dry run")
+			continue
+		}
+		if err := deleteSubApiKey(ctx, client, k.ApiKey); err != nil {
+			p_fail.Print("✗ FAILED ")
+			p_fail_why.Println(err.Error())
+			errs = append(errs, fmt.Errorf("revoke key %s: %w", k.ApiKey, err))
+			continue
+		}
+
+		p_good.Println("✓ REVOKED")
+	}
+
+	return errors.Join(errs...)
+}
+
+// Returns the client acting as the main account and UID of the sub account.
+func prepareSubKeyCmd(ctx context.Context, conf *Config, opts KeyOptions) (bybit.Client, bybit.UserId, error) {
+	username := opts.Username
+	if username == "" {
+		username = conf.Transfer.To.Username
+	}
+	if username == "" {
+		return nil, 0, errors.New("username of the sub account must be given")
+	}
+	if username == "$MAIN" {
+		return nil, 0, errors.New("main account does not have sub API keys")
+	}
+
+	secret, err := readActingSecret(ctx, conf)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	client, err := newClient(conf, secret)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	uid, err := resolveSubMember(ctx, client, username)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return client, uid, nil
+}
+
+func listSubApiKeys(ctx context.Context, client bybit.Client, uid bybit.UserId) ([]bybit.SubApiKeyInfo, error) {
+	keys, err := bybit.CollectPages[bybit.SubApiKeyInfo](ctx, client.User().SubApiKeys, bybit.UserSubApiKeysReq{
+		SubUserId: uid,
+		Limit:     20,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("sub API keys: %w", err)
+	}
+
+	return keys, nil
+}
+
+func deleteSubApiKey(ctx context.Context, client bybit.Client, api_key string) error {
+	res, err := client.User().DeleteSubApiKey(ctx, bybit.UserDeleteSubApiKeyReq{
+		ApiKey: api_key,
+	})
+	if err != nil {
+		return fmt.Errorf("request for delete sub API key: %w", err)
+	} else if !res.Ok() {
+		return fmt.Errorf("delete sub API key: %w", res.Err())
+	}
+
+	return nil
+}
+
+func printSubApiKey(k bybit.SubApiKeyInfo, stored bybit.SecretRecord) {
+	h2.Print(k.ApiKey, " ")
+	if k.ApiKey == stored.ApiKey {
+		p_good.Print("● stored ")
+	}
+	if k.ReadOnly {
+		p_dimmed.Print("read-only ")
+	}
+	p_dimmed.Printf("%s ", k.Note)
+	if k.ExpiredAt.IsZero() {
+		fmt.Println("never expires")
+	} else if left := time.Until(k.ExpiredAt); left > 0 {
+		fmt.Printf("left %s\n", DurationString(left))
+	} else {
+		p_fail.Println("expired")
+	}
+}
+
+// KeyStoreRekey seals the secret store with a new key given by `enc`.
+// A store that is not sealed yet is migrated.
+func KeyStoreRekey(ctx context.Context, conf *Config, enc SecretStoreEncryptionConfig) error {
+	if !conf.Secret.Store.Enabled {
+		return errors.New("secret store is not enabled")
+	}
+
+	ctx, err := withLogger(ctx, conf)
+	if err != nil {
+		return err
+	}
+
+	lk, err := acquireLock(ctx, conf)
+	if err != nil {
+		return err
+	}
+	defer lk.Unlock()
+
+	store, err := openSecretStore(ctx, conf)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	secrets, err := store.Load(ctx)
+	if err != nil {
+		return err
+	}
+
+	key, err := newStoreKey(ctx, conf, enc, true)
+	if err != nil {
+		return fmt.Errorf("new key: %w", err)
+	}
+
+	store.key = key
+	h2.Print("Writing the secret store... ")
+	if err := store.Save(ctx, secrets); err != nil {
+		p_fail.Print("✗ FAILED ")
+		p_fail_why.Println(err.Error())
+		return err
+	}
+	p_good.Print("✓ OK ")
+	p_dimmed.Printf("%d records\n", len(secrets))
+
+	if enc.Type != conf.Secret.Store.Encryption.Type || enc.Env != conf.Secret.Store.Encryption.Env {
+		p_warn.Println("Update .secret.store.encryption in the config:")
+		fmt.Printf("  type: %s\n", enc.Type)
+		if enc.Env != "" {
+			fmt.Printf("  env: %s\n", enc.Env)
+		}
+	}
+
+	return nil
+}
@@ -9,7 +9,10 @@ import (
 	"fmt"
 
 	"github.com/fatih/color"
+	"github.com/lesomnus/tiny-short/bybit"
+	"github.com/lesomnus/tiny-short/secret"
 	"github.com/urfave/cli/v2"
+	"github.com/youmark/pkcs8"
 )
 
 var Gen = &cli.Command{
@@ -26,44 +29,60 @@ var Gen = &cli.Command{
 			Value: "pub_key.pem",
 			Usage: "output path for a public key",
 		},
+		&cli.StringFlag{
+			Name:  "format",
+			Value: "pkcs1",
+			Usage: `format of the private key; one of "pkcs1" or "pkcs8"`,
+		},
+		&cli.IntFlag{
+			Name:  "bits",
+			Value: 4096,
+			Usage: "size of the key in bits",
+		},
+		&cli.BoolFlag{
+			Name:  "passphrase",
+			Usage: "encrypt the private key by a passphrase; the key is written in encrypted PKCS #8",
+		},
+		passphraseEnvFlag,
 	},
 	Action: func(c *cli.Context) error {
 		prv_out_p := c.String("prv-out")
 		pub_out_p := c.String("pub-out")
+		format := c.String("format")
+		bits := c.Int("bits")
+		use_passphrase := c.Bool("passphrase") || c.IsSet("passphrase-env")
 
-		prv_out, err := touch(prv_out_p)
-		if err != nil {
-			return fmt.Errorf("touch %s: %w", prv_out_p, err)
-		}
-		defer prv_out.Close()
-
-		pub_out, err := touch(pub_out_p)
-		if err != nil {
-			return fmt.Errorf("touch %s: %w", pub_out_p, err)
+		switch format {
+		case "pkcs1":
+			if use_passphrase {
+				return fmt.Errorf(`passphrase protection requires "pkcs8" format`)
+			}
+		case "pkcs8":
+		default:
+			return fmt.Errorf(`--format must be one of "pkcs1" or "pkcs8": %s`, format)
 		}
-		defer pub_out.Close()
-
-		prv_key, err := rsa.GenerateKey(rand.Reader, 4096)
-		if err != nil {
-			return fmt.Errorf("generate RSA key: %w", err)
+		if bits < 2048 {
+			return fmt.Errorf("--bits must be at least 2048: %d", bits)
 		}
 
-		if err := pem.Encode(prv_out, &pem.Block{
-			Type:  "RSA PRIVATE KEY",
-			Bytes: x509.MarshalPKCS1PrivateKey(prv_key),
-		}); err != nil {
-			return fmt.Errorf("encode private key into pem: %w", err)
+		var passphrase []byte
+		if use_passphrase {
+			p, err := secret.ReadPassphrase(c.String("passphrase-env"), "Passphrase for the private key: ", true)
+			if err != nil {
+				return err
+			}
+			passphrase = p
 		}
 
-		pub_key_pem := bytes.Buffer{}
-		if err := pem.Encode(&pub_key_pem, &pem.Block{
-			Type:  "RSA PUBLIC KEY",
-			Bytes: x509.MarshalPKCS1PublicKey(&prv_key.PublicKey),
-		}); err != nil {
-			return fmt.Errorf("encode public key into pem: %w", err)
-		}
-		if _, err := pub_out.Write(pub_key_pem.Bytes()); err != nil {
-			return fmt.Errorf("write public key at %s: %w", pub_out_p, err)
+		pub_key_pem, err := GenerateKeyPair(GenOptions{
+			PrvOut:     prv_out_p,
+			PubOut:     pub_out_p,
+			Format:     format,
+			Bits:       bits,
+			Passphrase: passphrase,
+		})
+		if err != nil {
+			return err
 		}
 
 		p := color.New(color.FgHiWhite)
@@ -74,8 +93,73 @@ var Gen = &cli.Command{
 		p.Println(pub_out_p)
 
 		p.Printf("\nCopy your public key to ByBit!:\n")
-		fmt.Println(pub_key_pem.String())
+		fmt.Println(string(pub_key_pem))
 
 		return nil
 	},
 }
+
+type GenOptions struct {
+	PrvOut     string
+	PubOut     string
+	Format     string // "pkcs1" | "pkcs8"
+	Bits       int
+	Passphrase []byte // Private key is written in encrypted PKCS #8 if given.
+}
+
+// GenerateKeyPair writes a new RSA key pair and returns the public key in PEM.
+func GenerateKeyPair(opts GenOptions) ([]byte, error) {
+	prv_out, err := touch(opts.PrvOut)
+	if err != nil {
+		return nil, fmt.Errorf("touch %s: %w", opts.PrvOut, err)
+	}
+	defer prv_out.Close()
+
+	pub_out, err := touch(opts.PubOut)
+	if err != nil {
+		return nil, fmt.Errorf("touch %s: %w", opts.PubOut, err)
+	}
+	defer pub_out.Close()
+
+	prv_key, err := rsa.GenerateKey(rand.Reader, opts.Bits)
+	if err != nil {
+		return nil, fmt.Errorf("generate RSA key: %w", err)
+	}
+
+	prv_block := &pem.Block{}
+	switch {
+	case opts.Format == "pkcs1":
+		prv_block.Type = bybit.PemTypeRsaPrivateKey
+		prv_block.Bytes = x509.MarshalPKCS1PrivateKey(prv_key)
+	case opts.Passphrase == nil:
+		prv_block.Type = bybit.PemTypePrivateKey
+		prv_block.Bytes, err = x509.MarshalPKCS8PrivateKey(prv_key)
+	default:
+		prv_block.Type = bybit.PemTypeEncryptedPrivateKey
+		prv_block.Bytes, err = pkcs8.MarshalPrivateKey(prv_key, opts.Passphrase, nil)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("marshal private key: %w", err)
+	}
+	if err := pem.Encode(prv_out, prv_block); err != nil {
+		return nil, fmt.Errorf("encode private key into pem: %w", err)
+	}
+
+	pub_key_pem := bytes.Buffer{}
+	if err := pem.Encode(&pub_key_pem, &pem.Block{
+		Type:  bybit.PemTypeRsaPublicKey,
+		Bytes: x509.MarshalPKCS1PublicKey(&prv_key.PublicKey),
+	}); err != nil {
+		return nil, fmt.Errorf("encode public key into pem: %w", err)
+	}
+	if _, err := pub_out.Write(pub_key_pem.Bytes()); err != nil {
+		return nil, fmt.Errorf("write public key at %s: %w", opts.PubOut, err)
+	}
+
+	return pub_key_pem.Bytes(), nil
+}
+
+var passphraseEnvFlag = &cli.StringFlag{
+	Name:  "passphrase-env",
+	Usage: "name of env var that holds the passphrase; passphrase is prompted if not given",
+}
@@ -0,0 +1,78 @@
+package key
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/fatih/color"
+	"github.com/lesomnus/tiny-short/bybit"
+	"github.com/lesomnus/tiny-short/secret"
+	"github.com/urfave/cli/v2"
+)
+
+var Show = &cli.Command{
+	Name:      "show",
+	Usage:     "prints the public key, its fingerprint and size from a private or public key",
+	ArgsUsage: "PEM_FILE",
+	Flags: []cli.Flag{
+		passphraseEnvFlag,
+	},
+	Action: func(c *cli.Context) error {
+		if c.NArg() != 1 {
+			return fmt.Errorf("path to a PEM file must be given")
+		}
+
+		p := c.Args().First()
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return fmt.Errorf("read %s: %w", p, err)
+		}
+
+		pub, err := readPublicKey(data, c.String("passphrase-env"))
+		if err != nil {
+			return fmt.Errorf("key at %s: %w", p, err)
+		}
+
+		fingerprint, err := bybit.RsaFingerprint(pub)
+		if err != nil {
+			return err
+		}
+
+		h := color.New(color.FgHiWhite)
+		h.Print("Fingerprint ")
+		fmt.Println(fingerprint)
+		h.Print("       Size ")
+		fmt.Printf("%d bits\n", pub.N.BitLen())
+		fmt.Println()
+
+		return pem.Encode(os.Stdout, &pem.Block{
+			Type:  bybit.PemTypeRsaPublicKey,
+			Bytes: x509.MarshalPKCS1PublicKey(pub),
+		})
+	},
+}
+
+// Reads a public key from PEM of a public key or a private key.
+func readPublicKey(data []byte, passphrase_env string) (*rsa.PublicKey, error) {
+	if pub, err := bybit.ParseRsaPublicKey(data); err == nil {
+		return pub, nil
+	}
+
+	prv, err := bybit.ParseRsaPrivateKey(data, nil)
+	if errors.Is(err, bybit.ErrPassphraseRequired) {
+		p, err_ := secret.ReadPassphrase(passphrase_env, "Passphrase for the private key: ", false)
+		if err_ != nil {
+			return nil, err_
+		}
+		prv, err = bybit.ParseRsaPrivateKey(data, p)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &prv.PublicKey, nil
+}
@@ -16,7 +16,7 @@ func touch(p string) (*os.File, error) {
 		return nil, fmt.Errorf("it must not a directory")
 	}
 
-	f, err := os.OpenFile(p, os.O_RDWR|os.O_CREATE, 0644)
+	f, err := os.OpenFile(p, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
 	if err == nil {
 		return f, nil
 	}
@@ -0,0 +1,93 @@
+package key
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/lesomnus/tiny-short/bybit"
+	"github.com/lesomnus/tiny-short/secret"
+	"github.com/urfave/cli/v2"
+)
+
+var Verify = &cli.Command{
+	Name:  "verify",
+	Usage: "signs a sample payload by the private key and verifies it by the public key",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "prv",
+			Value: "prv_key.pem",
+			Usage: "path to a private key",
+		},
+		&cli.StringFlag{
+			Name:  "pub",
+			Value: "pub_key.pem",
+			Usage: "path to a public key",
+		},
+		passphraseEnvFlag,
+	},
+	Action: func(c *cli.Context) error {
+		prv_p := c.String("prv")
+		pub_p := c.String("pub")
+
+		p_good := color.New(color.FgHiGreen)
+		p_fail := color.New(color.FgHiRed)
+		p_fail_why := color.New(color.FgRed)
+
+		prv, err := os.ReadFile(prv_p)
+		if err != nil {
+			return fmt.Errorf("read %s: %w", prv_p, err)
+		}
+		pub, err := os.ReadFile(pub_p)
+		if err != nil {
+			return fmt.Errorf("read %s: %w", pub_p, err)
+		}
+
+		s := bybit.SecretRecord{
+			Type:   bybit.SecretTypeRsa,
+			Secret: string(prv),
+		}
+		fmt.Print("private key ")
+		if _, err := s.Rsa(); errors.Is(err, bybit.ErrPassphraseRequired) {
+			p, err := secret.ReadPassphrase(c.String("passphrase-env"), "Passphrase for the private key: ", false)
+			if err != nil {
+				return err
+			}
+			s.Passphrase = string(p)
+		}
+		if _, err := s.Rsa(); err != nil {
+			p_fail.Print("✗ INVALID ")
+			p_fail_why.Println(err.Error())
+			return fmt.Errorf("private key at %s: %w", prv_p, err)
+		}
+		p_good.Println("✓ OK")
+
+		fmt.Print(" public key ")
+		pub_key, err := bybit.ParseRsaPublicKey(pub)
+		if err != nil {
+			p_fail.Print("✗ INVALID ")
+			p_fail_why.Println(err.Error())
+			return fmt.Errorf("public key at %s: %w", pub_p, err)
+		}
+		p_good.Println("✓ OK")
+
+		fmt.Print("  signature ")
+		payload := []byte(fmt.Sprintf("%dtiny-short5000category=inverse", time.Now().UnixMilli()))
+		signature, err := s.Sign(payload)
+		if err != nil {
+			p_fail.Print("✗ SIGN FAILED ")
+			p_fail_why.Println(err.Error())
+			return fmt.Errorf("sign: %w", err)
+		}
+		if err := bybit.VerifyRsa(pub_key, payload, signature); err != nil {
+			p_fail.Print("✗ MISMATCH ")
+			p_fail_why.Println("private key and public key are not a pair")
+			return fmt.Errorf("verify: %w", err)
+		}
+		p_good.Println("✓ OK")
+
+		return nil
+	},
+}
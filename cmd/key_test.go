@@ -0,0 +1,53 @@
+package cmd_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lesomnus/tiny-short/bybit"
+	"github.com/lesomnus/tiny-short/cmd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKey(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("list without the stored key", func(t *testing.T) {
+		require := require.New(t)
+
+		f := newRootFixture(t)
+		f.server.AddKey(f.trader, bybit.SecretRecord{Type: bybit.SecretTypeHmac, ApiKey: "trader-key", Secret: "trader-secret"}, bybit.ApiPermissions{})
+
+		require.NoError(cmd.KeyList(ctx, f.config(t), cmd.KeyOptions{}))
+
+		conf := f.config(t)
+		conf.Secret.Store.Enabled = false
+		require.NoError(cmd.KeyList(ctx, conf, cmd.KeyOptions{}))
+	})
+
+	t.Run("revoke refuses a key of another account", func(t *testing.T) {
+		require := require.New(t)
+
+		f := newRootFixture(t)
+		f.server.AddKey(f.foo, bybit.SecretRecord{Type: bybit.SecretTypeHmac, ApiKey: "foo-key", Secret: "foo-secret"}, bybit.ApiPermissions{})
+
+		err := cmd.KeyRevoke(ctx, f.config(t), cmd.KeyOptions{Username: "trader"}, "foo-key")
+		require.ErrorContains(err, "does not belong to the sub account")
+		require.Equal([]string{"foo-key"}, f.server.Keys(f.foo))
+	})
+
+	t.Run("prune refuses without the stored key", func(t *testing.T) {
+		require := require.New(t)
+
+		f := newRootFixture(t)
+		require.NoError(cmd.Root(ctx, f.config(t)))
+		require.Len(f.server.Keys(f.trader), 1)
+		require.NoError(os.Remove(filepath.Join(f.dir, "store.json")))
+
+		err := cmd.KeyPrune(ctx, f.config(t), cmd.KeyOptions{})
+		require.ErrorContains(err, "not in the secret store")
+		require.Len(f.server.Keys(f.trader), 1)
+	})
+}
@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/lesomnus/tiny-short/lock"
+	"github.com/lesomnus/tiny-short/log"
+)
+
+func acquireLock(ctx context.Context, conf *Config) (*lock.Lock, error) {
+	return lockFile(ctx, conf.Lock.Path, conf.Lock.Wait)
+}
+
+func lockFile(ctx context.Context, path string, wait bool) (*lock.Lock, error) {
+	l, err := lock.TryLock(path)
+	if errors.Is(err, lock.ErrLocked) && wait {
+		p_warn.Print("Waiting for the lock ")
+		p_dimmed.Println(err.Error())
+		l, err = lock.Wait(ctx, path)
+	}
+	if errors.Is(err, lock.ErrNotSupported) {
+		// Overlapping runs are not prevented but the tool is still usable.
+		log.From(ctx).Warn("run without lock", slog.String("path", path), slog.String("err", err.Error()))
+		p_warn.Print("Run without lock ")
+		p_dimmed.Println(err.Error())
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("acquire lock at %s: %w", path, err)
+	}
+
+	log.From(ctx).Info("lock acquired", slog.String("path", path))
+	return l, nil
+}
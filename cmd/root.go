@@ -4,61 +4,76 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"log/slog"
-	"net/url"
-	"os"
 	"slices"
-	"strings"
 	"time"
 
 	"github.com/lesomnus/tiny-short/bybit"
-	"github.com/lesomnus/tiny-short/log"
+	"github.com/lesomnus/tiny-short/bybit/ws"
 )
 
 func Root(ctx context.Context, conf *Config) error {
-	l, err := conf.Log.NewLogger()
+	ctx, err := withLogger(ctx, conf)
 	if err != nil {
-		return fmt.Errorf("create logger: %w", err)
+		return err
 	}
 
-	l.Info("read config", slog.String("path", conf.path))
-	ctx = log.Into(ctx, l)
-
-	acting_account := bybit.AccountInfo{
-		Secret: bybit.SecretRecord{
-			Type: conf.Secret.Type,
-		},
+	// Overlapping runs will transfer and short the same balances twice.
+	lk, err := acquireLock(ctx, conf)
+	if err != nil {
+		return err
 	}
-	if data, err := os.ReadFile(conf.Secret.ApiKeyFile); err != nil {
-		return fmt.Errorf("read %s: %w", conf.Secret.ApiKeyFile, err)
-	} else {
-		acting_account.Secret.ApiKey = string(data)
-		acting_account.Secret.ApiKey = strings.TrimSpace(acting_account.Secret.ApiKey)
+	defer lk.Unlock()
+
+	cas, err := openCassette(conf)
+	if err != nil {
+		return err
 	}
-	if data, err := os.ReadFile(conf.Secret.PrivateKeyFile); err != nil {
-		return fmt.Errorf("read %s: %w", conf.Secret.PrivateKeyFile, err)
+	defer cas.Close(ctx)
+
+	acting_account := bybit.AccountInfo{AccountType: bybit.AccountTypeUnified}
+	if s, err := cas.actingSecret(ctx, conf); err != nil {
+		return err
 	} else {
-		acting_account.Secret.Secret = string(data)
-		acting_account.Secret.Secret = strings.TrimSpace(acting_account.Secret.Secret)
+		acting_account.Secret = s
 	}
 
-	secrets := bybit.SecretStore{}
-	if conf.Secret.Store.Enabled {
-		f, err := os.OpenFile(conf.Secret.Store.Path, os.O_RDWR|os.O_CREATE, 0600)
-		if err != nil {
-			return fmt.Errorf("open secret store: %w", err)
-		}
-		if err := bybit.LoadSecrets(f, secrets); err != nil {
-			return fmt.Errorf("load secrets at %s: %w", conf.Secret.Store.Path, err)
+	// Secret store is not opened when replaying since its keys are replaced with dummies.
+	store := &persistedSecretStore{}
+	if !cas.replaying() {
+		if store, err = openSecretStore(ctx, conf); err != nil {
+			return err
 		}
 	}
+	defer store.Close()
+
+	secrets, err := store.Load(ctx)
+	if err != nil {
+		return err
+	}
+	if secrets, err = cas.secrets(secrets); err != nil {
+		return err
+	}
 
-	mainnet, err := url.Parse(bybit.MainNetAddr1)
+	client, err := newClient(conf, acting_account.Secret, cas.clientOptions()...)
 	if err != nil {
-		return fmt.Errorf("invalid main net url: %w", err)
+		return err
+	}
+	private_addr, trade_addr, err := streamAddrs(conf)
+	if err != nil {
+		return err
+	}
+	if cas.enabled() {
+		// Fills are learned from the order history so that the replay makes the same requests.
+		private_addr = ""
+		if conf.Misc.OrderTransport == "websocket" {
+			p_warn.Println("Orders are placed by REST while the cassette is used")
+		}
+	} else if conf.Misc.OrderTransport == "websocket" {
+		c := ws.NewTradeClient(client, trade_addr, acting_account.Secret)
+		defer c.Close()
+		client = c
 	}
 
-	client := bybit.NewClient(acting_account.Secret, bybit.WithNetwork(*mainnet))
 	if res, err := client.User().QueryApi(ctx, bybit.UserQueryApiReq{}); err != nil {
 		return fmt.Errorf("request for user query API: %w", err)
 	} else if !res.Ok() {
@@ -72,6 +87,22 @@ func Root(ctx context.Context, conf *Config) error {
 		h1.Print("API Key Status\n")
 		h2.Print("UID ")
 		fmt.Println(acting_account.UserId)
+		if acting_account.Secret.Signer != nil {
+			h2.Print("Key ")
+			p_dimmed.Println("external signer")
+		} else if acting_account.Secret.Type == bybit.SecretTypeRsa {
+			h2.Print("Key ")
+			if k, err := acting_account.Secret.Rsa(); err != nil {
+				p_fail.Print("✗ ")
+				p_fail_why.Println(err.Error())
+			} else if fingerprint, err := bybit.RsaFingerprint(&k.PublicKey); err != nil {
+				p_fail.Print("✗ ")
+				p_fail_why.Println(err.Error())
+			} else {
+				fmt.Print(fingerprint, " ")
+				p_dimmed.Printf("%d bits\n", k.N.BitLen())
+			}
+		}
 		h2.Print("Created at ")
 		fmt.Println(acting_account.Secret.DateCreated)
 		h2.Print("Expired at ")
@@ -120,6 +151,18 @@ func Root(ctx context.Context, conf *Config) error {
 			p_fail_why.Println("required to transfer asset between accounts")
 		}
 
+		// Balances between accounts of the main UID are moved by its API key.
+		if conf.Transfer.Enabled && conf.Transfer.To.Username == "$MAIN" && conf.Transfer.interTransfers() {
+			h2.Print("                 ")
+			if slices.Contains(res.Result.Permissions.Wallet, "AccountTransfer") {
+				p_good.Println("✓ AccountTransfer")
+			} else {
+				isGood = false
+				p_fail.Print("✗ AccountTransfer ")
+				p_fail_why.Println("required to transfer asset between accounts of the same UID")
+			}
+		}
+
 		h2.Print("    Main Account ")
 		if !conf.Transfer.Enabled {
 			fmt.Println("= Disabled")
@@ -143,77 +186,148 @@ func Root(ctx context.Context, conf *Config) error {
 
 	fmt.Println()
 
+	var state *runState
 	transfer_plan := TransferPlan{}
 	if !conf.Transfer.Enabled {
 		transfer_plan.Users = []bybit.AccountInfo{acting_account}
 	} else {
-		transfer_plan.Users = make([]bybit.AccountInfo, len(conf.Transfer.From)+1)
-		users := transfer_plan.Users
-
 		fmt.Print("🪪  ")
 		h1.Print("Resolve User IDs\n")
 
 		// Assert:
 		//   If `conf.Move.Enabled` == true, `actingUser` must be a main account.
-		if res, err := client.User().QuerySubMembers(ctx, bybit.UserQuerySubMembersReq{}); err != nil {
-			return fmt.Errorf("request for query sub members: %w", err)
-		} else if !res.Ok() {
-			return fmt.Errorf("query sub members: %w", res.Err())
-		} else {
-			// Fills user IDs by username.
-			users[0].Nickname = conf.Transfer.To.Nickname
-			users[0].Username = conf.Transfer.To.Username
-			for i, a := range conf.Transfer.From {
-				users[i+1].Nickname = a.Nickname
-				users[i+1].Username = a.Username
-			}
+		members, err := listSubMembers(ctx, client)
+		if err != nil {
+			return err
+		}
+
+		sources := resolveTransferSources(conf.Transfer, members)
+		transfer_plan.Users = make([]bybit.AccountInfo, len(sources)+1)
+		users := transfer_plan.Users
+
+		// Fills user IDs by username.
+		users[0].Nickname = conf.Transfer.To.Nickname
+		users[0].Username = conf.Transfer.To.Username
+		users[0].AccountType = conf.Transfer.To.AccountType
+		for i, a := range sources {
+			users[i+1].Nickname = a.Nickname
+			users[i+1].Username = a.Username
+			users[i+1].AccountType = a.AccountType
+		}
 
-			failed := false
-			for i := range users {
-				u := &users[i]
-				ok := false
-				if u.Username == "$MAIN" {
-					ok = true
-					*u = acting_account
-				} else {
-					for _, v := range res.Result.SubMembers {
-						if u.Username == v.Username {
-							ok = true
-							u.UserId = v.UserId
-							break
-						}
+		failed := false
+		for i := range users {
+			u := &users[i]
+			ok := false
+			if u.Username == "$MAIN" {
+				ok = true
+				account_type := u.AccountType
+				*u = acting_account
+				u.AccountType = account_type
+			} else {
+				for _, v := range members {
+					if u.Username == v.Username {
+						ok = true
+						u.UserId = v.UserId
+						break
 					}
 				}
+			}
+
+			h2.Printf("%8s ", u.DisplayNameTrunc(8))
+			p_dimmed.Printf("%s ", u.UserId.String())
+			if ok {
+				p_good.Printf("✓ OK ")
+			} else {
+				p_fail.Printf("✓ Not found ")
+			}
+			if i > 0 && !sources[i-1].SubAccountSelector.IsEmpty() {
+				p_dimmed.Printf("by %s", sources[i-1].SubAccountSelector.String())
+			}
+			fmt.Println()
 
-				h2.Printf("%8s ", u.DisplayNameTrunc(8))
-				p_dimmed.Printf("%s ", u.UserId.String())
-				if ok {
-					p_good.Printf("✓ OK\n")
-				} else {
-					p_fail.Printf("✓ Not found\n")
+			failed = failed || !ok
+		}
+		for i, v := range conf.Transfer.From {
+			if v.SubAccountSelector.IsEmpty() || slices.ContainsFunc(sources, func(s TransferSource) bool {
+				return s.SubAccountSelector.String() == v.SubAccountSelector.String()
+			}) {
+				continue
+			}
+
+			p_warn.Printf("No sub account is selected by .transfer.from[%d] ", i)
+			p_dimmed.Println(v.SubAccountSelector.String())
+		}
+		for i, coin := range conf.Coins {
+			for _, username := range coin.Transfer.From {
+				if slices.ContainsFunc(sources, func(s TransferSource) bool { return s.Username == username }) {
+					continue
 				}
 
-				failed = !ok
+				p_warn.Printf("No source is resolved for .coins[%d].transfer.from ", i)
+				p_dimmed.Println(username)
 			}
+		}
 
-			if failed {
-				return fmt.Errorf("some users are not found")
+		if failed {
+			return fmt.Errorf("some users are not found")
+		}
+
+		// Trading account and sources that transfer funding income,
+		// which is read from their transaction log, need their own API keys.
+		needs_key := []*bybit.AccountInfo{&users[0]}
+		for i, v := range sources {
+			transfer_plan.Rules = append(transfer_plan.Rules, v.TransferRule)
+			if v.Funding {
+				needs_key = append(needs_key, &users[i+1])
 			}
 		}
+		if len(needs_key) > 1 {
+			s, err := cas.loadState(ctx, conf.Transfer.StatePath)
+			if err != nil {
+				return err
+			}
+			state = s
+		}
+
+		for i, u := range needs_key {
+			if u.Username == "$MAIN" {
+				continue
+			}
 
-		u := &users[0]
-		if u.Username != "$Main" {
 			fmt.Println()
-			h2.Print("Getting trading account's API key... ")
+			if i == 0 {
+				h2.Print("Getting trading account's API key... ")
+			} else {
+				h2.Printf("Getting %s's API key... ", u.DisplayName())
+			}
+
+			perms := subApiKeyPermissions(i == 0 && transfer_plan.InterTransfers())
+
+			s, ok := secrets.Get(u.UserId)
+			stale := s.ApiKey // Revoked if replaced.
+			ok = ok && time.Until(s.DateExpired) > 96*time.Hour
+			if ok && len(perms.Wallet) > 0 {
+				// Keys stored by older versions may not have the permission.
+				if ok, err = permitted(ctx, client.Clone(s), perms); err != nil {
+					return err
+				} else if !ok {
+					p_dimmed.Print("stored key lacks permissions ")
+				}
+			}
 
-			if s, ok := secrets.Get(u.UserId); ok && time.Until(s.DateExpired) > 96*time.Hour {
+			if ok {
 				u.Secret = s
 				p_good.Print("✓ OK ")
 				p_dimmed.Print("from secret store ")
-			} else if s, err := createSubApiKey(ctx, client, *u); err != nil {
+				if err := cas.keyStored(u.UserId); err != nil {
+					return err
+				}
+				stale = ""
+			} else if s, err := createSubApiKey(ctx, client, *u, perms); err != nil {
 				p_fail.Print("✗ Failed to create API key ")
 				p_fail_why.Printf("%s\n", err.Error())
-				return fmt.Errorf("create trading account's API key: %w", err)
+				return fmt.Errorf("create API key of %s: %w", u.Username, err)
 			} else {
 				u.Secret = s
 				secrets.Set(u.UserId, s)
@@ -224,24 +338,24 @@ func Root(ctx context.Context, conf *Config) error {
 
 			fmt.Printf("🔑 left %s\n", DurationString(time.Until(u.Secret.DateExpired)))
 
-			if conf.Secret.Store.Enabled {
-				if err := func() error {
-					f, err := os.OpenFile(conf.Secret.Store.Path, os.O_RDWR|os.O_CREATE, 0600)
-					if err != nil {
-						return fmt.Errorf("open secret store: %w", err)
-					}
-					defer f.Close()
-
-					if err := bybit.SaveSecrets(f, secrets); err != nil {
-						return err
-					}
+			if cas.replaying() {
+				// Secrets are dummies.
+			} else if err := store.Save(ctx, secrets); err != nil {
+				p_fail.Printf("Failed to save secrets to %s ", store)
+				p_fail_why.Println(err.Error())
+				return err
+			}
 
-					return nil
-				}(); err != nil {
-					p_fail.Printf("Failed to save secrets at %s ", conf.Secret.Store.Path)
-					p_fail_why.Println(err.Error())
-					return fmt.Errorf("save secrets at %s: %w", conf.Secret.Store.Path, err)
-				}
+			if stale == "" {
+				continue
+			}
+			h2.Print("Revoking replaced API key... ")
+			if err := deleteSubApiKey(ctx, client, stale); err != nil {
+				// The run does not need the key anymore; it can be revoked by `key prune`.
+				p_fail.Print("✗ FAILED ")
+				p_fail_why.Println(err.Error())
+			} else {
+				p_good.Println("✓ REVOKED")
 			}
 		}
 	}
@@ -251,12 +365,15 @@ func Root(ctx context.Context, conf *Config) error {
 		TransferPlan: transfer_plan,
 		Debug:        conf.Debug,
 		Secrets:      secrets,
+		State:        state,
+
+		PrivateStream: private_addr,
 	}
 
 	errs := make([]error, 0)
 	for _, coin := range conf.Coins {
 		if err := exec.Do(ctx, coin); err != nil {
-			errs = append(errs, fmt.Errorf("execution failed %s: %w", coin, err))
+			errs = append(errs, fmt.Errorf("execution failed %s: %w", coin.Coin, err))
 		}
 	}
 	if len(errs) > 0 {
@@ -266,18 +383,28 @@ func Root(ctx context.Context, conf *Config) error {
 	return nil
 }
 
-func createSubApiKey(ctx context.Context, client bybit.Client, account bybit.AccountInfo) (bybit.SecretRecord, error) {
+// subApiKeyPermissions returns permissions of the API key of the sub account to create.
+// Inter transfer between accounts of the sub account needs "AccountTransfer".
+func subApiKeyPermissions(account_transfer bool) bybit.ApiPermissions {
+	perms := bybit.ApiPermissions{
+		ContractTrade: []string{"Order", "Position"},
+	}
+	if account_transfer {
+		perms.Wallet = []string{"AccountTransfer"}
+	}
+	return perms
+}
+
+func createSubApiKey(ctx context.Context, client bybit.Client, account bybit.AccountInfo, perms bybit.ApiPermissions) (bybit.SecretRecord, error) {
 	s := bybit.SecretRecord{
 		Type: bybit.SecretTypeHmac,
 	}
 
 	if res, err := client.User().CreateSubApiKey(ctx, bybit.UserCreateSubApiKeyReq{
-		SubUserId: account.UserId,
-		Note:      "tiny-short",
-		ReadOnly:  0,
-		Permissions: bybit.ApiPermissions{
-			ContractTrade: []string{"Order", "Position"},
-		},
+		SubUserId:   account.UserId,
+		Note:        SubApiKeyNote,
+		ReadOnly:    0,
+		Permissions: perms,
 	}); err != nil {
 		return bybit.SecretRecord{}, fmt.Errorf("request for create sub APi key: %w", err)
 	} else if !res.Ok() {
@@ -299,3 +426,29 @@ func createSubApiKey(ctx context.Context, client bybit.Client, account bybit.Acc
 
 	return s, nil
 }
+
+// permitted reports whether the API key of the client has every permission in `perms`.
+func permitted(ctx context.Context, client bybit.Client, perms bybit.ApiPermissions) (bool, error) {
+	res, err := client.User().QueryApi(ctx, bybit.UserQueryApiReq{})
+	if err != nil {
+		return false, fmt.Errorf("request for user query API: %w", err)
+	}
+	if !res.Ok() {
+		// Key may be deleted on Bybit.
+		return false, nil
+	}
+
+	for _, v := range []struct{ required, granted []string }{
+		{perms.ContractTrade, res.Result.Permissions.ContractTrade},
+		{perms.Wallet, res.Result.Permissions.Wallet},
+		{perms.Derivatives, res.Result.Permissions.Derivatives},
+	} {
+		for _, p := range v.required {
+			if !slices.Contains(v.granted, p) {
+				return false, nil
+			}
+		}
+	}
+
+	return true, nil
+}
@@ -0,0 +1,238 @@
+package cmd_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lesomnus/tiny-short/bybit"
+	"github.com/lesomnus/tiny-short/bybit/bybittest"
+	"github.com/lesomnus/tiny-short/cmd"
+	"github.com/stretchr/testify/require"
+)
+
+type rootFixture struct {
+	server *bybittest.Server
+	dir    string
+	trader bybit.UserId
+	foo    bybit.UserId
+}
+
+// newRootFixture runs a fake server where the main account transfers BTC of "foo" to "trader" and shorts it.
+func newRootFixture(t *testing.T) *rootFixture {
+	require := require.New(t)
+
+	s := bybittest.NewServer()
+	t.Cleanup(s.Close)
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(err)
+	prv_key := pem.EncodeToMemory(&pem.Block{Type: bybit.PemTypeRsaPrivateKey, Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+	dir := t.TempDir()
+	require.NoError(os.WriteFile(filepath.Join(dir, "api.key"), []byte("main-key"), 0600))
+	require.NoError(os.WriteFile(filepath.Join(dir, "key.pem"), prv_key, 0600))
+
+	s.AddKey(bybittest.MainUserId, bybit.SecretRecord{
+		Type:   bybit.SecretTypeRsa,
+		ApiKey: "main-key",
+		Secret: string(prv_key),
+	}, bybittest.FullPermissions)
+
+	f := &rootFixture{
+		server: s,
+		dir:    dir,
+		trader: s.AddSubMember("trader", ""),
+		foo:    s.AddSubMember("foo", ""),
+	}
+	s.SetBalance(f.trader, bybit.AccountTypeUnified, bybit.CoinBtc, 0.1)
+	s.SetBalance(f.foo, bybit.AccountTypeUnified, bybit.CoinBtc, 0.5)
+	s.SetTicker("BTCUSD", bybittest.Ticker{MarkPrice: 40000, Bid1Price: 40000, FundingRate: 0.0001})
+
+	return f
+}
+
+func (f *rootFixture) config(t *testing.T) *cmd.Config {
+	p := writeConfig(t, fmt.Sprintf(`
+secret:
+  type: RSA
+  api_key_file: %[1]s/api.key
+  private_key_file: %[1]s/key.pem
+  store:
+    enabled: true
+    path: %[1]s/store.json
+coins: [BTC]
+transfer:
+  enabled: true
+  to:
+    username: trader
+  from:
+    - username: foo
+  state_path: %[1]s/state.json
+lock:
+  path: %[1]s/lock
+log:
+  enabled: false
+debug:
+  enabled: true
+  endpoint: %[2]s
+`, f.dir, f.server.URL))
+
+	conf, err := cmd.ReadConfig(p, "")
+	require.NoError(t, err)
+	return conf
+}
+
+func TestRoot(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("transfer and short", func(t *testing.T) {
+		require := require.New(t)
+
+		f := newRootFixture(t)
+		require.NoError(cmd.Root(ctx, f.config(t)))
+
+		require.Equal(bybit.Amount(0), f.server.Balance(f.foo, bybit.AccountTypeUnified, bybit.CoinBtc))
+		require.InDelta(0.6, float64(f.server.Balance(f.trader, bybit.AccountTypeUnified, bybit.CoinBtc)), 1e-9)
+
+		orders := f.server.Orders(f.trader)
+		require.Len(orders, 1)
+		require.Equal(bybit.ProductTypeInverse, orders[0].Category)
+		require.Equal(bybit.OrderSideSell, orders[0].Side)
+		require.Equal(bybit.Amount(23986), orders[0].Qty) // ⌊0.6 × 40000 × (1 - fee)⌋
+
+		// API key of the trading account is created and kept in the store.
+		require.Len(f.server.Keys(f.trader), 1)
+		data, err := os.ReadFile(filepath.Join(f.dir, "store.json"))
+		require.NoError(err)
+		require.Contains(string(data), f.server.Keys(f.trader)[0])
+	})
+
+	t.Run("record and replay", func(t *testing.T) {
+		require := require.New(t)
+
+		f := newRootFixture(t)
+		conf := f.config(t)
+		conf.Cassette = cmd.CassetteConfig{Mode: "record", Path: filepath.Join(t.TempDir(), "cassette.json")}
+		require.NoError(cmd.Root(ctx, conf))
+		require.Len(f.server.Orders(f.trader), 1)
+
+		data, err := os.ReadFile(conf.Cassette.Path)
+		require.NoError(err)
+		require.NotContains(string(data), "main-key")
+		require.NotContains(string(data), f.server.Keys(f.trader)[0])
+
+		// Replays in clean directory without the server and the keys.
+		f.server.Close()
+		f.dir = t.TempDir()
+		replay := f.config(t)
+		replay.Cassette = cmd.CassetteConfig{Mode: "replay", Path: conf.Cassette.Path}
+		require.NoError(cmd.Root(ctx, replay))
+		require.NoFileExists(filepath.Join(f.dir, "store.json"))
+	})
+
+	t.Run("funding income not transferred is carried over", func(t *testing.T) {
+		require := require.New(t)
+
+		f := newRootFixture(t)
+		conf := f.config(t)
+		conf.Transfer.From[0].Funding = true
+		conf.Transfer.From[0].Percent = 50
+
+		read_state := func() string {
+			data, err := os.ReadFile(filepath.Join(f.dir, "state.json"))
+			require.NoError(err)
+			return string(data)
+		}
+
+		// Funding income is counted from the first run.
+		require.NoError(cmd.Root(ctx, conf))
+		state := read_state()
+
+		// Half of the income is too small to be transferred.
+		f.server.AddTransactionLog(f.foo, bybit.TransactionLog{
+			Currency:        bybit.CoinBtc,
+			Type:            bybit.TransactionTypeSettlement,
+			Funding:         -0.00000001,
+			TransactionTime: bybit.Timestamp(time.Now()),
+		})
+		require.NoError(cmd.Root(ctx, conf))
+		require.Equal(state, read_state())
+		require.Empty(f.server.Transfers())
+	})
+
+	t.Run("funding income limited by keep is carried over", func(t *testing.T) {
+		require := require.New(t)
+
+		f := newRootFixture(t)
+		conf := f.config(t)
+		conf.Transfer.From[0].Funding = true
+		conf.Transfer.From[0].Keep = map[bybit.Coin]bybit.Amount{bybit.CoinBtc: 0.4}
+
+		// Funding income is counted from the first run.
+		require.NoError(cmd.Root(ctx, conf))
+		require.Empty(f.server.Transfers())
+
+		// Only 0.1 of the income can be transferred by the keep.
+		f.server.AddTransactionLog(f.foo, bybit.TransactionLog{
+			Currency:        bybit.CoinBtc,
+			Type:            bybit.TransactionTypeSettlement,
+			Funding:         -0.25,
+			TransactionTime: bybit.Timestamp(time.Now()),
+		})
+		require.NoError(cmd.Root(ctx, conf))
+		require.Len(f.server.Transfers(), 1)
+		require.InDelta(0.1, float64(f.server.Transfers()[0].Amount), 1e-7)
+
+		// The rest is transferred by the next run without new income.
+		f.server.SetBalance(f.foo, bybit.AccountTypeUnified, bybit.CoinBtc, 1)
+		require.NoError(cmd.Root(ctx, conf))
+		require.Len(f.server.Transfers(), 2)
+		require.InDelta(0.15, float64(f.server.Transfers()[1].Amount), 1e-7)
+
+		// Nothing is left to be carried over.
+		require.NoError(cmd.Root(ctx, conf))
+		require.Len(f.server.Transfers(), 2)
+	})
+
+	t.Run("trading account of FUND", func(t *testing.T) {
+		require := require.New(t)
+
+		f := newRootFixture(t)
+		conf := f.config(t)
+
+		// Key of the trading account stored by the run that does not need inter transfer.
+		require.NoError(cmd.Root(ctx, conf))
+		require.Len(f.server.Keys(f.trader), 1)
+		stored := f.server.Keys(f.trader)[0]
+
+		f.server.SetBalance(f.foo, bybit.AccountTypeUnified, bybit.CoinBtc, 0.5)
+		conf.Transfer.To.AccountType = bybit.AccountTypeFund
+		require.NoError(cmd.Root(ctx, conf))
+
+		// New key replaces the stored one since it cannot move FUND to UNIFIED.
+		require.Len(f.server.Keys(f.trader), 1)
+		require.NotEqual(stored, f.server.Keys(f.trader)[0])
+		require.Equal(bybit.Amount(0), f.server.Balance(f.trader, bybit.AccountTypeFund, bybit.CoinBtc))
+		require.Len(f.server.Orders(f.trader), 2)
+	})
+
+	t.Run("pending transfer aborts the short", func(t *testing.T) {
+		require := require.New(t)
+
+		f := newRootFixture(t)
+		f.server.Fail("/v5/asset/transfer/universal-transfer", bybittest.Failure{Status: bybit.TransferStatusPending})
+
+		err := cmd.Root(ctx, f.config(t))
+		require.ErrorContains(err, "transfer not succeed: PENDING")
+		require.Len(f.server.Transfers(), 1)
+		require.Empty(f.server.Orders(f.trader))
+	})
+}
@@ -0,0 +1,269 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/lesomnus/tiny-short/bybit"
+	"github.com/lesomnus/tiny-short/bybit/ws"
+	"github.com/lesomnus/tiny-short/lock"
+	"github.com/lesomnus/tiny-short/secret"
+)
+
+// Note attached to the sub account's API keys created by tiny-short.
+const SubApiKeyNote = "tiny-short"
+
+func readActingSecret(ctx context.Context, conf *Config) (bybit.SecretRecord, error) {
+	s := bybit.SecretRecord{
+		Type: conf.Secret.Type,
+	}
+	if v, err := readSecret(ctx, conf.Secret.ApiKey); err != nil {
+		return bybit.SecretRecord{}, fmt.Errorf("read API key: %w", err)
+	} else {
+		s.ApiKey = v
+	}
+	if len(conf.Secret.Signer.Command) > 0 {
+		s.Signer = &bybit.ExecSigner{
+			Command:  conf.Secret.Signer.Command,
+			Encoding: bybit.ExecSignerEncoding(conf.Secret.Signer.Encoding),
+		}
+		return s, nil
+	}
+	if v, err := readSecret(ctx, conf.Secret.PrivateKey); err != nil {
+		return bybit.SecretRecord{}, fmt.Errorf("read private key: %w", err)
+	} else {
+		s.Secret = v
+	}
+	if conf.Secret.PrivateKeyPassphrase.Provider != "" {
+		if v, err := readSecret(ctx, conf.Secret.PrivateKeyPassphrase); err != nil {
+			return bybit.SecretRecord{}, fmt.Errorf("read private key passphrase: %w", err)
+		} else {
+			s.Passphrase = v
+		}
+	}
+
+	return s, nil
+}
+
+func readSecret(ctx context.Context, conf SecretSourceConfig) (string, error) {
+	p, err := conf.NewProvider()
+	if err != nil {
+		return "", err
+	}
+
+	data, err := p.Load(ctx)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", p, err)
+	}
+
+	return strings.TrimSpace(string(data)), nil
+}
+
+// newClient returns a client of the main net or of `.debug.endpoint` if debugging.
+// The conf can be nil.
+func newClient(conf *Config, secret bybit.SecretRecord, opts ...bybit.ClientOption) (bybit.Client, error) {
+	u, err := endpoint(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	return bybit.NewClient(secret, append([]bybit.ClientOption{bybit.WithNetwork(*u)}, opts...)...), nil
+}
+
+func endpoint(conf *Config) (*url.URL, error) {
+	addr := bybit.MainNetAddr1
+	if conf != nil && conf.Debug.Enabled && conf.Debug.Endpoint != "" {
+		addr = conf.Debug.Endpoint
+	}
+
+	u, err := url.Parse(addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid endpoint url: %w", err)
+	}
+	return u, nil
+}
+
+// streamAddrs returns addresses of the private stream and the trade stream.
+// They are on the host of `.debug.endpoint` if debugging.
+func streamAddrs(conf *Config) (string, string, error) {
+	if conf == nil || !conf.Debug.Enabled || conf.Debug.Endpoint == "" {
+		return ws.PrivateAddr, ws.TradeAddr, nil
+	}
+
+	u, err := endpoint(conf)
+	if err != nil {
+		return "", "", err
+	}
+	if u.Scheme == "https" {
+		u.Scheme = "wss"
+	} else {
+		u.Scheme = "ws"
+	}
+	return u.JoinPath("/v5/private").String(), u.JoinPath("/v5/trade").String(), nil
+}
+
+// persistedSecretStore reads and writes the secret store through the configured provider.
+// The store is locked until it is closed if it is kept in a file.
+type persistedSecretStore struct {
+	enabled  bool
+	provider secret.Provider
+	key      *bybit.StoreKey // Store is not encrypted if nil.
+	lock     *lock.Lock
+}
+
+func openSecretStore(ctx context.Context, conf *Config) (*persistedSecretStore, error) {
+	s := &persistedSecretStore{enabled: conf.Secret.Store.Enabled}
+	if !s.enabled {
+		return s, nil
+	}
+
+	p, err := conf.Secret.Store.NewProvider()
+	if err != nil {
+		return nil, fmt.Errorf("secret store provider: %w", err)
+	}
+
+	key, err := newStoreKey(ctx, conf, conf.Secret.Store.Encryption, false)
+	if err != nil {
+		return nil, fmt.Errorf("secret store key: %w", err)
+	}
+
+	if f, ok := p.(*secret.File); ok {
+		l, err := lockFile(ctx, f.Path+".lock", conf.Lock.Wait)
+		if err != nil {
+			return nil, fmt.Errorf("lock secret store: %w", err)
+		}
+
+		s.lock = l
+	}
+
+	s.provider = p
+	s.key = key
+	return s, nil
+}
+
+func (s *persistedSecretStore) String() string {
+	if s.provider == nil {
+		return "disabled"
+	}
+	return s.provider.String()
+}
+
+func (s *persistedSecretStore) Close() error {
+	if s.lock == nil {
+		return nil
+	}
+	return s.lock.Unlock()
+}
+
+// Load returns an empty store if the store is disabled or not exist yet.
+func (s *persistedSecretStore) Load(ctx context.Context) (bybit.SecretStore, error) {
+	secrets := bybit.SecretStore{}
+	if !s.enabled {
+		return secrets, nil
+	}
+
+	data, err := s.provider.Load(ctx)
+	if errors.Is(err, secret.ErrNotFound) {
+		return secrets, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("load secrets from %s: %w", s.provider, err)
+	}
+	if err := bybit.OpenSecrets(bytes.NewReader(data), secrets, s.key); err != nil {
+		return nil, fmt.Errorf("load secrets from %s: %w", s.provider, err)
+	}
+
+	return secrets, nil
+}
+
+func (s *persistedSecretStore) Save(ctx context.Context, secrets bybit.SecretStore) error {
+	if !s.enabled {
+		return nil
+	}
+
+	var (
+		w   bytes.Buffer
+		err error
+	)
+	if s.key == nil {
+		err = bybit.SaveSecrets(&w, secrets)
+	} else {
+		err = bybit.SealSecrets(&w, secrets, s.key)
+	}
+	if err != nil {
+		return fmt.Errorf("encode secrets: %w", err)
+	}
+	if err := s.provider.Store(ctx, w.Bytes()); err != nil {
+		return fmt.Errorf("save secrets to %s: %w", s.provider, err)
+	}
+
+	return nil
+}
+
+// Returns nil if the store is not encrypted.
+// The passphrase is asked twice if `confirm` is true and it is read from the terminal.
+func newStoreKey(ctx context.Context, conf *Config, enc SecretStoreEncryptionConfig, confirm bool) (*bybit.StoreKey, error) {
+	switch enc.Type {
+	case "", "none":
+		return nil, nil
+
+	case "passphrase":
+		p, err := secret.ReadPassphrase(enc.Env, "Passphrase for the secret store: ", confirm)
+		if err != nil {
+			return nil, err
+		}
+		return bybit.NewPassphraseKey(p), nil
+
+	case "env":
+		v, ok := os.LookupEnv(enc.Env)
+		if !ok {
+			return nil, fmt.Errorf("env %s not set", enc.Env)
+		}
+		k, err := base64.StdEncoding.DecodeString(strings.TrimSpace(v))
+		if err != nil {
+			return nil, fmt.Errorf("decode key in env %s: %w", enc.Env, err)
+		}
+		return bybit.NewRawKey(k)
+
+	case "rsa":
+		s, err := readActingSecret(ctx, conf)
+		if err != nil {
+			return nil, err
+		}
+		return bybit.NewRsaKey(s)
+
+	default:
+		return nil, fmt.Errorf("unknown type of encryption: %s", enc.Type)
+	}
+}
+
+// listSubMembers returns every sub member of the main account.
+func listSubMembers(ctx context.Context, client bybit.Client) ([]bybit.SubMember, error) {
+	members, err := bybit.CollectPages[bybit.SubMember](ctx, client.User().SubMembers, bybit.UserSubMembersReq{PageSize: 100})
+	if err != nil {
+		return nil, fmt.Errorf("query sub members: %w", err)
+	}
+
+	return members, nil
+}
+
+func resolveSubMember(ctx context.Context, client bybit.Client, username string) (bybit.UserId, error) {
+	members, err := listSubMembers(ctx, client)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, v := range members {
+		if v.Username == username {
+			return v.UserId, nil
+		}
+	}
+
+	return 0, fmt.Errorf("sub member not found: %s", username)
+}
@@ -0,0 +1,167 @@
+package cmd
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/lesomnus/tiny-short/bybit"
+)
+
+// SubAccountSelector selects sub accounts by their username or remark.
+// Given conditions are all required to be matched.
+type SubAccountSelector struct {
+	All     bool     `yaml:"all"`     // Selects every sub account.
+	Glob    string   `yaml:"glob"`    // Glob on username, e.g. "bot-*".
+	Regex   string   `yaml:"regex"`   // Regular expression on username.
+	Remark  string   `yaml:"remark"`  // Glob on remark.
+	Exclude []string `yaml:"exclude"` // Globs on username to exclude.
+}
+
+func (s *SubAccountSelector) IsEmpty() bool {
+	return !s.All && s.Glob == "" && s.Regex == "" && s.Remark == ""
+}
+
+func (s *SubAccountSelector) String() string {
+	vs := []string{}
+	if s.All {
+		vs = append(vs, "all")
+	}
+	if s.Glob != "" {
+		vs = append(vs, fmt.Sprintf("glob %s", s.Glob))
+	}
+	if s.Regex != "" {
+		vs = append(vs, fmt.Sprintf("regex %s", s.Regex))
+	}
+	if s.Remark != "" {
+		vs = append(vs, fmt.Sprintf("remark %s", s.Remark))
+	}
+	if len(s.Exclude) > 0 {
+		vs = append(vs, fmt.Sprintf("exclude %s", strings.Join(s.Exclude, ",")))
+	}
+
+	return strings.Join(vs, ", ")
+}
+
+// Match reports whether the sub member is selected.
+// Patterns must be valid; see `validate`.
+func (s *SubAccountSelector) Match(m bybit.SubMember) bool {
+	if s.IsEmpty() {
+		return false
+	}
+	if s.Glob != "" {
+		if ok, _ := path.Match(s.Glob, m.Username); !ok {
+			return false
+		}
+	}
+	if s.Regex != "" {
+		if !regexp.MustCompile(s.Regex).MatchString(m.Username) {
+			return false
+		}
+	}
+	if s.Remark != "" {
+		if ok, _ := path.Match(s.Remark, m.Remark); !ok {
+			return false
+		}
+	}
+	for _, p := range s.Exclude {
+		if ok, _ := path.Match(p, m.Username); ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// mayMatch reports whether a sub member of the username can be selected
+// whatever its remark is.
+func (s *SubAccountSelector) mayMatch(username string) bool {
+	if s.IsEmpty() || username == "$MAIN" {
+		return false
+	}
+
+	s_ := *s
+	s_.Remark = ""
+	if s_.IsEmpty() {
+		// Selected only by the remark.
+		return true
+	}
+	return s_.Match(bybit.SubMember{Username: username})
+}
+
+func (s *SubAccountSelector) validate(field string) []error {
+	errs := []error{}
+	for _, v := range []struct {
+		key     string
+		pattern string
+	}{
+		{"glob", s.Glob},
+		{"remark", s.Remark},
+	} {
+		if _, err := path.Match(v.pattern, ""); err != nil {
+			errs = append(errs, fmt.Errorf(".%s.%s: %w: %s", field, v.key, err, v.pattern))
+		}
+	}
+	if _, err := regexp.Compile(s.Regex); err != nil {
+		errs = append(errs, fmt.Errorf(".%s.regex: %w", field, err))
+	}
+	for i, p := range s.Exclude {
+		if _, err := path.Match(p, ""); err != nil {
+			errs = append(errs, fmt.Errorf(".%s.exclude[%d]: %w: %s", field, i, err, p))
+		}
+	}
+
+	return errs
+}
+
+// resolveTransferSources replaces sources given by selectors with the sub members they select.
+// Sources given by username take precedence over the ones selected, and
+// frozen sub members or the trading account itself are never selected.
+func resolveTransferSources(conf TransferConfig, members []bybit.SubMember) []TransferSource {
+	key := func(username string, account_type bybit.AccountType) string {
+		return fmt.Sprintf("%s/%s", username, account_type)
+	}
+
+	taken := map[string]bool{
+		key(conf.To.Username, conf.To.AccountType): true,
+	}
+	for _, v := range conf.From {
+		if v.Username != "" {
+			taken[key(v.Username, v.AccountType)] = true
+		}
+	}
+
+	members = slices.Clone(members)
+	slices.SortFunc(members, func(a, b bybit.SubMember) int {
+		return strings.Compare(a.Username, b.Username)
+	})
+
+	sources := []TransferSource{}
+	for _, v := range conf.From {
+		if v.Username != "" {
+			sources = append(sources, v)
+			continue
+		}
+
+		for _, m := range members {
+			if m.Status == bybit.SubMemberStatusFrozen || !v.Match(m) {
+				continue
+			}
+
+			k := key(m.Username, v.AccountType)
+			if taken[k] {
+				continue
+			}
+			taken[k] = true
+
+			s := v
+			s.Username = m.Username
+			s.Nickname = m.Remark
+			sources = append(sources, s)
+		}
+	}
+
+	return sources
+}
@@ -0,0 +1,115 @@
+package cmd_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/lesomnus/tiny-short/bybit"
+	"github.com/lesomnus/tiny-short/cmd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubAccountSelectorMatch(t *testing.T) {
+	bot := bybit.SubMember{Username: "bot-1", Remark: "hedge-a"}
+	for _, tc := range []struct {
+		name     string
+		selector cmd.SubAccountSelector
+		member   bybit.SubMember
+		expected bool
+	}{
+		{"empty", cmd.SubAccountSelector{}, bot, false},
+		{"all", cmd.SubAccountSelector{All: true}, bot, true},
+		{"glob", cmd.SubAccountSelector{Glob: "bot-*"}, bot, true},
+		{"glob not matched", cmd.SubAccountSelector{Glob: "foo-*"}, bot, false},
+		{"regex", cmd.SubAccountSelector{Regex: `^bot-[0-9]+$`}, bot, true},
+		{"regex not matched", cmd.SubAccountSelector{Regex: `^bot-[a-z]+$`}, bot, false},
+		{"remark", cmd.SubAccountSelector{Remark: "hedge-*"}, bot, true},
+		{"remark not matched", cmd.SubAccountSelector{Remark: "hedge-b"}, bot, false},
+		{"every condition", cmd.SubAccountSelector{Glob: "bot-*", Remark: "hedge-b"}, bot, false},
+		{"exclude", cmd.SubAccountSelector{All: true, Exclude: []string{"foo", "bot-?"}}, bot, false},
+		{"exclude not matched", cmd.SubAccountSelector{All: true, Exclude: []string{"bot-test"}}, bot, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, tc.selector.Match(tc.member))
+		})
+	}
+}
+
+func TestResolveTransferSources(t *testing.T) {
+	members := []bybit.SubMember{
+		{Username: "trader"},
+		{Username: "bot-2", Remark: "second"},
+		{Username: "bot-1", Remark: "first"},
+		{Username: "bot-frozen", Status: bybit.SubMemberStatusFrozen},
+		{Username: "foo"},
+	}
+	unified := bybit.AccountTypeUnified
+	source := func(username string, account_type bybit.AccountType, percent float64) cmd.TransferSource {
+		return cmd.TransferSource{
+			AccountDescription: cmd.AccountDescription{Username: username, AccountType: account_type},
+			TransferRule:       cmd.TransferRule{Percent: percent},
+		}
+	}
+	selected := func(s cmd.SubAccountSelector, account_type bybit.AccountType, percent float64) cmd.TransferSource {
+		v := source("", account_type, percent)
+		v.SubAccountSelector = s
+		return v
+	}
+
+	for _, tc := range []struct {
+		name     string
+		from     []cmd.TransferSource
+		expected []string // "<username>/<account type>/<percent>"
+	}{
+		{
+			name:     "by username",
+			from:     []cmd.TransferSource{source("foo", unified, 0), source("bot-1", unified, 0)},
+			expected: []string{"foo/UNIFIED/0", "bot-1/UNIFIED/0"},
+		},
+		{
+			name:     "sorted by username without frozen and trading account",
+			from:     []cmd.TransferSource{selected(cmd.SubAccountSelector{All: true}, unified, 0)},
+			expected: []string{"bot-1/UNIFIED/0", "bot-2/UNIFIED/0", "foo/UNIFIED/0"},
+		},
+		{
+			name:     "exclude",
+			from:     []cmd.TransferSource{selected(cmd.SubAccountSelector{Glob: "bot-*", Exclude: []string{"bot-2"}}, unified, 0)},
+			expected: []string{"bot-1/UNIFIED/0"},
+		},
+		{
+			name:     "username takes precedence",
+			from:     []cmd.TransferSource{selected(cmd.SubAccountSelector{Glob: "bot-*"}, unified, 50), source("bot-1", unified, 10)},
+			expected: []string{"bot-2/UNIFIED/50", "bot-1/UNIFIED/10"},
+		},
+		{
+			name: "first selector takes precedence",
+			from: []cmd.TransferSource{
+				selected(cmd.SubAccountSelector{Remark: "first"}, unified, 10),
+				selected(cmd.SubAccountSelector{Glob: "bot-*"}, unified, 50),
+			},
+			expected: []string{"bot-1/UNIFIED/10", "bot-2/UNIFIED/50"},
+		},
+		{
+			name: "same username of other account type",
+			from: []cmd.TransferSource{
+				selected(cmd.SubAccountSelector{Glob: "bot-1"}, unified, 0),
+				selected(cmd.SubAccountSelector{Glob: "bot-1"}, bybit.AccountTypeFund, 0),
+				selected(cmd.SubAccountSelector{Glob: "trader"}, bybit.AccountTypeFund, 0),
+			},
+			expected: []string{"bot-1/UNIFIED/0", "bot-1/FUND/0", "trader/FUND/0"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			sources := cmd.ResolveTransferSources(cmd.TransferConfig{
+				To:   cmd.AccountDescription{Username: "trader", AccountType: unified},
+				From: tc.from,
+			}, members)
+
+			actual := []string{}
+			for _, v := range sources {
+				actual = append(actual, fmt.Sprintf("%s/%s/%v", v.Username, v.AccountType, v.Percent))
+			}
+			require.Equal(t, tc.expected, actual)
+		})
+	}
+}
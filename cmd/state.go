@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lesomnus/tiny-short/bybit"
+	"github.com/lesomnus/tiny-short/secret"
+)
+
+// runState is kept between runs.
+type runState struct {
+	file *secret.File // Not persisted if nil, e.g. while replaying a cassette.
+
+	// Time until which funding income is transferred, by "<uid>/<coin>".
+	FundingUntil map[string]time.Time `json:"funding_until"`
+	// Funding income counted until `FundingUntil` but not transferred yet, by "<uid>/<coin>".
+	FundingCarried map[string]bybit.Amount `json:"funding_carried,omitempty"`
+}
+
+func loadRunState(ctx context.Context, path string) (*runState, error) {
+	s := &runState{
+		file:           &secret.File{Path: path},
+		FundingUntil:   map[string]time.Time{},
+		FundingCarried: map[string]bybit.Amount{},
+	}
+
+	data, err := s.file.Load(ctx)
+	if err != nil {
+		if errors.Is(err, secret.ErrNotFound) {
+			return s, nil
+		}
+		return nil, fmt.Errorf("read state: %w", err)
+	}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("unmarshal state at %s: %w", path, err)
+	}
+	if s.FundingUntil == nil {
+		s.FundingUntil = map[string]time.Time{}
+	}
+	if s.FundingCarried == nil {
+		s.FundingCarried = map[string]bybit.Amount{}
+	}
+
+	return s, nil
+}
+
+func (s *runState) Save(ctx context.Context) error {
+	if s.file == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("marshal state: %w", err)
+	}
+	if err := s.file.Store(ctx, data); err != nil {
+		return fmt.Errorf("write state: %w", err)
+	}
+
+	return nil
+}
+
+func fundingKey(uid bybit.UserId, coin bybit.Coin) string {
+	return fmt.Sprintf("%s/%s", uid, coin)
+}
@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/lesomnus/tiny-short/bybit"
+	"github.com/lesomnus/tiny-short/bybit/ws"
+)
+
+// orderWatcher learns closed orders and wallet balances of the account from the private stream
+// so fills are known at once instead of polling the order history.
+type orderWatcher struct {
+	stream *ws.Stream
+	cancel context.CancelFunc
+
+	mu      sync.Mutex
+	updated chan struct{} // Closed and replaced on every update.
+	orders  map[string]bybit.Order
+	wallet  map[bybit.Coin]bybit.Amount
+}
+
+// watchOrders returns after the stream is subscribed so no update is missed after it.
+func watchOrders(ctx context.Context, addr string, secret bybit.SecretRecord) (*orderWatcher, error) {
+	w := &orderWatcher{
+		stream:  ws.NewPrivateStream(addr, secret),
+		updated: make(chan struct{}),
+		orders:  map[string]bybit.Order{},
+		wallet:  map[bybit.Coin]bybit.Amount{},
+	}
+	if err := w.stream.SubscribeOrders(ctx, w.onOrders); err != nil {
+		return nil, err
+	}
+	if err := w.stream.SubscribeWallet(ctx, w.onWallet); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	w.cancel = cancel
+
+	go w.stream.Run(ctx)
+
+	ready_ctx, ready_cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer ready_cancel()
+	if err := w.stream.Ready(ready_ctx); err != nil {
+		cancel()
+		return nil, fmt.Errorf("private stream: %w", err)
+	}
+
+	return w, nil
+}
+
+func (w *orderWatcher) Close() {
+	w.cancel()
+}
+
+func (w *orderWatcher) onOrders(vs []bybit.Order) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, v := range vs {
+		if v.OrderStatus.IsClosed() {
+			w.orders[v.OrderId] = v
+		}
+	}
+	w.notify()
+}
+
+func (w *orderWatcher) onWallet(vs []ws.Wallet) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, v := range vs {
+		if v.AccountType != bybit.AccountTypeUnified {
+			continue
+		}
+		for _, c := range v.Coins {
+			w.wallet[c.Coin] = c.WalletBalance
+		}
+	}
+	w.notify()
+}
+
+// Must be called with the lock held.
+func (w *orderWatcher) notify() {
+	close(w.updated)
+	w.updated = make(chan struct{})
+}
+
+// WaitOrder waits until the order is closed.
+func (w *orderWatcher) WaitOrder(ctx context.Context, order_id string) (bybit.Order, error) {
+	for {
+		w.mu.Lock()
+		order, ok := w.orders[order_id]
+		updated := w.updated
+		w.mu.Unlock()
+		if ok {
+			return order, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return bybit.Order{}, ctx.Err()
+		case <-updated:
+		}
+	}
+}
+
+// Balance returns the latest wallet balance of the coin in the unified account
+// if it is updated after the watch started.
+func (w *orderWatcher) Balance(coin bybit.Coin) (bybit.Amount, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	v, ok := w.wallet[coin]
+	return v, ok
+}
@@ -0,0 +1,136 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Interval between attempts while waiting for a lock held by another process.
+var PollInterval = 500 * time.Millisecond
+
+var (
+	ErrLocked       = errors.New("locked")
+	ErrNotSupported = errors.New("file lock is not supported on this platform")
+)
+
+type LockedError struct {
+	Path string
+	Pid  int // 0 if the holder is unknown.
+}
+
+func (e *LockedError) Error() string {
+	if e.Pid == 0 {
+		return fmt.Sprintf("%s is locked by another process", e.Path)
+	}
+	return fmt.Sprintf("%s is locked by another process (PID %d)", e.Path, e.Pid)
+}
+
+func (e *LockedError) Unwrap() error {
+	return ErrLocked
+}
+
+// Lock is an advisory lock on a file.
+// The file holds the PID of the process that owns the lock.
+type Lock struct {
+	f *os.File
+}
+
+// TryLock acquires the lock at given path without blocking.
+// It returns `*LockedError` if the lock is held by another process.
+func TryLock(path string) (*Lock, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("open: %w", err)
+	}
+
+	if err := flock(f); err != nil {
+		defer f.Close()
+		if !errors.Is(err, ErrLocked) {
+			return nil, fmt.Errorf("lock: %w", err)
+		}
+
+		return nil, &LockedError{Path: path, Pid: readPid(f)}
+	}
+
+	l := &Lock{f: f}
+	if err := l.writePid(); err != nil {
+		l.Unlock()
+		return nil, fmt.Errorf("write PID: %w", err)
+	}
+
+	return l, nil
+}
+
+// Wait acquires the lock at given path, blocking until it is released by
+// the other process or the context is done.
+func Wait(ctx context.Context, path string) (*Lock, error) {
+	for {
+		l, err := TryLock(path)
+		if err == nil {
+			return l, nil
+		}
+		if !errors.Is(err, ErrLocked) {
+			return nil, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%w: %w", ctx.Err(), err)
+		case <-time.After(PollInterval):
+		}
+	}
+}
+
+// Unlock releases the lock.
+// It does nothing on nil so the caller can run without a lock.
+func (l *Lock) Unlock() error {
+	if l == nil {
+		return nil
+	}
+
+	// Lock file is not removed since other process may be opened it
+	// and waiting for the lock.
+	errs := []error{}
+	if err := l.f.Truncate(0); err != nil {
+		errs = append(errs, fmt.Errorf("truncate: %w", err))
+	}
+	if err := funlock(l.f); err != nil {
+		errs = append(errs, fmt.Errorf("unlock: %w", err))
+	}
+	if err := l.f.Close(); err != nil {
+		errs = append(errs, fmt.Errorf("close: %w", err))
+	}
+
+	return errors.Join(errs...)
+}
+
+func (l *Lock) writePid() error {
+	if err := l.f.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := l.f.WriteAt([]byte(strconv.Itoa(os.Getpid())), 0); err != nil {
+		return err
+	}
+
+	return l.f.Sync()
+}
+
+func readPid(f *os.File) int {
+	data, err := io.ReadAll(io.NewSectionReader(f, 0, 32))
+	if err != nil {
+		return 0
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0
+	}
+
+	return pid
+}
@@ -0,0 +1,15 @@
+//go:build !unix && !windows
+
+package lock
+
+import (
+	"os"
+)
+
+func flock(f *os.File) error {
+	return ErrNotSupported
+}
+
+func funlock(f *os.File) error {
+	return ErrNotSupported
+}
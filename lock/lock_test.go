@@ -0,0 +1,81 @@
+package lock_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lesomnus/tiny-short/lock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLock(t *testing.T) {
+	t.Run("locked by another", func(t *testing.T) {
+		require := require.New(t)
+
+		p := filepath.Join(t.TempDir(), "lock")
+		l, err := lock.TryLock(p)
+		require.NoError(err)
+		defer l.Unlock()
+
+		_, err = lock.TryLock(p)
+		require.ErrorIs(err, lock.ErrLocked)
+
+		var locked *lock.LockedError
+		require.True(errors.As(err, &locked))
+		require.Equal(os.Getpid(), locked.Pid)
+	})
+
+	t.Run("lock again after unlock", func(t *testing.T) {
+		require := require.New(t)
+
+		p := filepath.Join(t.TempDir(), "lock")
+		l, err := lock.TryLock(p)
+		require.NoError(err)
+		require.NoError(l.Unlock())
+
+		l, err = lock.TryLock(p)
+		require.NoError(err)
+		require.NoError(l.Unlock())
+	})
+
+	t.Run("wait until unlocked", func(t *testing.T) {
+		require := require.New(t)
+
+		p := filepath.Join(t.TempDir(), "lock")
+		l, err := lock.TryLock(p)
+		require.NoError(err)
+
+		held := l
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			held.Unlock()
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		waited, err := lock.Wait(ctx, p)
+		require.NoError(err)
+		require.NoError(waited.Unlock())
+	})
+
+	t.Run("wait is canceled", func(t *testing.T) {
+		require := require.New(t)
+
+		p := filepath.Join(t.TempDir(), "lock")
+		l, err := lock.TryLock(p)
+		require.NoError(err)
+		defer l.Unlock()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		_, err = lock.Wait(ctx, p)
+		require.ErrorIs(err, context.DeadlineExceeded)
+		require.ErrorIs(err, lock.ErrLocked)
+	})
+}
@@ -23,15 +23,52 @@ func main() {
 				Value:   ".tiny-short.yaml",
 				Usage:   "path to a config file",
 			},
+			&cli.StringFlag{
+				Name:    "profile",
+				Aliases: []string{"p"},
+				EnvVars: []string{"TINY_SHORT_PROFILE"},
+				Usage:   "name of the profile in the config to use",
+			},
+			&cli.BoolFlag{
+				Name:  "wait",
+				Usage: "wait for other running instance to finish instead of fail",
+			},
+			&cli.StringFlag{
+				Name:  "record",
+				Usage: "path to a cassette file where REST requests and responses of the run are recorded",
+			},
+			&cli.StringFlag{
+				Name:  "replay",
+				Usage: "path to a cassette file whose responses are replayed instead of requesting Bybit",
+			},
 		},
 		Before: func(c *cli.Context) error {
+			switch c.Args().First() {
+			case "config", "init":
+				// Config commands read the config by themselves to report its problems
+				// and init writes a new one.
+				return nil
+			}
+
 			p := c.String("conf")
-			conf_, err := cmd.ReadConfig(p)
+			conf_, err := cmd.ReadConfig(p, c.String("profile"))
 			if err != nil {
 				return fmt.Errorf("read config: %w", err)
 			}
 
 			conf = conf_
+			if c.IsSet("wait") {
+				conf.Lock.Wait = c.Bool("wait")
+			}
+			switch {
+			case c.IsSet("record") && c.IsSet("replay"):
+				return fmt.Errorf("--record and --replay cannot be given together")
+			case c.IsSet("record"):
+				conf.Cassette = cmd.CassetteConfig{Mode: "record", Path: c.String("record")}
+			case c.IsSet("replay"):
+				conf.Cassette = cmd.CassetteConfig{Mode: "replay", Path: c.String("replay")}
+			}
+
 			switch conf.Misc.UseColorOutput {
 			case "always":
 				color.NoColor = false
@@ -50,12 +87,153 @@ func main() {
 		},
 
 		Commands: []*cli.Command{
+			{
+				Name:  "init",
+				Usage: "sets up a key pair and writes the config interactively",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "secret-dir",
+						Value: "./secrets",
+						Usage: "directory where the key pair and the API key are written",
+					},
+					&cli.BoolFlag{
+						Name:  "force",
+						Usage: "overwrite existing config",
+					},
+					&cli.BoolFlag{
+						Name:  "reuse-key",
+						Usage: "use the existing key pair in the secret directory instead of generating one",
+					},
+					&cli.StringFlag{
+						Name:  "endpoint",
+						Usage: "Bybit endpoint to verify the API key against, e.g. a fake server; written to .debug.endpoint",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					return cmd.Init(c.Context, cmd.InitOptions{
+						Path:      c.String("conf"),
+						SecretDir: c.String("secret-dir"),
+						Force:     c.Bool("force"),
+						ReuseKey:  c.Bool("reuse-key"),
+						Endpoint:  c.String("endpoint"),
+					})
+				},
+			},
+			{
+				Name:  "config",
+				Usage: "utilities for the config",
+
+				Subcommands: []*cli.Command{
+					{
+						Name:  "check",
+						Usage: "reports every problem found in the config",
+						Flags: []cli.Flag{
+							&cli.BoolFlag{
+								Name:  "remote",
+								Usage: "check the API key and usernames against Bybit; nothing is written",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							return cmd.ConfigCheck(c.Context, cmd.ConfigCheckOptions{
+								Path:    c.String("conf"),
+								Profile: c.String("profile"),
+								Remote:  c.Bool("remote"),
+							})
+						},
+					},
+					{
+						Name:  "schema",
+						Usage: "prints JSON Schema of the config",
+						Action: func(c *cli.Context) error {
+							data, err := cmd.ConfigSchema()
+							if err != nil {
+								return err
+							}
+
+							_, err = os.Stdout.Write(data)
+							return err
+						},
+					},
+				},
+			},
 			{
 				Name:  "key",
 				Usage: "utilities for keys",
 
 				Subcommands: []*cli.Command{
 					key.Gen,
+					key.Verify,
+					key.Show,
+					{
+						Name:  "list",
+						Usage: "lists API keys of the sub account",
+						Flags: []cli.Flag{userFlag},
+						Action: func(c *cli.Context) error {
+							return cmd.KeyList(c.Context, conf, keyOptions(c))
+						},
+					},
+					{
+						Name:  "rotate",
+						Usage: "creates a new API key of the sub account and revokes the stored one",
+						Flags: []cli.Flag{userFlag, dryRunFlag},
+						Action: func(c *cli.Context) error {
+							return cmd.KeyRotate(c.Context, conf, keyOptions(c))
+						},
+					},
+					{
+						Name:      "revoke",
+						Usage:     "revokes an API key of the sub account",
+						ArgsUsage: "API_KEY",
+						Flags:     []cli.Flag{userFlag, dryRunFlag},
+						Action: func(c *cli.Context) error {
+							if c.NArg() != 1 {
+								return fmt.Errorf("API key must be given")
+							}
+							return cmd.KeyRevoke(c.Context, conf, keyOptions(c), c.Args().First())
+						},
+					},
+					{
+						Name:  "prune",
+						Usage: "revokes API keys of the sub account created by tiny-short except the stored one",
+						Flags: []cli.Flag{userFlag, dryRunFlag},
+						Action: func(c *cli.Context) error {
+							return cmd.KeyPrune(c.Context, conf, keyOptions(c))
+						},
+					},
+					{
+						Name:  "store",
+						Usage: "utilities for the secret store",
+
+						Subcommands: []*cli.Command{
+							{
+								Name:  "rekey",
+								Usage: "seals the secret store with a new key",
+								Flags: []cli.Flag{
+									&cli.StringFlag{
+										Name:  "type",
+										Usage: `type of the new key; one of "none", "passphrase", "env", or "rsa" (default: .secret.store.encryption.type)`,
+									},
+									&cli.StringFlag{
+										Name:  "env",
+										Usage: "name of env var that holds the new passphrase or the new base64 encoded key; passphrase is prompted if not given",
+									},
+								},
+								Action: func(c *cli.Context) error {
+									enc := cmd.SecretStoreEncryptionConfig{
+										Type: c.String("type"),
+										Env:  c.String("env"),
+									}
+									if enc.Type == "" {
+										enc.Type = conf.Secret.Store.Encryption.Type
+									}
+									if enc.Type == "env" && enc.Env == "" {
+										return fmt.Errorf("--env must be given for the key of type env")
+									}
+									return cmd.KeyStoreRekey(c.Context, conf, enc)
+								},
+							},
+						},
+					},
 				},
 			},
 		},
@@ -66,3 +244,22 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+var (
+	userFlag = &cli.StringFlag{
+		Name:    "user",
+		Aliases: []string{"u"},
+		Usage:   "username of the sub account (default: .transfer.to.username)",
+	}
+	dryRunFlag = &cli.BoolFlag{
+		Name:  "dry-run",
+		Usage: "print what would be done without doing it",
+	}
+)
+
+func keyOptions(c *cli.Context) cmd.KeyOptions {
+	return cmd.KeyOptions{
+		Username: c.String("user"),
+		DryRun:   c.Bool("dry-run"),
+	}
+}
@@ -0,0 +1,28 @@
+package secret
+
+import (
+	"context"
+	"fmt"
+	"os"
+)
+
+type Env struct {
+	Name string
+}
+
+func (p *Env) String() string {
+	return fmt.Sprintf("env:%s", p.Name)
+}
+
+func (p *Env) Load(ctx context.Context) ([]byte, error) {
+	v, ok := os.LookupEnv(p.Name)
+	if !ok {
+		return nil, fmt.Errorf("%w: env %s not set", ErrNotFound, p.Name)
+	}
+
+	return []byte(v), nil
+}
+
+func (p *Env) Store(ctx context.Context, data []byte) error {
+	return ErrReadOnly
+}
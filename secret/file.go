@@ -0,0 +1,71 @@
+package secret
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+type File struct {
+	Path string
+}
+
+func (p *File) String() string {
+	return fmt.Sprintf("file:%s", p.Path)
+}
+
+func (p *File) Load(ctx context.Context) ([]byte, error) {
+	data, err := os.ReadFile(p.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %w", ErrNotFound, err)
+	}
+
+	return data, err
+}
+
+// Store replaces the file by renaming a temporary file
+// so the file is either fully written or left untouched.
+func (p *File) Store(ctx context.Context, data []byte) error {
+	dir := filepath.Dir(p.Path)
+	f, err := os.CreateTemp(dir, fmt.Sprintf(".%s.*", filepath.Base(p.Path)))
+	if err != nil {
+		return fmt.Errorf("create temp file: %w", err)
+	}
+
+	tmp := f.Name()
+	defer os.Remove(tmp)
+
+	if err := func() error {
+		defer f.Close()
+		if err := f.Chmod(0600); err != nil {
+			return fmt.Errorf("chmod: %w", err)
+		}
+		if _, err := f.Write(data); err != nil {
+			return fmt.Errorf("write: %w", err)
+		}
+		if err := f.Sync(); err != nil {
+			return fmt.Errorf("sync: %w", err)
+		}
+		return f.Close()
+	}(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp, p.Path); err != nil {
+		return fmt.Errorf("rename: %w", err)
+	}
+
+	// Persists the rename.
+	d, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("open dir: %w", err)
+	}
+	defer d.Close()
+	if err := d.Sync(); err != nil {
+		return fmt.Errorf("sync dir: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,55 @@
+package secret
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+
+	"golang.org/x/term"
+)
+
+// ReadPassphrase reads a passphrase from env or terminal.
+// Env is not used if its name is empty.
+func ReadPassphrase(env string, prompt string, confirm bool) ([]byte, error) {
+	if env != "" {
+		if v, ok := os.LookupEnv(env); ok {
+			return []byte(v), nil
+		}
+	}
+
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		if env == "" {
+			return nil, errors.New("passphrase not given")
+		}
+		return nil, fmt.Errorf("passphrase not given: set env %s", env)
+	}
+
+	read := func(prompt string) ([]byte, error) {
+		fmt.Fprint(os.Stderr, prompt)
+		defer fmt.Fprintln(os.Stderr)
+		return term.ReadPassword(fd)
+	}
+
+	p, err := read(prompt)
+	if err != nil {
+		return nil, fmt.Errorf("read passphrase: %w", err)
+	}
+	if len(p) == 0 {
+		return nil, errors.New("passphrase cannot be empty")
+	}
+	if !confirm {
+		return p, nil
+	}
+
+	q, err := read("Confirm: ")
+	if err != nil {
+		return nil, fmt.Errorf("read passphrase: %w", err)
+	}
+	if !bytes.Equal(p, q) {
+		return nil, errors.New("passphrase does not match")
+	}
+
+	return p, nil
+}
@@ -0,0 +1,23 @@
+package secret
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	ErrNotFound = errors.New("secret not found")
+	ErrReadOnly = errors.New("secret is read-only")
+)
+
+// Provider reads and writes a secret from where it is kept.
+type Provider interface {
+	// Describes where the secret is kept, e.g. "file:./secrets/api.key".
+	String() string
+
+	// Returns `ErrNotFound` if the secret does not exist.
+	Load(ctx context.Context) ([]byte, error)
+
+	// Returns `ErrReadOnly` if the provider cannot write the secret.
+	Store(ctx context.Context, data []byte) error
+}
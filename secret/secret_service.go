@@ -0,0 +1,157 @@
+package secret
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// See https://specifications.freedesktop.org/secret-service-spec/latest/.
+const (
+	ssDest           = "org.freedesktop.secrets"
+	ssPath           = dbus.ObjectPath("/org/freedesktop/secrets")
+	ssDefaultAlias   = "default"
+	ssIfaceService   = "org.freedesktop.Secret.Service"
+	ssIfaceItem      = "org.freedesktop.Secret.Item"
+	ssIfaceSession   = "org.freedesktop.Secret.Session"
+	ssIfaceColl      = "org.freedesktop.Secret.Collection"
+	ssPropLabel      = "org.freedesktop.Secret.Item.Label"
+	ssPropAttributes = "org.freedesktop.Secret.Item.Attributes"
+	ssNone           = dbus.ObjectPath("/")
+)
+
+type ssSecret struct {
+	Session     dbus.ObjectPath
+	Parameters  []byte
+	Value       []byte
+	ContentType string
+}
+
+// SecretService keeps a secret in the freedesktop Secret Service,
+// e.g. GNOME Keyring or KeePassXC, through the D-Bus session bus.
+// The item is looked up by its attributes.
+type SecretService struct {
+	Label      string
+	Attributes map[string]string
+}
+
+func (p *SecretService) String() string {
+	kvs := []string{}
+	for k, v := range p.Attributes {
+		kvs = append(kvs, fmt.Sprintf("%s=%s", k, v))
+	}
+	slices.Sort(kvs)
+	return fmt.Sprintf("secret-service:%s", strings.Join(kvs, ","))
+}
+
+func (p *SecretService) Load(ctx context.Context) ([]byte, error) {
+	conn, err := dbus.ConnectSessionBus(dbus.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("connect to session bus: %w", err)
+	}
+	defer conn.Close()
+
+	svc := conn.Object(ssDest, ssPath)
+
+	var (
+		unlocked []dbus.ObjectPath
+		locked   []dbus.ObjectPath
+	)
+	if err := svc.CallWithContext(ctx, ssIfaceService+".SearchItems", 0, p.Attributes).Store(&unlocked, &locked); err != nil {
+		return nil, fmt.Errorf("search items: %w", err)
+	}
+	if len(unlocked) == 0 && len(locked) > 0 {
+		var prompt dbus.ObjectPath
+		if err := svc.CallWithContext(ctx, ssIfaceService+".Unlock", 0, locked).Store(&unlocked, &prompt); err != nil {
+			return nil, fmt.Errorf("unlock items: %w", err)
+		}
+		if len(unlocked) == 0 {
+			return nil, errors.New("item is locked; unlock the keyring first")
+		}
+	}
+	if len(unlocked) == 0 {
+		return nil, fmt.Errorf("%w: no item with attributes %v", ErrNotFound, p.Attributes)
+	}
+
+	session, err := p.openSession(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Object(ssDest, session).CallWithContext(ctx, ssIfaceSession+".Close", 0)
+
+	var s ssSecret
+	if err := conn.Object(ssDest, unlocked[0]).CallWithContext(ctx, ssIfaceItem+".GetSecret", 0, session).Store(&s); err != nil {
+		return nil, fmt.Errorf("get secret: %w", err)
+	}
+
+	return s.Value, nil
+}
+
+func (p *SecretService) Store(ctx context.Context, data []byte) error {
+	conn, err := dbus.ConnectSessionBus(dbus.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("connect to session bus: %w", err)
+	}
+	defer conn.Close()
+
+	var coll dbus.ObjectPath
+	if err := conn.Object(ssDest, ssPath).CallWithContext(ctx, ssIfaceService+".ReadAlias", 0, ssDefaultAlias).Store(&coll); err != nil {
+		return fmt.Errorf("read alias: %w", err)
+	}
+	if coll == ssNone {
+		return errors.New("default collection not found")
+	}
+
+	session, err := p.openSession(ctx, conn)
+	if err != nil {
+		return err
+	}
+	defer conn.Object(ssDest, session).CallWithContext(ctx, ssIfaceSession+".Close", 0)
+
+	label := p.Label
+	if label == "" {
+		label = p.String()
+	}
+
+	props := map[string]dbus.Variant{
+		ssPropLabel:      dbus.MakeVariant(label),
+		ssPropAttributes: dbus.MakeVariant(p.Attributes),
+	}
+	s := ssSecret{
+		Session:     session,
+		Parameters:  []byte{},
+		Value:       data,
+		ContentType: "text/plain",
+	}
+
+	var (
+		item   dbus.ObjectPath
+		prompt dbus.ObjectPath
+	)
+	if err := conn.Object(ssDest, coll).CallWithContext(ctx, ssIfaceColl+".CreateItem", 0, props, s, true).Store(&item, &prompt); err != nil {
+		return fmt.Errorf("create item: %w", err)
+	}
+	if prompt != ssNone {
+		return errors.New("collection is locked; unlock the keyring first")
+	}
+
+	return nil
+}
+
+// Opens a session without transport encryption since the session bus
+// is private to the user.
+func (p *SecretService) openSession(ctx context.Context, conn *dbus.Conn) (dbus.ObjectPath, error) {
+	var (
+		output  dbus.Variant
+		session dbus.ObjectPath
+	)
+	if err := conn.Object(ssDest, ssPath).CallWithContext(ctx, ssIfaceService+".OpenSession", 0, "plain", dbus.MakeVariant("")).Store(&output, &session); err != nil {
+		return "", fmt.Errorf("open session: %w", err)
+	}
+
+	return session, nil
+}
@@ -0,0 +1,168 @@
+package secret_test
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/lesomnus/tiny-short/secret"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeSecret struct {
+	Session     dbus.ObjectPath
+	Parameters  []byte
+	Value       []byte
+	ContentType string
+}
+
+// fakeSecretService implements a subset of the Secret Service
+// that is used by `secret.SecretService`.
+type fakeSecretService struct {
+	conn *dbus.Conn
+
+	mu    sync.Mutex
+	items map[dbus.ObjectPath]*fakeItem
+}
+
+type fakeItem struct {
+	attrs map[string]string
+	value []byte
+}
+
+func (s *fakeSecretService) OpenSession(algorithm string, input dbus.Variant) (dbus.Variant, dbus.ObjectPath, *dbus.Error) {
+	if algorithm != "plain" {
+		return dbus.Variant{}, "", dbus.MakeFailedError(fmt.Errorf("not supported"))
+	}
+	return dbus.MakeVariant(""), "/org/freedesktop/secrets/session/1", nil
+}
+
+func (s *fakeSecretService) SearchItems(attrs map[string]string) ([]dbus.ObjectPath, []dbus.ObjectPath, *dbus.Error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ps := []dbus.ObjectPath{}
+	for p, item := range s.items {
+		ok := true
+		for k, v := range attrs {
+			ok = ok && item.attrs[k] == v
+		}
+		if ok {
+			ps = append(ps, p)
+		}
+	}
+	return ps, []dbus.ObjectPath{}, nil
+}
+
+func (s *fakeSecretService) ReadAlias(name string) (dbus.ObjectPath, *dbus.Error) {
+	return "/org/freedesktop/secrets/collection/login", nil
+}
+
+func (s *fakeSecretService) CreateItem(props map[string]dbus.Variant, secret fakeSecret, replace bool) (dbus.ObjectPath, dbus.ObjectPath, *dbus.Error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attrs := props["org.freedesktop.Secret.Item.Attributes"].Value().(map[string]string)
+	for p, item := range s.items {
+		if replace && fmt.Sprint(item.attrs) == fmt.Sprint(attrs) {
+			item.value = secret.Value
+			return p, "/", nil
+		}
+	}
+
+	p := dbus.ObjectPath(fmt.Sprintf("/org/freedesktop/secrets/collection/login/%d", len(s.items)+1))
+	item := &fakeItem{attrs: attrs, value: secret.Value}
+	s.items[p] = item
+	if err := s.conn.Export(&fakeItemObject{s: s, path: p}, p, "org.freedesktop.Secret.Item"); err != nil {
+		return "", "", dbus.MakeFailedError(err)
+	}
+	return p, "/", nil
+}
+
+func (s *fakeSecretService) Close() *dbus.Error {
+	return nil
+}
+
+type fakeItemObject struct {
+	s    *fakeSecretService
+	path dbus.ObjectPath
+}
+
+func (o *fakeItemObject) GetSecret(session dbus.ObjectPath) (fakeSecret, *dbus.Error) {
+	o.s.mu.Lock()
+	defer o.s.mu.Unlock()
+
+	return fakeSecret{
+		Session:     session,
+		Parameters:  []byte{},
+		Value:       o.s.items[o.path].value,
+		ContentType: "text/plain",
+	}, nil
+}
+
+// Starts a private session bus serving the fake Secret Service.
+func startFakeSecretService(t *testing.T) {
+	bin, err := exec.LookPath("dbus-daemon")
+	if err != nil {
+		t.Skip("dbus-daemon not found")
+	}
+
+	cmd := exec.Command(bin, "--session", "--nofork", "--print-address")
+	out, err := cmd.StdoutPipe()
+	require.NoError(t, err)
+	require.NoError(t, cmd.Start())
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+
+	addr, err := bufio.NewReader(out).ReadString('\n')
+	require.NoError(t, err)
+	t.Setenv("DBUS_SESSION_BUS_ADDRESS", strings.TrimSpace(addr))
+
+	conn, err := dbus.ConnectSessionBus()
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	s := &fakeSecretService{conn: conn, items: map[dbus.ObjectPath]*fakeItem{}}
+	require.NoError(t, conn.Export(s, "/org/freedesktop/secrets", "org.freedesktop.Secret.Service"))
+	require.NoError(t, conn.Export(s, "/org/freedesktop/secrets/collection/login", "org.freedesktop.Secret.Collection"))
+	require.NoError(t, conn.Export(s, "/org/freedesktop/secrets/session/1", "org.freedesktop.Secret.Session"))
+
+	reply, err := conn.RequestName("org.freedesktop.secrets", dbus.NameFlagDoNotQueue)
+	require.NoError(t, err)
+	require.Equal(t, dbus.RequestNameReplyPrimaryOwner, reply)
+}
+
+func TestSecretService(t *testing.T) {
+	startFakeSecretService(t)
+
+	t.Run("not found", func(t *testing.T) {
+		p := &secret.SecretService{Attributes: map[string]string{"service": "tiny-short", "kind": "none"}}
+		_, err := p.Load(context.Background())
+		require.ErrorIs(t, err, secret.ErrNotFound)
+	})
+
+	t.Run("store and load", func(t *testing.T) {
+		require := require.New(t)
+
+		ctx := context.Background()
+		p := &secret.SecretService{Attributes: map[string]string{"service": "tiny-short", "kind": "api-key"}}
+		require.NoError(p.Store(ctx, []byte("foo")))
+
+		v, err := p.Load(ctx)
+		require.NoError(err)
+		require.Equal("foo", string(v))
+
+		require.NoError(p.Store(ctx, []byte("bar")))
+
+		v, err = p.Load(ctx)
+		require.NoError(err)
+		require.Equal("bar", string(v))
+	})
+}
@@ -0,0 +1,38 @@
+package secret
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// SystemdCreds reads a credential passed by systemd,
+// e.g. `LoadCredential=` or `LoadCredentialEncrypted=` of a service unit.
+// See https://systemd.io/CREDENTIALS/.
+type SystemdCreds struct {
+	Name string
+}
+
+func (p *SystemdCreds) String() string {
+	return fmt.Sprintf("systemd-creds:%s", p.Name)
+}
+
+func (p *SystemdCreds) Load(ctx context.Context) ([]byte, error) {
+	dir, ok := os.LookupEnv("CREDENTIALS_DIRECTORY")
+	if !ok || dir == "" {
+		return nil, fmt.Errorf("%w: CREDENTIALS_DIRECTORY not set", ErrNotFound)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, p.Name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %w", ErrNotFound, err)
+	}
+
+	return data, err
+}
+
+func (p *SystemdCreds) Store(ctx context.Context, data []byte) error {
+	return ErrReadOnly
+}